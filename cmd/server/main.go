@@ -31,6 +31,8 @@ func main() {
     }
 
     go built.Hub.Run()
+    go built.Keys.Run(ctx)
+    go built.Seasons.Run(ctx)
 
     go func() {
         log.Printf("server listening on %s", built.Server.Addr)
@@ -1,6 +1,7 @@
 ﻿package auth
 
 import (
+    "context"
     "time"
 
     "github.com/golang-jwt/jwt/v5"
@@ -8,53 +9,91 @@ import (
     "xo-server/internal/domain"
 )
 
+type RevocationChecker interface {
+    IsTokenFamilyRevoked(ctx context.Context, familyID uuid.UUID) (bool, error)
+}
+
 type JWTProvider struct {
-    secret []byte
-    ttl    time.Duration
+    keys        *KeySet
+    ttl         time.Duration
+    revocations RevocationChecker
+}
+
+func NewJWTProvider(secret string, ttl time.Duration, revocations RevocationChecker) *JWTProvider {
+    return NewJWTProviderWithKeys(NewHMACKeySet(secret), ttl, revocations)
 }
 
-func NewJWTProvider(secret string, ttl time.Duration) *JWTProvider {
-    return &JWTProvider{secret: []byte(secret), ttl: ttl}
+func NewJWTProviderWithKeys(keys *KeySet, ttl time.Duration, revocations RevocationChecker) *JWTProvider {
+    return &JWTProvider{keys: keys, ttl: ttl, revocations: revocations}
 }
 
 type Claims struct {
-    UserID   string `json:"uid"`
-    Username string `json:"usr"`
+    UserID    string `json:"uid"`
+    Username  string `json:"usr"`
+    SessionID string `json:"sid"`
+    Role      string `json:"rol,omitempty"`
+    Guest     bool   `json:"gst,omitempty"`
     jwt.RegisteredClaims
 }
 
-func (p *JWTProvider) IssueToken(user *domain.User) (string, error) {
+func (p *JWTProvider) IssueToken(user *domain.User, sessionID uuid.UUID) (string, error) {
     now := time.Now().UTC()
     claims := Claims{
-        UserID:   user.ID.String(),
-        Username: user.Username,
+        UserID:    user.ID.String(),
+        Username:  user.Username,
+        SessionID: sessionID.String(),
+        Role:      string(user.Role),
+        Guest:     user.IsGuest,
         RegisteredClaims: jwt.RegisteredClaims{
             ExpiresAt: jwt.NewNumericDate(now.Add(p.ttl)),
             IssuedAt:  jwt.NewNumericDate(now),
         },
     }
 
-    token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-    return token.SignedString(p.secret)
+    signer, err := p.keys.signingKey()
+    if err != nil {
+        return "", err
+    }
+
+    token := jwt.NewWithClaims(jwt.GetSigningMethod(signer.alg), claims)
+    if signer.kid != "" {
+        token.Header["kid"] = signer.kid
+    }
+    return token.SignedString(signer.key)
 }
 
-func (p *JWTProvider) ParseToken(tokenStr string) (*domain.User, error) {
-    token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, func(t *jwt.Token) (interface{}, error) {
-        return p.secret, nil
-    })
+func (p *JWTProvider) ParseToken(tokenStr string) (*domain.User, uuid.UUID, error) {
+    token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, p.keys.keyFunc, jwt.WithValidMethods([]string{AlgHS256, AlgRS256, AlgEdDSA}))
     if err != nil {
-        return nil, err
+        return nil, uuid.Nil, err
     }
 
     claims, ok := token.Claims.(*Claims)
     if !ok || !token.Valid {
-        return nil, domain.ErrUnauthorized
+        return nil, uuid.Nil, domain.ErrUnauthorized
     }
 
     uid, err := uuid.Parse(claims.UserID)
     if err != nil {
-        return nil, domain.ErrUnauthorized
+        return nil, uuid.Nil, domain.ErrUnauthorized
+    }
+
+    sid, err := uuid.Parse(claims.SessionID)
+    if err != nil {
+        return nil, uuid.Nil, domain.ErrUnauthorized
+    }
+
+    if p.revocations != nil {
+        revoked, err := p.revocations.IsTokenFamilyRevoked(context.Background(), sid)
+        if err != nil || revoked {
+            return nil, uuid.Nil, domain.ErrUnauthorized
+        }
+    }
+
+    role, ok := domain.ParseRole(claims.Role)
+    if !ok {
+        role = domain.RolePlayer
     }
 
-    return &domain.User{ID: uid, Username: claims.Username}, nil
+    return &domain.User{ID: uid, Username: claims.Username, Role: role, IsGuest: claims.Guest}, sid, nil
 }
@@ -0,0 +1,379 @@
+﻿package auth
+
+import (
+    "context"
+    "crypto"
+    "crypto/ed25519"
+    "crypto/rand"
+    "crypto/rsa"
+    "crypto/x509"
+    "encoding/base64"
+    "encoding/hex"
+    "encoding/json"
+    "encoding/pem"
+    "fmt"
+    "log"
+    "math/big"
+    "net/http"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/golang-jwt/jwt/v5"
+)
+
+const (
+    AlgHS256 = "HS256"
+    AlgRS256 = "RS256"
+    AlgEdDSA = "EdDSA"
+
+    privateKeyExt = ".key"
+    publicKeyExt  = ".pub"
+    kidTimeLayout = "20060102T150405Z"
+    retireGrace   = 5 * time.Minute
+)
+
+type verifyKey struct {
+    alg string
+    key interface{}
+}
+
+type signingKey struct {
+    kid       string
+    alg       string
+    key       interface{}
+    createdAt time.Time
+    generated bool
+}
+
+type KeySetOptions struct {
+    Algorithm      string
+    Dir            string
+    RotateAfter    time.Duration
+    ReloadInterval time.Duration
+    TokenTTL       time.Duration
+}
+
+type KeySet struct {
+    opts KeySetOptions
+
+    mu     sync.RWMutex
+    signer *signingKey
+    verify map[string]verifyKey
+}
+
+func NewHMACKeySet(secret string) *KeySet {
+    key := []byte(secret)
+    return &KeySet{
+        opts:   KeySetOptions{Algorithm: AlgHS256},
+        signer: &signingKey{alg: AlgHS256, key: key},
+        verify: map[string]verifyKey{"": {alg: AlgHS256, key: key}},
+    }
+}
+
+func NewKeySet(opts KeySetOptions) (*KeySet, error) {
+    if opts.Algorithm != AlgRS256 && opts.Algorithm != AlgEdDSA {
+        return nil, fmt.Errorf("unsupported signing algorithm %q", opts.Algorithm)
+    }
+    if opts.Dir == "" {
+        return nil, fmt.Errorf("key directory is required")
+    }
+    if err := os.MkdirAll(opts.Dir, 0o700); err != nil {
+        return nil, err
+    }
+
+    k := &KeySet{opts: opts, verify: make(map[string]verifyKey)}
+    if err := k.Reload(); err != nil {
+        return nil, err
+    }
+    if err := k.RotateIfDue(time.Now().UTC()); err != nil {
+        return nil, err
+    }
+    return k, nil
+}
+
+func (k *KeySet) Run(ctx context.Context) {
+    if k.opts.ReloadInterval <= 0 {
+        return
+    }
+    ticker := time.NewTicker(k.opts.ReloadInterval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case now := <-ticker.C:
+            if err := k.Reload(); err != nil {
+                log.Printf("jwt keys reload error: %v", err)
+                continue
+            }
+            if err := k.RotateIfDue(now.UTC()); err != nil {
+                log.Printf("jwt keys rotation error: %v", err)
+            }
+        }
+    }
+}
+
+func (k *KeySet) Reload() error {
+    entries, err := os.ReadDir(k.opts.Dir)
+    if err != nil {
+        return err
+    }
+
+    verify := make(map[string]verifyKey)
+    var chain []*signingKey
+    names := make([]string, 0, len(entries))
+    for _, e := range entries {
+        if !e.IsDir() {
+            names = append(names, e.Name())
+        }
+    }
+    sort.Strings(names)
+
+    for _, name := range names {
+        ext := filepath.Ext(name)
+        if ext != privateKeyExt && ext != publicKeyExt {
+            continue
+        }
+        kid := strings.TrimSuffix(name, ext)
+        path := filepath.Join(k.opts.Dir, name)
+        data, err := os.ReadFile(path)
+        if err != nil {
+            log.Printf("jwt keys skip %s: %v", name, err)
+            continue
+        }
+
+        if ext == publicKeyExt {
+            pub, alg, err := parsePublicKey(data)
+            if err != nil {
+                log.Printf("jwt keys skip %s: %v", name, err)
+                continue
+            }
+            verify[kid] = verifyKey{alg: alg, key: pub}
+            continue
+        }
+
+        priv, alg, err := parsePrivateKey(data)
+        if err != nil {
+            log.Printf("jwt keys skip %s: %v", name, err)
+            continue
+        }
+        verify[kid] = verifyKey{alg: alg, key: priv.Public()}
+        if alg != k.opts.Algorithm {
+            continue
+        }
+        createdAt, generated := kidTime(kid)
+        if !generated {
+            info, err := os.Stat(path)
+            if err != nil {
+                log.Printf("jwt keys skip %s: %v", name, err)
+                continue
+            }
+            createdAt = info.ModTime().UTC()
+        }
+        chain = append(chain, &signingKey{kid: kid, alg: alg, key: priv, createdAt: createdAt, generated: generated})
+    }
+
+    sort.SliceStable(chain, func(i, j int) bool {
+        if chain[i].createdAt.Equal(chain[j].createdAt) {
+            return chain[i].kid < chain[j].kid
+        }
+        return chain[i].createdAt.Before(chain[j].createdAt)
+    })
+    var signer *signingKey
+    if len(chain) > 0 {
+        signer = chain[len(chain)-1]
+    }
+    if k.opts.TokenTTL > 0 {
+        now := time.Now().UTC()
+        for i := 0; i < len(chain)-1; i++ {
+            retiredAt := chain[i+1].createdAt
+            if now.Sub(retiredAt) <= k.opts.TokenTTL+retireGrace {
+                continue
+            }
+            delete(verify, chain[i].kid)
+            if chain[i].generated {
+                if err := os.Remove(filepath.Join(k.opts.Dir, chain[i].kid+privateKeyExt)); err != nil && !os.IsNotExist(err) {
+                    log.Printf("jwt keys retire %s: %v", chain[i].kid, err)
+                }
+            }
+        }
+    }
+
+    k.mu.Lock()
+    k.signer = signer
+    k.verify = verify
+    k.mu.Unlock()
+    return nil
+}
+
+func (k *KeySet) RotateIfDue(now time.Time) error {
+    k.mu.RLock()
+    signer := k.signer
+    k.mu.RUnlock()
+
+    if signer != nil && (k.opts.RotateAfter <= 0 || now.Sub(signer.createdAt) < k.opts.RotateAfter) {
+        return nil
+    }
+    return k.Rotate(now)
+}
+
+func (k *KeySet) Rotate(now time.Time) error {
+    var priv crypto.Signer
+    var err error
+    switch k.opts.Algorithm {
+    case AlgRS256:
+        priv, err = rsa.GenerateKey(rand.Reader, 2048)
+    case AlgEdDSA:
+        _, priv, err = ed25519.GenerateKey(rand.Reader)
+    default:
+        return fmt.Errorf("cannot rotate %s keys", k.opts.Algorithm)
+    }
+    if err != nil {
+        return err
+    }
+
+    der, err := x509.MarshalPKCS8PrivateKey(priv)
+    if err != nil {
+        return err
+    }
+
+    suffix := make([]byte, 4)
+    if _, err := rand.Read(suffix); err != nil {
+        return err
+    }
+    kid := now.UTC().Format(kidTimeLayout) + "-" + hex.EncodeToString(suffix)
+    path := filepath.Join(k.opts.Dir, kid+privateKeyExt)
+    data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+    if err := os.WriteFile(path, data, 0o600); err != nil {
+        return err
+    }
+    return k.Reload()
+}
+
+func kidTime(kid string) (time.Time, bool) {
+    stamp, _, _ := strings.Cut(kid, "-")
+    t, err := time.Parse(kidTimeLayout, stamp)
+    if err != nil {
+        return time.Time{}, false
+    }
+    return t.UTC(), true
+}
+
+func (k *KeySet) signingKey() (*signingKey, error) {
+    k.mu.RLock()
+    defer k.mu.RUnlock()
+    if k.signer == nil {
+        return nil, fmt.Errorf("no signing key available")
+    }
+    return k.signer, nil
+}
+
+func (k *KeySet) keyFunc(t *jwt.Token) (interface{}, error) {
+    kid, _ := t.Header["kid"].(string)
+
+    k.mu.RLock()
+    vk, ok := k.verify[kid]
+    k.mu.RUnlock()
+    if !ok {
+        return nil, fmt.Errorf("unknown key id %q", kid)
+    }
+    if t.Method.Alg() != vk.alg {
+        return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+    }
+    return vk.key, nil
+}
+
+func (k *KeySet) JWKS() map[string]interface{} {
+    k.mu.RLock()
+    defer k.mu.RUnlock()
+
+    kids := make([]string, 0, len(k.verify))
+    for kid := range k.verify {
+        kids = append(kids, kid)
+    }
+    sort.Strings(kids)
+
+    keys := make([]map[string]string, 0, len(kids))
+    for _, kid := range kids {
+        vk := k.verify[kid]
+        jwk := map[string]string{"kid": kid, "alg": vk.alg, "use": "sig"}
+        switch pub := vk.key.(type) {
+        case *rsa.PublicKey:
+            jwk["kty"] = "RSA"
+            jwk["n"] = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+            jwk["e"] = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+        case ed25519.PublicKey:
+            jwk["kty"] = "OKP"
+            jwk["crv"] = "Ed25519"
+            jwk["x"] = base64.RawURLEncoding.EncodeToString(pub)
+        default:
+            continue
+        }
+        keys = append(keys, jwk)
+    }
+    return map[string]interface{}{"keys": keys}
+}
+
+func (k *KeySet) ServeJWKS(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    w.Header().Set("Cache-Control", "public, max-age=300")
+    _ = json.NewEncoder(w).Encode(k.JWKS())
+}
+
+func parsePrivateKey(data []byte) (crypto.Signer, string, error) {
+    block, _ := pem.Decode(data)
+    if block == nil {
+        return nil, "", fmt.Errorf("invalid pem")
+    }
+
+    var key interface{}
+    var err error
+    switch block.Type {
+    case "RSA PRIVATE KEY":
+        key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+    default:
+        key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+    }
+    if err != nil {
+        return nil, "", err
+    }
+
+    switch priv := key.(type) {
+    case *rsa.PrivateKey:
+        return priv, AlgRS256, nil
+    case ed25519.PrivateKey:
+        return priv, AlgEdDSA, nil
+    default:
+        return nil, "", fmt.Errorf("unsupported private key type %T", key)
+    }
+}
+
+func parsePublicKey(data []byte) (crypto.PublicKey, string, error) {
+    block, _ := pem.Decode(data)
+    if block == nil {
+        return nil, "", fmt.Errorf("invalid pem")
+    }
+
+    key, err := x509.ParsePKIXPublicKey(block.Bytes)
+    if err != nil {
+        return nil, "", err
+    }
+
+    switch pub := key.(type) {
+    case *rsa.PublicKey:
+        return pub, AlgRS256, nil
+    case ed25519.PublicKey:
+        return pub, AlgEdDSA, nil
+    default:
+        return nil, "", fmt.Errorf("unsupported public key type %T", key)
+    }
+}
@@ -0,0 +1,146 @@
+﻿package auth
+
+import (
+    "context"
+    "encoding/json"
+    "net/http"
+    "strings"
+
+    "github.com/google/uuid"
+    "xo-server/internal/domain"
+)
+
+const (
+    DefaultCookieName      = "xo_token"
+    SubprotocolTokenPrefix = "xo.token."
+)
+
+type TokenParser interface {
+    ParseToken(tokenStr string) (*domain.User, uuid.UUID, error)
+}
+
+type contextKey struct{}
+
+type sessionContextKey struct{}
+
+func WithUser(ctx context.Context, user *domain.User) context.Context {
+    return context.WithValue(ctx, contextKey{}, user)
+}
+
+func UserFromContext(ctx context.Context) (*domain.User, bool) {
+    user, ok := ctx.Value(contextKey{}).(*domain.User)
+    return user, ok && user != nil
+}
+
+func WithSessionID(ctx context.Context, sessionID uuid.UUID) context.Context {
+    return context.WithValue(ctx, sessionContextKey{}, sessionID)
+}
+
+func SessionIDFromContext(ctx context.Context) uuid.UUID {
+    sessionID, _ := ctx.Value(sessionContextKey{}).(uuid.UUID)
+    return sessionID
+}
+
+type Middleware struct {
+    tokens     TokenParser
+    cookieName string
+    allowQuery bool
+}
+
+func NewMiddleware(tokens TokenParser, cookieName string, allowQuery bool) *Middleware {
+    if cookieName == "" {
+        cookieName = DefaultCookieName
+    }
+    return &Middleware{tokens: tokens, cookieName: cookieName, allowQuery: allowQuery}
+}
+
+func (m *Middleware) Require(next http.HandlerFunc) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        token := m.TokenFromRequest(r)
+        if token == "" {
+            writeUnauthorized(w, "missing token")
+            return
+        }
+
+        user, sessionID, err := m.tokens.ParseToken(token)
+        if err != nil {
+            writeUnauthorized(w, "invalid token")
+            return
+        }
+
+        ctx := WithSessionID(WithUser(r.Context(), user), sessionID)
+        next(w, r.WithContext(ctx))
+    }
+}
+
+func (m *Middleware) TokenFromRequest(r *http.Request) string {
+    if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && token != "" {
+        return token
+    }
+    if cookie, err := r.Cookie(m.cookieName); err == nil && cookie.Value != "" {
+        return cookie.Value
+    }
+    for _, header := range r.Header.Values("Sec-WebSocket-Protocol") {
+        for _, proto := range strings.Split(header, ",") {
+            if token, ok := strings.CutPrefix(strings.TrimSpace(proto), SubprotocolTokenPrefix); ok && token != "" {
+                return token
+            }
+        }
+    }
+    if m.allowQuery {
+        return r.URL.Query().Get("token")
+    }
+    return ""
+}
+
+func (m *Middleware) SetCookie(w http.ResponseWriter, r *http.Request, token string) {
+    http.SetCookie(w, &http.Cookie{
+        Name:     m.cookieName,
+        Value:    token,
+        Path:     "/",
+        HttpOnly: true,
+        Secure:   r.TLS != nil,
+        SameSite: http.SameSiteStrictMode,
+    })
+}
+
+func (m *Middleware) SetRefreshCookie(w http.ResponseWriter, r *http.Request, token string) {
+    http.SetCookie(w, &http.Cookie{
+        Name:     m.RefreshCookieName(),
+        Value:    token,
+        Path:     "/api/refresh",
+        HttpOnly: true,
+        Secure:   r.TLS != nil,
+        SameSite: http.SameSiteStrictMode,
+    })
+}
+
+func (m *Middleware) ClearCookies(w http.ResponseWriter, r *http.Request) {
+    http.SetCookie(w, &http.Cookie{Name: m.cookieName, Path: "/", MaxAge: -1, HttpOnly: true, Secure: r.TLS != nil})
+    http.SetCookie(w, &http.Cookie{Name: m.RefreshCookieName(), Path: "/api/refresh", MaxAge: -1, HttpOnly: true, Secure: r.TLS != nil})
+}
+
+func (m *Middleware) RefreshCookieName() string {
+    return m.cookieName + "_refresh"
+}
+
+func (m *Middleware) RequirePermission(perm domain.Permission, next http.HandlerFunc) http.HandlerFunc {
+    return m.Require(func(w http.ResponseWriter, r *http.Request) {
+        user, _ := UserFromContext(r.Context())
+        if err := domain.Authorize(user, perm); err != nil {
+            writeStatus(w, http.StatusForbidden, err.Error())
+            return
+        }
+        next(w, r)
+    })
+}
+
+func writeUnauthorized(w http.ResponseWriter, message string) {
+    writeStatus(w, http.StatusUnauthorized, message)
+}
+
+func writeStatus(w http.ResponseWriter, status int, message string) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    _ = json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
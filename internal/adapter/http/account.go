@@ -0,0 +1,145 @@
+﻿package http
+
+import (
+    "encoding/json"
+    "net/http"
+    "time"
+
+    "xo-server/internal/adapter/auth"
+    "xo-server/internal/domain"
+)
+
+type accountExportResponse struct {
+    ExportedAt  string             `json:"exported_at"`
+    Profile     profileExport      `json:"profile"`
+    Games       []*gameResponse    `json:"games"`
+    Moves       []moveExport       `json:"moves"`
+    Messages    []chatResponse     `json:"messages"`
+    Friendships []friendshipExport `json:"friendships"`
+    Identities  []identityResponse `json:"identities"`
+}
+
+type profileExport struct {
+    UserID    string `json:"user_id"`
+    Username  string `json:"username"`
+    Role      string `json:"role"`
+    Guest     bool   `json:"guest"`
+    CreatedAt string `json:"created_at"`
+}
+
+type moveExport struct {
+    GameID   string `json:"game_id"`
+    Position int    `json:"position"`
+    Symbol   string `json:"symbol"`
+    At       string `json:"at"`
+}
+
+type friendshipExport struct {
+    RequesterID string `json:"requester_id"`
+    AddresseeID string `json:"addressee_id"`
+    Status      string `json:"status"`
+    CreatedAt   string `json:"created_at"`
+}
+
+func (h *Handler) handleAccountExport(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    user, ok := h.currentUser(w, r)
+    if !ok {
+        return
+    }
+
+    data, err := h.accounts.ExportData(r.Context(), user.ID)
+    if err != nil {
+        mapDomainError(w, err)
+        return
+    }
+
+    w.Header().Set("Content-Disposition", `attachment; filename="xo-account-export.json"`)
+    writeJSON(w, http.StatusOK, toAccountExportResponse(data))
+}
+
+func (h *Handler) handleDeleteAccount(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodDelete {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    user, ok := h.currentUser(w, r)
+    if !ok {
+        return
+    }
+
+    var req struct {
+        Password string `json:"password"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid json")
+        return
+    }
+
+    if err := h.accounts.DeleteAccount(r.Context(), user.ID, auth.SessionIDFromContext(r.Context()), req.Password); err != nil {
+        mapDomainError(w, err)
+        return
+    }
+
+    h.authn.ClearCookies(w, r)
+    w.WriteHeader(http.StatusNoContent)
+}
+
+func toAccountExportResponse(data *domain.AccountExport) *accountExportResponse {
+    resp := &accountExportResponse{
+        ExportedAt: data.ExportedAt.Format(time.RFC3339),
+        Profile: profileExport{
+            UserID:    data.User.ID.String(),
+            Username:  data.User.Username,
+            Role:      string(data.User.Role),
+            Guest:     data.User.IsGuest,
+            CreatedAt: data.User.CreatedAt.Format(time.RFC3339),
+        },
+        Games:       make([]*gameResponse, 0, len(data.Games)),
+        Moves:       make([]moveExport, 0, len(data.Moves)),
+        Messages:    make([]chatResponse, 0, len(data.Messages)),
+        Friendships: make([]friendshipExport, 0, len(data.Friendships)),
+        Identities:  make([]identityResponse, 0, len(data.Identities)),
+    }
+    for _, g := range data.Games {
+        resp.Games = append(resp.Games, toGameResponse(g))
+    }
+    for _, m := range data.Moves {
+        resp.Moves = append(resp.Moves, moveExport{
+            GameID:   m.GameID.String(),
+            Position: m.Position,
+            Symbol:   m.Symbol,
+            At:       m.CreatedAt.Format(time.RFC3339),
+        })
+    }
+    for _, m := range data.Messages {
+        resp.Messages = append(resp.Messages, chatResponse{
+            GameID:  m.GameID.String(),
+            UserID:  m.UserID.String(),
+            Message: m.Message,
+            At:      m.CreatedAt.Format(time.RFC3339),
+        })
+    }
+    for _, f := range data.Friendships {
+        resp.Friendships = append(resp.Friendships, friendshipExport{
+            RequesterID: f.RequesterID.String(),
+            AddresseeID: f.AddresseeID.String(),
+            Status:      string(f.Status),
+            CreatedAt:   f.CreatedAt.Format(time.RFC3339),
+        })
+    }
+    for _, i := range data.Identities {
+        resp.Identities = append(resp.Identities, identityResponse{
+            Provider:  i.Provider,
+            Subject:   i.Subject,
+            Email:     i.Email,
+            CreatedAt: i.CreatedAt.Format(time.RFC3339),
+        })
+    }
+    return resp
+}
@@ -0,0 +1,324 @@
+﻿package http
+
+import (
+    "encoding/json"
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/google/uuid"
+    "xo-server/internal/domain"
+)
+
+func (h *Handler) handleSetRole(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    actor, ok := h.currentUser(w, r)
+    if !ok {
+        return
+    }
+    userID, ok := pathUserID(w, r)
+    if !ok {
+        return
+    }
+
+    var req struct {
+        Role string `json:"role"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid json")
+        return
+    }
+
+    if err := h.auth.SetRole(r.Context(), actor, userID, domain.Role(req.Role)); err != nil {
+        mapDomainError(w, err)
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) handleAdminGames(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    actor, ok := h.currentUser(w, r)
+    if !ok {
+        return
+    }
+    limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+    games, err := h.games.ListLiveGames(r.Context(), actor, limit)
+    if err != nil {
+        mapDomainError(w, err)
+        return
+    }
+
+    resp := make([]*gameResponse, 0, len(games))
+    for _, g := range games {
+        resp = append(resp, toGameResponse(g))
+    }
+    writeJSON(w, http.StatusOK, map[string]interface{}{"games": resp})
+}
+
+func (h *Handler) handleAdminFinishGame(w http.ResponseWriter, r *http.Request) {
+    h.adminGameAction(w, r, func(actor *domain.User, gameID uuid.UUID, winnerID *uuid.UUID, reason string) (*domain.Game, error) {
+        return h.games.ForceFinish(r.Context(), actor, gameID, winnerID, reason)
+    })
+}
+
+func (h *Handler) handleAdminAbortGame(w http.ResponseWriter, r *http.Request) {
+    h.adminGameAction(w, r, func(actor *domain.User, gameID uuid.UUID, winnerID *uuid.UUID, reason string) (*domain.Game, error) {
+        if winnerID != nil {
+            return nil, domain.ErrInvalidInput
+        }
+        return h.games.AbortGame(r.Context(), actor, gameID, reason)
+    })
+}
+
+func (h *Handler) handleAdminAdjustResult(w http.ResponseWriter, r *http.Request) {
+    h.adminGameAction(w, r, func(actor *domain.User, gameID uuid.UUID, winnerID *uuid.UUID, reason string) (*domain.Game, error) {
+        return h.games.AdjustResult(r.Context(), actor, gameID, winnerID, reason)
+    })
+}
+
+func (h *Handler) adminGameAction(w http.ResponseWriter, r *http.Request, action func(actor *domain.User, gameID uuid.UUID, winnerID *uuid.UUID, reason string) (*domain.Game, error)) {
+    if r.Method != http.MethodPost {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    actor, ok := h.currentUser(w, r)
+    if !ok {
+        return
+    }
+    gameID, ok := pathGameID(w, r)
+    if !ok {
+        return
+    }
+
+    var req struct {
+        WinnerUserID *uuid.UUID `json:"winner_user_id"`
+        Reason       string     `json:"reason"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid json")
+        return
+    }
+
+    game, err := action(actor, gameID, req.WinnerUserID, req.Reason)
+    if err != nil {
+        mapDomainError(w, err)
+        return
+    }
+    writeJSON(w, http.StatusOK, map[string]interface{}{"game": toGameResponse(game)})
+}
+
+func (h *Handler) handleSuspendUser(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    actor, ok := h.currentUser(w, r)
+    if !ok {
+        return
+    }
+    userID, ok := pathUserID(w, r)
+    if !ok {
+        return
+    }
+
+    var req struct {
+        Until  *time.Time `json:"until"`
+        Reason string     `json:"reason"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid json")
+        return
+    }
+
+    if err := h.auth.SuspendUser(r.Context(), actor, userID, req.Until, req.Reason); err != nil {
+        mapDomainError(w, err)
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) handleUnsuspendUser(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    actor, ok := h.currentUser(w, r)
+    if !ok {
+        return
+    }
+    userID, ok := pathUserID(w, r)
+    if !ok {
+        return
+    }
+
+    if err := h.auth.LiftSuspension(r.Context(), actor, userID); err != nil {
+        mapDomainError(w, err)
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) handleAdminReports(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    actor, ok := h.currentUser(w, r)
+    if !ok {
+        return
+    }
+    limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+    reports, err := h.reports.ListReports(r.Context(), actor, domain.ReportStatus(r.URL.Query().Get("status")), limit)
+    if err != nil {
+        mapDomainError(w, err)
+        return
+    }
+
+    resp := make([]reportResponse, 0, len(reports))
+    for _, rep := range reports {
+        resp = append(resp, toReportResponse(rep))
+    }
+    writeJSON(w, http.StatusOK, map[string]interface{}{"reports": resp})
+}
+
+func (h *Handler) handleResolveReport(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    actor, ok := h.currentUser(w, r)
+    if !ok {
+        return
+    }
+    reportID, err := uuid.Parse(r.PathValue("id"))
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "invalid report id")
+        return
+    }
+
+    var req struct {
+        Resolution string `json:"resolution"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid json")
+        return
+    }
+
+    if err := h.reports.ResolveReport(r.Context(), actor, reportID, req.Resolution); err != nil {
+        mapDomainError(w, err)
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) handleCreateReport(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    user, ok := h.currentUser(w, r)
+    if !ok {
+        return
+    }
+
+    var req struct {
+        UserID uuid.UUID  `json:"user_id"`
+        GameID *uuid.UUID `json:"game_id"`
+        Reason string     `json:"reason"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid json")
+        return
+    }
+
+    report, err := h.reports.Report(r.Context(), user.ID, req.UserID, req.GameID, req.Reason)
+    if err != nil {
+        mapDomainError(w, err)
+        return
+    }
+    writeJSON(w, http.StatusCreated, map[string]interface{}{"report": toReportResponse(report)})
+}
+
+func (h *Handler) handleAuditLog(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    actor, ok := h.currentUser(w, r)
+    if !ok {
+        return
+    }
+
+    q := r.URL.Query()
+    filter := domain.AuditFilter{Action: domain.AuditAction(q.Get("action"))}
+    filter.Limit, _ = strconv.Atoi(q.Get("limit"))
+    var err error
+    if filter.ActorID, err = queryUUID(q.Get("actor_id")); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid actor_id")
+        return
+    }
+    if filter.TargetID, err = queryUUID(q.Get("target_id")); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid target_id")
+        return
+    }
+    if filter.Since, err = queryTime(q.Get("since")); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid since")
+        return
+    }
+    if filter.Until, err = queryTime(q.Get("until")); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid until")
+        return
+    }
+
+    events, err := h.audit.ListEvents(r.Context(), actor, filter)
+    if err != nil {
+        mapDomainError(w, err)
+        return
+    }
+
+    resp := make([]auditEventResponse, 0, len(events))
+    for _, e := range events {
+        resp = append(resp, toAuditEventResponse(e))
+    }
+    writeJSON(w, http.StatusOK, map[string]interface{}{"events": resp})
+}
+
+func queryUUID(v string) (*uuid.UUID, error) {
+    if v == "" {
+        return nil, nil
+    }
+    id, err := uuid.Parse(v)
+    if err != nil {
+        return nil, err
+    }
+    return &id, nil
+}
+
+func queryTime(v string) (*time.Time, error) {
+    if v == "" {
+        return nil, nil
+    }
+    t, err := time.Parse(time.RFC3339, v)
+    if err != nil {
+        return nil, err
+    }
+    return &t, nil
+}
@@ -0,0 +1,192 @@
+﻿package http
+
+import (
+    "encoding/json"
+    "net/http"
+)
+
+func (h *Handler) handleFriends(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    user, ok := h.currentUser(w, r)
+    if !ok {
+        return
+    }
+
+    friends, err := h.friends.ListFriends(r.Context(), user.ID)
+    if err != nil {
+        mapDomainError(w, err)
+        return
+    }
+
+    resp := make([]friendResponse, 0, len(friends))
+    for _, f := range friends {
+        resp = append(resp, toFriendResponse(f))
+    }
+    writeJSON(w, http.StatusOK, map[string]interface{}{"friends": resp})
+}
+
+func (h *Handler) handleFriendRequests(w http.ResponseWriter, r *http.Request) {
+    switch r.Method {
+    case http.MethodGet:
+        h.listFriendRequests(w, r)
+    case http.MethodPost:
+        h.sendFriendRequest(w, r)
+    default:
+        w.WriteHeader(http.StatusMethodNotAllowed)
+    }
+}
+
+func (h *Handler) listFriendRequests(w http.ResponseWriter, r *http.Request) {
+    user, ok := h.currentUser(w, r)
+    if !ok {
+        return
+    }
+
+    requests, err := h.friends.ListRequests(r.Context(), user.ID)
+    if err != nil {
+        mapDomainError(w, err)
+        return
+    }
+
+    resp := make([]friendRequestResponse, 0, len(requests))
+    for _, fr := range requests {
+        resp = append(resp, toFriendRequestResponse(fr))
+    }
+    writeJSON(w, http.StatusOK, map[string]interface{}{"requests": resp})
+}
+
+func (h *Handler) sendFriendRequest(w http.ResponseWriter, r *http.Request) {
+    user, ok := h.currentUser(w, r)
+    if !ok {
+        return
+    }
+
+    var req struct {
+        Username string `json:"username"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid json")
+        return
+    }
+
+    f, err := h.friends.SendRequest(r.Context(), user.ID, req.Username)
+    if err != nil {
+        mapDomainError(w, err)
+        return
+    }
+
+    other := f.Other(user.ID)
+    if f.RequesterID == user.ID {
+        h.notifier.NotifyFriendRequest(other, user)
+    } else {
+        h.notifier.NotifyFriendAccepted(other, user)
+    }
+
+    writeJSON(w, http.StatusCreated, map[string]string{
+        "user_id": other.String(),
+        "status":  string(f.Status),
+    })
+}
+
+func (h *Handler) handleFriendAccept(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    user, ok := h.currentUser(w, r)
+    if !ok {
+        return
+    }
+    requesterID, ok := pathUserID(w, r)
+    if !ok {
+        return
+    }
+
+    f, err := h.friends.AcceptRequest(r.Context(), user.ID, requesterID)
+    if err != nil {
+        mapDomainError(w, err)
+        return
+    }
+
+    h.notifier.NotifyFriendAccepted(requesterID, user)
+    writeJSON(w, http.StatusOK, map[string]string{
+        "user_id": requesterID.String(),
+        "status":  string(f.Status),
+    })
+}
+
+func (h *Handler) handleFriendDecline(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    user, ok := h.currentUser(w, r)
+    if !ok {
+        return
+    }
+    requesterID, ok := pathUserID(w, r)
+    if !ok {
+        return
+    }
+
+    if err := h.friends.DeclineRequest(r.Context(), user.ID, requesterID); err != nil {
+        mapDomainError(w, err)
+        return
+    }
+
+    w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) handleFriendRemove(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodDelete {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    user, ok := h.currentUser(w, r)
+    if !ok {
+        return
+    }
+    friendID, ok := pathUserID(w, r)
+    if !ok {
+        return
+    }
+
+    if err := h.friends.RemoveFriend(r.Context(), user.ID, friendID); err != nil {
+        mapDomainError(w, err)
+        return
+    }
+
+    w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) handleFriendChallenge(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    user, ok := h.currentUser(w, r)
+    if !ok {
+        return
+    }
+    friendID, ok := pathUserID(w, r)
+    if !ok {
+        return
+    }
+
+    game, err := h.friends.Challenge(r.Context(), user.ID, friendID)
+    if err != nil {
+        mapDomainError(w, err)
+        return
+    }
+
+    h.notifier.NotifyGameFound(game)
+    writeJSON(w, http.StatusCreated, map[string]interface{}{"game": toGameResponse(game)})
+}
@@ -0,0 +1,210 @@
+﻿package http
+
+import (
+    "context"
+    "encoding/json"
+    "net/http"
+    "time"
+
+    "github.com/google/uuid"
+    "xo-server/internal/domain"
+)
+
+func (h *Handler) handleActiveGames(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    user, ok := h.currentUser(w, r)
+    if !ok {
+        return
+    }
+
+    games, err := h.games.GetActiveGames(r.Context(), user.ID)
+    if err != nil {
+        mapDomainError(w, err)
+        return
+    }
+
+    resp := make([]*gameResponse, 0, len(games))
+    for _, g := range games {
+        resp = append(resp, toGameResponse(g))
+    }
+    writeJSON(w, http.StatusOK, map[string]interface{}{"games": resp})
+}
+
+func (h *Handler) handleGetGame(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    user, ok := h.currentUser(w, r)
+    if !ok {
+        return
+    }
+    gameID, ok := pathGameID(w, r)
+    if !ok {
+        return
+    }
+
+    game, err := h.games.GetGame(r.Context(), gameID)
+    if err != nil {
+        mapDomainError(w, err)
+        return
+    }
+    if user.ID != game.PlayerX && user.ID != game.PlayerO {
+        mapDomainError(w, domain.ErrForbidden)
+        return
+    }
+
+    writeJSON(w, http.StatusOK, map[string]interface{}{"game": toGameResponse(game)})
+}
+
+func (h *Handler) handleChat(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    user, ok := h.currentUser(w, r)
+    if !ok {
+        return
+    }
+    gameID, ok := pathGameID(w, r)
+    if !ok {
+        return
+    }
+
+    var req struct {
+        Message string `json:"message"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid json")
+        return
+    }
+
+    msg, err := h.games.AddChat(r.Context(), user.ID, gameID, req.Message)
+    if err != nil {
+        mapDomainError(w, err)
+        return
+    }
+
+    writeJSON(w, http.StatusCreated, chatResponse{
+        GameID:  msg.GameID.String(),
+        UserID:  msg.UserID.String(),
+        Message: msg.Message,
+        At:      msg.CreatedAt.Format(time.RFC3339),
+    })
+}
+
+func (h *Handler) handleJoinQueue(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    user, ok := h.currentUser(w, r)
+    if !ok {
+        return
+    }
+
+    var req struct {
+        Pool string `json:"pool"`
+    }
+    if r.ContentLength != 0 {
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+            writeError(w, http.StatusBadRequest, "invalid json")
+            return
+        }
+    }
+
+    matched, game, err := h.matchmaking.JoinQueue(user, domain.QueuePool(req.Pool))
+    if err != nil {
+        mapDomainError(w, err)
+        return
+    }
+    if !matched {
+        writeJSON(w, http.StatusAccepted, map[string]string{"status": "waiting"})
+        return
+    }
+
+    h.notifier.NotifyGameFound(game)
+    writeJSON(w, http.StatusCreated, map[string]interface{}{
+        "status": "matched",
+        "game":   toGameResponse(game),
+    })
+}
+
+func (h *Handler) handleMove(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    user, ok := h.currentUser(w, r)
+    if !ok {
+        return
+    }
+    gameID, ok := pathGameID(w, r)
+    if !ok {
+        return
+    }
+
+    var req struct {
+        Position *int `json:"position"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Position == nil {
+        writeError(w, http.StatusBadRequest, "invalid json")
+        return
+    }
+
+    game, err := h.games.MakeMove(r.Context(), user.ID, gameID, *req.Position)
+    if err != nil {
+        mapDomainError(w, err)
+        return
+    }
+
+    writeJSON(w, http.StatusOK, map[string]interface{}{"game": toGameResponse(game)})
+}
+
+func (h *Handler) handleResign(w http.ResponseWriter, r *http.Request) {
+    h.gameAction(w, r, h.games.Resign)
+}
+
+func (h *Handler) handleDrawOffer(w http.ResponseWriter, r *http.Request) {
+    h.gameAction(w, r, h.games.OfferDraw)
+}
+
+func (h *Handler) handleDrawAccept(w http.ResponseWriter, r *http.Request) {
+    h.gameAction(w, r, h.games.AcceptDraw)
+}
+
+func (h *Handler) handleDrawDecline(w http.ResponseWriter, r *http.Request) {
+    h.gameAction(w, r, h.games.DeclineDraw)
+}
+
+func (h *Handler) gameAction(w http.ResponseWriter, r *http.Request, action func(ctx context.Context, userID, gameID uuid.UUID) (*domain.Game, error)) {
+    if r.Method != http.MethodPost {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    user, ok := h.currentUser(w, r)
+    if !ok {
+        return
+    }
+    gameID, ok := pathGameID(w, r)
+    if !ok {
+        return
+    }
+
+    game, err := action(r.Context(), user.ID, gameID)
+    if err != nil {
+        mapDomainError(w, err)
+        return
+    }
+
+    writeJSON(w, http.StatusOK, map[string]interface{}{"game": toGameResponse(game)})
+}
@@ -5,29 +5,116 @@ import (
     "net/http"
     "os"
     "path/filepath"
+    "time"
 
+    "github.com/google/uuid"
+    "xo-server/internal/adapter/auth"
     "xo-server/internal/domain"
     "xo-server/internal/usecase"
 )
 
+type Notifier interface {
+    NotifyGameFound(game *domain.Game)
+    NotifyFriendRequest(to uuid.UUID, from *domain.User)
+    NotifyFriendAccepted(to uuid.UUID, by *domain.User)
+}
+
 type Handler struct {
-    auth usecase.AuthService
+    auth         usecase.AuthService
+    authn        *auth.Middleware
+    games        usecase.GameService
+    matchmaking  usecase.MatchmakingService
+    friends      usecase.FriendService
+    reports      usecase.ReportService
+    audit        usecase.AuditService
+    accounts     usecase.AccountService
+    stats        usecase.StatsService
+    leaderboard  usecase.LeaderboardService
+    achievements usecase.AchievementService
+    seasons      usecase.SeasonService
+    external     usecase.ExternalLoginService
+    notifier     Notifier
+    opts         Options
+}
+
+type Options struct {
+    PostLoginRedirect string
+    TrustForwardedFor bool
 }
 
-func NewHandler(auth usecase.AuthService) *Handler {
-    return &Handler{auth: auth}
+func NewHandler(authSvc usecase.AuthService, authn *auth.Middleware, games usecase.GameService, matchmaking usecase.MatchmakingService, friends usecase.FriendService, reports usecase.ReportService, audit usecase.AuditService, accounts usecase.AccountService, stats usecase.StatsService, leaderboard usecase.LeaderboardService, achievements usecase.AchievementService, seasons usecase.SeasonService, external usecase.ExternalLoginService, notifier Notifier, opts Options) *Handler {
+    return &Handler{auth: authSvc, authn: authn, games: games, matchmaking: matchmaking, friends: friends, reports: reports, audit: audit, accounts: accounts, stats: stats, leaderboard: leaderboard, achievements: achievements, seasons: seasons, external: external, notifier: notifier, opts: opts}
 }
 
 func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
     mux.HandleFunc("/health", h.handleHealth)
     mux.HandleFunc("/api/register", h.handleRegister)
     mux.HandleFunc("/api/login", h.handleLogin)
+    mux.HandleFunc("/api/login/2fa", h.handleLoginTwoFactor)
+    mux.HandleFunc("/api/guest", h.handleGuest)
+    mux.HandleFunc("/api/guest/upgrade", h.authn.Require(h.handleGuestUpgrade))
+    mux.HandleFunc("/api/refresh", h.handleRefresh)
+    mux.HandleFunc("/api/logout", h.authn.Require(h.handleLogout))
+    mux.HandleFunc("/api/password", h.authn.Require(h.handleChangePassword))
+    mux.HandleFunc("/api/password/reset/request", h.handleRequestPasswordReset)
+    mux.HandleFunc("/api/password/reset", h.handleResetPassword)
+    mux.HandleFunc("/api/account", h.authn.Require(h.handleDeleteAccount))
+    mux.HandleFunc("/api/account/export", h.authn.Require(h.handleAccountExport))
+    mux.HandleFunc("/api/2fa/totp/enroll", h.authn.Require(h.handleTOTPEnroll))
+    mux.HandleFunc("/api/2fa/totp/activate", h.authn.Require(h.handleTOTPActivate))
+    mux.HandleFunc("/api/2fa/totp/disable", h.authn.Require(h.handleTOTPDisable))
+    mux.HandleFunc("/api/auth/providers", h.handleAuthProviders)
+    mux.HandleFunc("/api/auth/identities", h.authn.Require(h.handleIdentities))
+    mux.HandleFunc("/api/auth/oidc/{provider}/login", h.handleOIDCLogin)
+    mux.HandleFunc("/api/auth/oidc/{provider}/link", h.authn.Require(h.handleOIDCLink))
+    mux.HandleFunc("/api/auth/oidc/{provider}/callback", h.handleOIDCCallback)
+    mux.HandleFunc("/api/queue", h.authn.RequirePermission(domain.PermPlay, h.handleJoinQueue))
+    mux.HandleFunc("/api/profiles/{username}", h.handleProfile)
+    mux.HandleFunc("/api/profiles/{username}/vs/{opponent}", h.handleHeadToHead)
+    mux.HandleFunc("/api/profiles/{username}/achievements", h.handleAchievements)
+    mux.HandleFunc("/api/leaderboards", h.handleLeaderboard)
+    mux.HandleFunc("/api/seasons", h.handleSeasons)
+    mux.HandleFunc("/api/seasons/current", h.handleCurrentSeason)
+    mux.HandleFunc("/api/seasons/{id}/standings", h.handleSeasonStandings)
+    mux.HandleFunc("/api/games", h.authn.Require(h.handleActiveGames))
+    mux.HandleFunc("/api/games/{id}", h.authn.Require(h.handleGetGame))
+    mux.HandleFunc("/api/games/{id}/chat", h.authn.RequirePermission(domain.PermChat, h.handleChat))
+    mux.HandleFunc("/api/games/{id}/move", h.authn.RequirePermission(domain.PermPlay, h.handleMove))
+    mux.HandleFunc("/api/games/{id}/resign", h.authn.RequirePermission(domain.PermPlay, h.handleResign))
+    mux.HandleFunc("/api/games/{id}/draw/offer", h.authn.RequirePermission(domain.PermPlay, h.handleDrawOffer))
+    mux.HandleFunc("/api/games/{id}/draw/accept", h.authn.RequirePermission(domain.PermPlay, h.handleDrawAccept))
+    mux.HandleFunc("/api/games/{id}/draw/decline", h.authn.RequirePermission(domain.PermPlay, h.handleDrawDecline))
+    mux.HandleFunc("/api/friends", h.authn.RequirePermission(domain.PermSocial, h.handleFriends))
+    mux.HandleFunc("/api/friends/requests", h.authn.RequirePermission(domain.PermSocial, h.handleFriendRequests))
+    mux.HandleFunc("/api/friends/requests/{id}/accept", h.authn.RequirePermission(domain.PermSocial, h.handleFriendAccept))
+    mux.HandleFunc("/api/friends/requests/{id}/decline", h.authn.RequirePermission(domain.PermSocial, h.handleFriendDecline))
+    mux.HandleFunc("/api/friends/{id}", h.authn.RequirePermission(domain.PermSocial, h.handleFriendRemove))
+    mux.HandleFunc("/api/friends/{id}/challenge", h.authn.RequirePermission(domain.PermSocial, h.handleFriendChallenge))
+    mux.HandleFunc("/api/reports", h.authn.RequirePermission(domain.PermSocial, h.handleCreateReport))
+    mux.HandleFunc("/api/admin/users/{id}/role", h.authn.RequirePermission(domain.PermManageUsers, h.handleSetRole))
+    mux.HandleFunc("/api/admin/users/{id}/suspend", h.authn.RequirePermission(domain.PermManageUsers, h.handleSuspendUser))
+    mux.HandleFunc("/api/admin/users/{id}/unsuspend", h.authn.RequirePermission(domain.PermManageUsers, h.handleUnsuspendUser))
+    mux.HandleFunc("/api/admin/games", h.authn.RequirePermission(domain.PermModerate, h.handleAdminGames))
+    mux.HandleFunc("/api/admin/games/{id}/finish", h.authn.RequirePermission(domain.PermManageGames, h.handleAdminFinishGame))
+    mux.HandleFunc("/api/admin/games/{id}/abort", h.authn.RequirePermission(domain.PermManageGames, h.handleAdminAbortGame))
+    mux.HandleFunc("/api/admin/games/{id}/result", h.authn.RequirePermission(domain.PermManageGames, h.handleAdminAdjustResult))
+    mux.HandleFunc("/api/admin/audit", h.authn.RequirePermission(domain.PermAudit, h.handleAuditLog))
+    mux.HandleFunc("/api/admin/seasons/open", h.authn.RequirePermission(domain.PermManageGames, h.handleOpenSeason))
+    mux.HandleFunc("/api/admin/seasons/close", h.authn.RequirePermission(domain.PermManageGames, h.handleCloseSeason))
+    mux.HandleFunc("/api/admin/reports", h.authn.RequirePermission(domain.PermModerate, h.handleAdminReports))
+    mux.HandleFunc("/api/admin/reports/{id}/resolve", h.authn.RequirePermission(domain.PermModerate, h.handleResolveReport))
     mux.HandleFunc("/docs", h.handleSwaggerUI)
     mux.HandleFunc("/openapi.yaml", h.handleOpenAPI)
     swaggerDir := filepath.Join("docs", "swagger-ui")
     mux.Handle("/swagger-ui/", http.StripPrefix("/swagger-ui/", http.FileServer(http.Dir(swaggerDir))))
 }
 
+func (h *Handler) WithClientIP(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        next.ServeHTTP(w, r.WithContext(usecase.WithClientIP(r.Context(), h.clientIP(r))))
+    })
+}
+
 func (h *Handler) handleHealth(w http.ResponseWriter, r *http.Request) {
     if r.Method != http.MethodGet {
         w.WriteHeader(http.StatusMethodNotAllowed)
@@ -75,29 +162,170 @@ func (h *Handler) handleLogin(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    token, user, err := h.auth.Login(r.Context(), req.Username, req.Password)
+    result, err := h.auth.Login(r.Context(), req.Username, req.Password)
+    if err != nil {
+        mapDomainError(w, err)
+        return
+    }
+
+    if result.Tokens == nil {
+        writeJSON(w, http.StatusOK, map[string]interface{}{
+            "two_factor_required": true,
+            "challenge_token":     result.ChallengeToken,
+            "expires_at":          result.ChallengeExpiresAt.Format(time.RFC3339),
+        })
+        return
+    }
+    h.writeTokens(w, r, result.Tokens, result.User)
+}
+
+func (h *Handler) handleGuest(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    pair, user, err := h.auth.CreateGuest(r.Context())
     if err != nil {
         mapDomainError(w, err)
         return
     }
+    h.writeTokens(w, r, pair, user)
+}
+
+func (h *Handler) handleGuestUpgrade(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    user, ok := h.currentUser(w, r)
+    if !ok {
+        return
+    }
+
+    var req struct {
+        Username string `json:"username"`
+        Password string `json:"password"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid json")
+        return
+    }
 
+    sessionID := auth.SessionIDFromContext(r.Context())
+    pair, upgraded, err := h.auth.UpgradeGuest(r.Context(), user.ID, sessionID, req.Username, req.Password)
+    if err != nil {
+        mapDomainError(w, err)
+        return
+    }
+    h.writeTokens(w, r, pair, upgraded)
+}
+
+func (h *Handler) handleRefresh(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    var req struct {
+        RefreshToken string `json:"refresh_token"`
+    }
+    if r.ContentLength != 0 {
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+            writeError(w, http.StatusBadRequest, "invalid json")
+            return
+        }
+    }
+    if req.RefreshToken == "" {
+        if cookie, err := r.Cookie(h.authn.RefreshCookieName()); err == nil {
+            req.RefreshToken = cookie.Value
+        }
+    }
+
+    pair, user, err := h.auth.Refresh(r.Context(), req.RefreshToken)
+    if err != nil {
+        h.authn.ClearCookies(w, r)
+        mapDomainError(w, err)
+        return
+    }
+
+    h.writeTokens(w, r, pair, user)
+}
+
+func (h *Handler) handleLogout(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    user, ok := h.currentUser(w, r)
+    if !ok {
+        return
+    }
+
+    if err := h.auth.Logout(r.Context(), user.ID, auth.SessionIDFromContext(r.Context())); err != nil {
+        mapDomainError(w, err)
+        return
+    }
+
+    h.authn.ClearCookies(w, r)
+    w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) writeTokens(w http.ResponseWriter, r *http.Request, pair *domain.TokenPair, user *domain.User) {
+    h.authn.SetCookie(w, r, pair.AccessToken)
+    h.authn.SetRefreshCookie(w, r, pair.RefreshToken)
     writeJSON(w, http.StatusOK, map[string]string{
-        "token":  token,
-        "user_id": user.ID.String(),
-        "username": user.Username,
+        "token":         pair.AccessToken,
+        "refresh_token": pair.RefreshToken,
+        "user_id":       user.ID.String(),
+        "username":      user.Username,
+        "role":          string(user.Role),
     })
 }
 
+func (h *Handler) currentUser(w http.ResponseWriter, r *http.Request) (*domain.User, bool) {
+    user, ok := auth.UserFromContext(r.Context())
+    if !ok {
+        writeError(w, http.StatusUnauthorized, "missing token")
+        return nil, false
+    }
+    return user, true
+}
+
+func pathUserID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+    id, err := uuid.Parse(r.PathValue("id"))
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "invalid user id")
+        return uuid.UUID{}, false
+    }
+    return id, true
+}
+
+func pathGameID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+    id, err := uuid.Parse(r.PathValue("id"))
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "invalid game id")
+        return uuid.UUID{}, false
+    }
+    return id, true
+}
+
 func mapDomainError(w http.ResponseWriter, err error) {
     switch err {
-    case domain.ErrInvalidInput:
+    case domain.ErrInvalidInput, domain.ErrInvalidPosition:
         writeError(w, http.StatusBadRequest, err.Error())
     case domain.ErrUnauthorized:
         writeError(w, http.StatusUnauthorized, err.Error())
-    case domain.ErrForbidden:
+    case domain.ErrForbidden, domain.ErrNotFriends, domain.ErrGuestNotAllowed, domain.ErrAccountSuspended, domain.ErrReauthRequired:
         writeError(w, http.StatusForbidden, err.Error())
     case domain.ErrNotFound:
         writeError(w, http.StatusNotFound, err.Error())
+    case domain.ErrTooManyAttempts:
+        writeError(w, http.StatusTooManyRequests, err.Error())
+    case domain.ErrGameNotActive, domain.ErrNotYourTurn, domain.ErrPositionTaken, domain.ErrAlreadyInQueue, domain.ErrDrawNotOffered, domain.ErrIdentityLinked, domain.ErrTwoFactorActive, domain.ErrActiveGames, domain.ErrSeasonOpen:
+        writeError(w, http.StatusConflict, err.Error())
     default:
         writeError(w, http.StatusInternalServerError, "internal error")
     }
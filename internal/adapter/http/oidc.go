@@ -0,0 +1,113 @@
+﻿package http
+
+import (
+    "net/http"
+    "time"
+
+    "github.com/google/uuid"
+)
+
+type identityResponse struct {
+    Provider  string `json:"provider"`
+    Subject   string `json:"subject"`
+    Email     string `json:"email"`
+    CreatedAt string `json:"created_at"`
+}
+
+func (h *Handler) handleAuthProviders(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    writeJSON(w, http.StatusOK, map[string]interface{}{"providers": h.external.Providers()})
+}
+
+func (h *Handler) handleIdentities(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    user, ok := h.currentUser(w, r)
+    if !ok {
+        return
+    }
+
+    identities, err := h.external.ListIdentities(r.Context(), user.ID)
+    if err != nil {
+        mapDomainError(w, err)
+        return
+    }
+
+    resp := make([]identityResponse, 0, len(identities))
+    for _, i := range identities {
+        resp = append(resp, identityResponse{
+            Provider:  i.Provider,
+            Subject:   i.Subject,
+            Email:     i.Email,
+            CreatedAt: i.CreatedAt.Format(time.RFC3339),
+        })
+    }
+    writeJSON(w, http.StatusOK, map[string]interface{}{"identities": resp})
+}
+
+func (h *Handler) handleOIDCLogin(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    url, err := h.external.Begin(r.Context(), r.PathValue("provider"), uuid.Nil)
+    if err != nil {
+        mapDomainError(w, err)
+        return
+    }
+    http.Redirect(w, r, url, http.StatusFound)
+}
+
+func (h *Handler) handleOIDCLink(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    user, ok := h.currentUser(w, r)
+    if !ok {
+        return
+    }
+
+    url, err := h.external.Begin(r.Context(), r.PathValue("provider"), user.ID)
+    if err != nil {
+        mapDomainError(w, err)
+        return
+    }
+    writeJSON(w, http.StatusOK, map[string]string{"url": url})
+}
+
+func (h *Handler) handleOIDCCallback(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    q := r.URL.Query()
+    if q.Get("error") != "" {
+        writeError(w, http.StatusUnauthorized, q.Get("error"))
+        return
+    }
+
+    pair, user, err := h.external.Complete(r.Context(), r.PathValue("provider"), q.Get("state"), q.Get("code"))
+    if err != nil {
+        mapDomainError(w, err)
+        return
+    }
+
+    if h.opts.PostLoginRedirect != "" {
+        h.authn.SetCookie(w, r, pair.AccessToken)
+        h.authn.SetRefreshCookie(w, r, pair.RefreshToken)
+        http.Redirect(w, r, h.opts.PostLoginRedirect, http.StatusFound)
+        return
+    }
+    h.writeTokens(w, r, pair, user)
+}
@@ -0,0 +1,95 @@
+﻿package http
+
+import (
+    "encoding/json"
+    "net"
+    "net/http"
+    "strings"
+
+    "xo-server/internal/adapter/auth"
+)
+
+func (h *Handler) handleChangePassword(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    user, ok := h.currentUser(w, r)
+    if !ok {
+        return
+    }
+
+    var req struct {
+        CurrentPassword string `json:"current_password"`
+        NewPassword     string `json:"new_password"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid json")
+        return
+    }
+
+    sessionID := auth.SessionIDFromContext(r.Context())
+    if err := h.auth.ChangePassword(r.Context(), user.ID, sessionID, req.CurrentPassword, req.NewPassword); err != nil {
+        mapDomainError(w, err)
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) handleRequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    var req struct {
+        Username string `json:"username"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid json")
+        return
+    }
+
+    if err := h.auth.RequestPasswordReset(r.Context(), req.Username); err != nil {
+        mapDomainError(w, err)
+        return
+    }
+    w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *Handler) handleResetPassword(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    var req struct {
+        Token       string `json:"token"`
+        NewPassword string `json:"new_password"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid json")
+        return
+    }
+
+    if err := h.auth.ResetPassword(r.Context(), req.Token, req.NewPassword); err != nil {
+        mapDomainError(w, err)
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) clientIP(r *http.Request) string {
+    if h.opts.TrustForwardedFor {
+        if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+            first, _, _ := strings.Cut(fwd, ",")
+            return strings.TrimSpace(first)
+        }
+    }
+    host, _, err := net.SplitHostPort(r.RemoteAddr)
+    if err != nil {
+        return r.RemoteAddr
+    }
+    return host
+}
@@ -0,0 +1,265 @@
+﻿package http
+
+import (
+    "net/http"
+    "strconv"
+    "time"
+
+    "xo-server/internal/domain"
+)
+
+type profileResponse struct {
+    UserID      string                    `json:"user_id"`
+    Username    string                    `json:"username"`
+    Guest       bool                      `json:"guest"`
+    JoinedAt    string                    `json:"joined_at"`
+    Ratings     map[string]map[string]ratingResponse `json:"ratings"`
+    Stats       statsResponse             `json:"stats"`
+    RecentGames []*gameResponse           `json:"recent_games"`
+}
+
+type ratingResponse struct {
+    Rating int `json:"rating"`
+    Games  int `json:"games"`
+}
+
+type achievementResponse struct {
+    Code        string  `json:"code"`
+    Name        string  `json:"name"`
+    Description string  `json:"description"`
+    GameID      *string `json:"game_id,omitempty"`
+    UnlockedAt  string  `json:"unlocked_at"`
+}
+
+type headToHeadResponse struct {
+    User     playerRef       `json:"user"`
+    Opponent playerRef       `json:"opponent"`
+    Total    recordResponse  `json:"total"`
+    AsX      recordResponse  `json:"as_x"`
+    AsO      recordResponse  `json:"as_o"`
+    Games    []*gameResponse `json:"games"`
+}
+
+type playerRef struct {
+    UserID   string `json:"user_id"`
+    Username string `json:"username"`
+}
+
+type recordResponse struct {
+    Games  int `json:"games"`
+    Wins   int `json:"wins"`
+    Losses int `json:"losses"`
+    Draws  int `json:"draws"`
+}
+
+type leaderboardResponse struct {
+    Variant     string                     `json:"variant"`
+    TimeControl string                     `json:"time_control"`
+    Period      string                     `json:"period"`
+    Since       *string                    `json:"since,omitempty"`
+    MinGames    int                        `json:"min_games"`
+    Total       int                        `json:"total"`
+    UpdatedAt   string                     `json:"updated_at"`
+    Entries     []leaderboardEntryResponse `json:"entries"`
+}
+
+type leaderboardEntryResponse struct {
+    Rank     int      `json:"rank"`
+    UserID   string   `json:"user_id"`
+    Username string   `json:"username"`
+    Rating   int      `json:"rating"`
+    Games    int      `json:"games"`
+    Wins     *int     `json:"wins,omitempty"`
+    Draws    *int     `json:"draws,omitempty"`
+    Losses   *int     `json:"losses,omitempty"`
+    Points   *float64 `json:"points,omitempty"`
+}
+
+type statsResponse struct {
+    Games         int     `json:"games"`
+    Wins          int     `json:"wins"`
+    Losses        int     `json:"losses"`
+    Draws         int     `json:"draws"`
+    GamesAsX      int     `json:"games_as_x"`
+    GamesAsO      int     `json:"games_as_o"`
+    WinRateX      float64 `json:"win_rate_x"`
+    WinRateO      float64 `json:"win_rate_o"`
+    CurrentStreak int     `json:"current_streak"`
+    BestStreak    int     `json:"best_streak"`
+}
+
+func (h *Handler) handleProfile(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    recent, _ := strconv.Atoi(r.URL.Query().Get("recent"))
+    profile, err := h.stats.Profile(r.Context(), r.PathValue("username"), recent)
+    if err != nil {
+        mapDomainError(w, err)
+        return
+    }
+
+    writeJSON(w, http.StatusOK, toProfileResponse(profile))
+}
+
+func (h *Handler) handleHeadToHead(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+    h2h, err := h.stats.HeadToHead(r.Context(), r.PathValue("username"), r.PathValue("opponent"), limit)
+    if err != nil {
+        mapDomainError(w, err)
+        return
+    }
+
+    writeJSON(w, http.StatusOK, toHeadToHeadResponse(h2h))
+}
+
+func (h *Handler) handleAchievements(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    list, err := h.achievements.ListAchievements(r.Context(), r.PathValue("username"))
+    if err != nil {
+        mapDomainError(w, err)
+        return
+    }
+
+    resp := make([]achievementResponse, 0, len(list))
+    for _, a := range list {
+        info, _ := domain.AchievementByCode(a.Code)
+        item := achievementResponse{
+            Code:        string(a.Code),
+            Name:        info.Name,
+            Description: info.Description,
+            UnlockedAt:  a.UnlockedAt.Format(time.RFC3339),
+        }
+        if a.GameID != nil {
+            gameID := a.GameID.String()
+            item.GameID = &gameID
+        }
+        resp = append(resp, item)
+    }
+    writeJSON(w, http.StatusOK, map[string]interface{}{"achievements": resp})
+}
+
+func (h *Handler) handleLeaderboard(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    q := r.URL.Query()
+    query := domain.LeaderboardQuery{
+        Variant:     q.Get("variant"),
+        TimeControl: q.Get("time_control"),
+        Period:      domain.LeaderboardPeriod(q.Get("period")),
+    }
+    query.Limit, _ = strconv.Atoi(q.Get("limit"))
+    query.Offset, _ = strconv.Atoi(q.Get("offset"))
+
+    board, err := h.leaderboard.Leaderboard(r.Context(), query)
+    if err != nil {
+        mapDomainError(w, err)
+        return
+    }
+
+    writeJSON(w, http.StatusOK, toLeaderboardResponse(board))
+}
+
+func toProfileResponse(p *domain.Profile) *profileResponse {
+    resp := &profileResponse{
+        UserID:   p.User.ID.String(),
+        Username: p.User.Username,
+        Guest:    p.User.IsGuest,
+        JoinedAt: p.User.CreatedAt.Format(time.RFC3339),
+        Ratings:  make(map[string]map[string]ratingResponse, len(p.Ratings)),
+        Stats: statsResponse{
+            Games:         p.Stats.Games(),
+            Wins:          p.Stats.Wins,
+            Losses:        p.Stats.Losses,
+            Draws:         p.Stats.Draws,
+            GamesAsX:      p.Stats.GamesAsX,
+            GamesAsO:      p.Stats.GamesAsO,
+            WinRateX:      winRate(p.Stats.WinsAsX, p.Stats.GamesAsX),
+            WinRateO:      winRate(p.Stats.WinsAsO, p.Stats.GamesAsO),
+            CurrentStreak: p.Stats.CurrentStreak,
+            BestStreak:    p.Stats.BestStreak,
+        },
+        RecentGames: make([]*gameResponse, 0, len(p.RecentGames)),
+    }
+    for _, rating := range p.Ratings {
+        if resp.Ratings[rating.Variant] == nil {
+            resp.Ratings[rating.Variant] = make(map[string]ratingResponse)
+        }
+        resp.Ratings[rating.Variant][rating.TimeControl] = ratingResponse{Rating: rating.Rating, Games: rating.Games}
+    }
+    for _, g := range p.RecentGames {
+        resp.RecentGames = append(resp.RecentGames, toGameResponse(g))
+    }
+    return resp
+}
+
+func winRate(wins, games int) float64 {
+    if games == 0 {
+        return 0
+    }
+    return float64(wins) / float64(games)
+}
+
+func toHeadToHeadResponse(h2h *domain.HeadToHead) *headToHeadResponse {
+    resp := &headToHeadResponse{
+        User:     playerRef{UserID: h2h.User.ID.String(), Username: h2h.User.Username},
+        Opponent: playerRef{UserID: h2h.Opponent.ID.String(), Username: h2h.Opponent.Username},
+        Total:    toRecordResponse(h2h.Total),
+        AsX:      toRecordResponse(h2h.AsX),
+        AsO:      toRecordResponse(h2h.AsO),
+        Games:    make([]*gameResponse, 0, len(h2h.Games)),
+    }
+    for _, g := range h2h.Games {
+        resp.Games = append(resp.Games, toGameResponse(g))
+    }
+    return resp
+}
+
+func toRecordResponse(r domain.ColourRecord) recordResponse {
+    return recordResponse{Games: r.Games(), Wins: r.Wins, Losses: r.Losses, Draws: r.Draws}
+}
+
+func toLeaderboardResponse(b *domain.Leaderboard) *leaderboardResponse {
+    resp := &leaderboardResponse{
+        Variant:     b.Variant,
+        TimeControl: b.TimeControl,
+        Period:      string(b.Period),
+        MinGames:    b.MinGames,
+        Total:       b.Total,
+        UpdatedAt:   b.UpdatedAt.Format(time.RFC3339),
+        Entries:     make([]leaderboardEntryResponse, 0, len(b.Entries)),
+    }
+    if b.Since != nil {
+        since := b.Since.Format(time.RFC3339)
+        resp.Since = &since
+    }
+    for _, e := range b.Entries {
+        entry := leaderboardEntryResponse{
+            Rank:     e.Rank,
+            UserID:   e.UserID.String(),
+            Username: e.Username,
+            Rating:   e.Rating,
+            Games:    e.Games,
+        }
+        if b.Since != nil {
+            wins, draws, losses, points := e.Wins, e.Draws, e.Losses, e.Points()
+            entry.Wins, entry.Draws, entry.Losses, entry.Points = &wins, &draws, &losses, &points
+        }
+        resp.Entries = append(resp.Entries, entry)
+    }
+    return resp
+}
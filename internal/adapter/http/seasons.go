@@ -0,0 +1,171 @@
+﻿package http
+
+import (
+    "encoding/json"
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/google/uuid"
+    "xo-server/internal/domain"
+)
+
+type seasonResponse struct {
+    ID       string  `json:"id"`
+    Name     string  `json:"name"`
+    Status   string  `json:"status"`
+    StartsAt string  `json:"starts_at"`
+    EndsAt   *string `json:"ends_at,omitempty"`
+    ClosedAt *string `json:"closed_at,omitempty"`
+}
+
+type seasonStandingResponse struct {
+    Rank     int    `json:"rank"`
+    UserID   string `json:"user_id"`
+    Username string `json:"username"`
+    Rating   int    `json:"rating"`
+    Games    int    `json:"games"`
+    Wins     int    `json:"wins"`
+    Draws    int    `json:"draws"`
+    Losses   int    `json:"losses"`
+    Reward   string `json:"reward,omitempty"`
+}
+
+func (h *Handler) handleSeasons(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    seasons, err := h.seasons.ListSeasons(r.Context())
+    if err != nil {
+        mapDomainError(w, err)
+        return
+    }
+
+    resp := make([]*seasonResponse, 0, len(seasons))
+    for _, s := range seasons {
+        resp = append(resp, toSeasonResponse(s))
+    }
+    writeJSON(w, http.StatusOK, map[string]interface{}{"seasons": resp})
+}
+
+func (h *Handler) handleCurrentSeason(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    season, err := h.seasons.Current(r.Context())
+    if err != nil {
+        mapDomainError(w, err)
+        return
+    }
+    writeJSON(w, http.StatusOK, map[string]interface{}{"season": toSeasonResponse(season)})
+}
+
+func (h *Handler) handleSeasonStandings(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    seasonID, err := uuid.Parse(r.PathValue("id"))
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "invalid season id")
+        return
+    }
+    q := r.URL.Query()
+    limit, _ := strconv.Atoi(q.Get("limit"))
+    offset, _ := strconv.Atoi(q.Get("offset"))
+
+    standings, err := h.seasons.Standings(r.Context(), seasonID, limit, offset)
+    if err != nil {
+        mapDomainError(w, err)
+        return
+    }
+
+    entries := make([]seasonStandingResponse, 0, len(standings.Entries))
+    for _, st := range standings.Entries {
+        entries = append(entries, seasonStandingResponse{
+            Rank:     st.Rank,
+            UserID:   st.UserID.String(),
+            Username: st.Username,
+            Rating:   st.Rating,
+            Games:    st.Games,
+            Wins:     st.Wins,
+            Draws:    st.Draws,
+            Losses:   st.Losses,
+            Reward:   st.Reward,
+        })
+    }
+    writeJSON(w, http.StatusOK, map[string]interface{}{
+        "season":  toSeasonResponse(standings.Season),
+        "total":   standings.Total,
+        "entries": entries,
+    })
+}
+
+func (h *Handler) handleOpenSeason(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    actor, ok := h.currentUser(w, r)
+    if !ok {
+        return
+    }
+
+    var req struct {
+        Name string `json:"name"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid json")
+        return
+    }
+
+    season, err := h.seasons.OpenSeason(r.Context(), actor, req.Name)
+    if err != nil {
+        mapDomainError(w, err)
+        return
+    }
+    writeJSON(w, http.StatusCreated, map[string]interface{}{"season": toSeasonResponse(season)})
+}
+
+func (h *Handler) handleCloseSeason(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    actor, ok := h.currentUser(w, r)
+    if !ok {
+        return
+    }
+
+    season, err := h.seasons.CloseSeason(r.Context(), actor)
+    if err != nil {
+        mapDomainError(w, err)
+        return
+    }
+    writeJSON(w, http.StatusOK, map[string]interface{}{"season": toSeasonResponse(season)})
+}
+
+func toSeasonResponse(s *domain.Season) *seasonResponse {
+    resp := &seasonResponse{
+        ID:       s.ID.String(),
+        Name:     s.Name,
+        Status:   string(s.Status),
+        StartsAt: s.StartsAt.Format(time.RFC3339),
+    }
+    if s.EndsAt != nil {
+        endsAt := s.EndsAt.Format(time.RFC3339)
+        resp.EndsAt = &endsAt
+    }
+    if s.ClosedAt != nil {
+        closedAt := s.ClosedAt.Format(time.RFC3339)
+        resp.ClosedAt = &closedAt
+    }
+    return resp
+}
@@ -0,0 +1,105 @@
+﻿package http
+
+import (
+    "encoding/json"
+    "net/http"
+)
+
+func (h *Handler) handleLoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    var req struct {
+        ChallengeToken string `json:"challenge_token"`
+        Code           string `json:"code"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid json")
+        return
+    }
+
+    pair, user, err := h.auth.VerifyTwoFactor(r.Context(), req.ChallengeToken, req.Code)
+    if err != nil {
+        mapDomainError(w, err)
+        return
+    }
+    h.writeTokens(w, r, pair, user)
+}
+
+func (h *Handler) handleTOTPEnroll(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    user, ok := h.currentUser(w, r)
+    if !ok {
+        return
+    }
+
+    enrollment, err := h.auth.EnrollTOTP(r.Context(), user.ID)
+    if err != nil {
+        mapDomainError(w, err)
+        return
+    }
+    writeJSON(w, http.StatusOK, map[string]string{
+        "secret":      enrollment.Secret,
+        "otpauth_uri": enrollment.URI,
+    })
+}
+
+func (h *Handler) handleTOTPActivate(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    user, ok := h.currentUser(w, r)
+    if !ok {
+        return
+    }
+
+    var req struct {
+        Code string `json:"code"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid json")
+        return
+    }
+
+    codes, err := h.auth.ActivateTOTP(r.Context(), user.ID, req.Code)
+    if err != nil {
+        mapDomainError(w, err)
+        return
+    }
+    writeJSON(w, http.StatusOK, map[string]interface{}{"recovery_codes": codes})
+}
+
+func (h *Handler) handleTOTPDisable(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    user, ok := h.currentUser(w, r)
+    if !ok {
+        return
+    }
+
+    var req struct {
+        Password string `json:"password"`
+        Code     string `json:"code"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid json")
+        return
+    }
+
+    if err := h.auth.DisableTOTP(r.Context(), user.ID, req.Password, req.Code); err != nil {
+        mapDomainError(w, err)
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
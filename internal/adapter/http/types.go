@@ -0,0 +1,151 @@
+﻿package http
+
+import (
+    "time"
+
+    "xo-server/internal/domain"
+)
+
+type gameResponse struct {
+    ID            string  `json:"id"`
+    PlayerX       string  `json:"player_x"`
+    PlayerO       string  `json:"player_o"`
+    Board         string  `json:"board"`
+    NextTurn      string  `json:"next_turn"`
+    Status        string  `json:"status"`
+    WinnerUserID  *string `json:"winner_user_id"`
+    DrawOfferedBy *string `json:"draw_offered_by"`
+    Rated         bool    `json:"rated"`
+    EndReason     string  `json:"end_reason,omitempty"`
+}
+
+type chatResponse struct {
+    GameID  string `json:"game_id"`
+    UserID  string `json:"user_id"`
+    Message string `json:"message"`
+    At      string `json:"at"`
+}
+
+type friendResponse struct {
+    UserID   string `json:"user_id"`
+    Username string `json:"username"`
+    Since    string `json:"since"`
+    Online   bool   `json:"online"`
+}
+
+type friendRequestResponse struct {
+    UserID    string `json:"user_id"`
+    Username  string `json:"username"`
+    Incoming  bool   `json:"incoming"`
+    CreatedAt string `json:"created_at"`
+}
+
+type reportResponse struct {
+    ID         string  `json:"id"`
+    ReporterID string  `json:"reporter_id"`
+    UserID     string  `json:"user_id"`
+    GameID     *string `json:"game_id"`
+    Reason     string  `json:"reason"`
+    Status     string  `json:"status"`
+    Resolution string  `json:"resolution,omitempty"`
+    ResolvedBy *string `json:"resolved_by,omitempty"`
+    CreatedAt  string  `json:"created_at"`
+    ResolvedAt *string `json:"resolved_at,omitempty"`
+}
+
+type auditEventResponse struct {
+    ID        string  `json:"id"`
+    Action    string  `json:"action"`
+    ActorID   *string `json:"actor_id"`
+    TargetID  *string `json:"target_id"`
+    IP        string  `json:"ip"`
+    Detail    string  `json:"detail"`
+    CreatedAt string  `json:"created_at"`
+}
+
+func toGameResponse(game *domain.Game) *gameResponse {
+    var winner *string
+    if game.WinnerUserID != nil {
+        w := game.WinnerUserID.String()
+        winner = &w
+    }
+    var drawBy *string
+    if game.DrawOfferedBy != nil {
+        d := game.DrawOfferedBy.String()
+        drawBy = &d
+    }
+    return &gameResponse{
+        ID:            game.ID.String(),
+        PlayerX:       game.PlayerX.String(),
+        PlayerO:       game.PlayerO.String(),
+        Board:         domain.BoardToString(game.Board),
+        NextTurn:      game.NextTurn,
+        Status:        string(game.Status),
+        WinnerUserID:  winner,
+        DrawOfferedBy: drawBy,
+        Rated:         game.Rated,
+        EndReason:     game.EndReason,
+    }
+}
+
+func toFriendResponse(f *domain.Friend) friendResponse {
+    return friendResponse{
+        UserID:   f.UserID.String(),
+        Username: f.Username,
+        Since:    f.Since.Format(time.RFC3339),
+        Online:   f.Online,
+    }
+}
+
+func toFriendRequestResponse(fr *domain.FriendRequest) friendRequestResponse {
+    return friendRequestResponse{
+        UserID:    fr.UserID.String(),
+        Username:  fr.Username,
+        Incoming:  fr.Incoming,
+        CreatedAt: fr.CreatedAt.Format(time.RFC3339),
+    }
+}
+
+func toReportResponse(rep *domain.Report) reportResponse {
+    resp := reportResponse{
+        ID:         rep.ID.String(),
+        ReporterID: rep.ReporterID.String(),
+        UserID:     rep.UserID.String(),
+        Reason:     rep.Reason,
+        Status:     string(rep.Status),
+        Resolution: rep.Resolution,
+        CreatedAt:  rep.CreatedAt.Format(time.RFC3339),
+    }
+    if rep.GameID != nil {
+        g := rep.GameID.String()
+        resp.GameID = &g
+    }
+    if rep.ResolvedBy != nil {
+        b := rep.ResolvedBy.String()
+        resp.ResolvedBy = &b
+    }
+    if rep.ResolvedAt != nil {
+        at := rep.ResolvedAt.Format(time.RFC3339)
+        resp.ResolvedAt = &at
+    }
+    return resp
+}
+
+func toAuditEventResponse(e *domain.AuditEvent) auditEventResponse {
+    resp := auditEventResponse{
+        ID:        e.ID.String(),
+        Action:    string(e.Action),
+        IP:        e.IP,
+        Detail:    e.Detail,
+        CreatedAt: e.CreatedAt.Format(time.RFC3339),
+    }
+    if e.ActorID != nil {
+        a := e.ActorID.String()
+        resp.ActorID = &a
+    }
+    if e.TargetID != nil {
+        t := e.TargetID.String()
+        resp.TargetID = &t
+    }
+    return resp
+}
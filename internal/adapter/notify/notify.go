@@ -0,0 +1,74 @@
+﻿package notify
+
+import (
+    "context"
+    "encoding/json"
+    "log"
+    "net/url"
+    "os"
+    "sync"
+    "time"
+
+    "xo-server/internal/domain"
+)
+
+type LogNotifier struct {
+    resetURL string
+}
+
+func NewLogNotifier(resetURL string) *LogNotifier {
+    return &LogNotifier{resetURL: resetURL}
+}
+
+func (n *LogNotifier) SendPasswordReset(ctx context.Context, user *domain.User, token string, expiresAt time.Time) error {
+    log.Printf("password reset for %s (%s): %s expires %s", user.Username, user.ID, resetLink(n.resetURL, token), expiresAt.Format(time.RFC3339))
+    return nil
+}
+
+type FileNotifier struct {
+    path     string
+    resetURL string
+    mu       sync.Mutex
+}
+
+func NewFileNotifier(path, resetURL string) *FileNotifier {
+    return &FileNotifier{path: path, resetURL: resetURL}
+}
+
+func (n *FileNotifier) SendPasswordReset(ctx context.Context, user *domain.User, token string, expiresAt time.Time) error {
+    line, err := json.Marshal(map[string]string{
+        "type":       "password_reset",
+        "user_id":    user.ID.String(),
+        "username":   user.Username,
+        "token":      token,
+        "link":       resetLink(n.resetURL, token),
+        "expires_at": expiresAt.Format(time.RFC3339),
+    })
+    if err != nil {
+        return err
+    }
+
+    n.mu.Lock()
+    defer n.mu.Unlock()
+    f, err := os.OpenFile(n.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+    _, err = f.Write(append(line, '\n'))
+    return err
+}
+
+func resetLink(base, token string) string {
+    if base == "" {
+        return token
+    }
+    u, err := url.Parse(base)
+    if err != nil {
+        return base + token
+    }
+    q := u.Query()
+    q.Set("token", token)
+    u.RawQuery = q.Encode()
+    return u.String()
+}
@@ -0,0 +1,304 @@
+﻿package oidc
+
+import (
+    "context"
+    "crypto/ecdsa"
+    "crypto/ed25519"
+    "crypto/elliptic"
+    "crypto/rsa"
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "math/big"
+    "net/http"
+    "net/url"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/golang-jwt/jwt/v5"
+    "xo-server/internal/domain"
+)
+
+var errInvalidIDToken = errors.New("invalid id token")
+
+type Config struct {
+    Name         string
+    Issuer       string
+    ClientID     string
+    ClientSecret string
+    RedirectURL  string
+    Scopes       []string
+}
+
+type discovery struct {
+    Issuer                string `json:"issuer"`
+    AuthorizationEndpoint string `json:"authorization_endpoint"`
+    TokenEndpoint         string `json:"token_endpoint"`
+    JWKSURI               string `json:"jwks_uri"`
+}
+
+type jwk struct {
+    Kid string `json:"kid"`
+    Kty string `json:"kty"`
+    Crv string `json:"crv"`
+    N   string `json:"n"`
+    E   string `json:"e"`
+    X   string `json:"x"`
+    Y   string `json:"y"`
+}
+
+type Provider struct {
+    cfg    Config
+    client *http.Client
+
+    mu     sync.Mutex
+    meta   *discovery
+    keys   map[string]interface{}
+    keysAt time.Time
+}
+
+func NewProvider(cfg Config, client *http.Client) *Provider {
+    if client == nil {
+        client = &http.Client{Timeout: 10 * time.Second}
+    }
+    if len(cfg.Scopes) == 0 {
+        cfg.Scopes = []string{"openid", "profile", "email"}
+    }
+    return &Provider{cfg: cfg, client: client}
+}
+
+func (p *Provider) Name() string {
+    return p.cfg.Name
+}
+
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+    meta, err := p.discover(ctx)
+    if err != nil {
+        return "", err
+    }
+
+    q := url.Values{}
+    q.Set("response_type", "code")
+    q.Set("client_id", p.cfg.ClientID)
+    q.Set("redirect_uri", p.cfg.RedirectURL)
+    q.Set("scope", strings.Join(p.cfg.Scopes, " "))
+    q.Set("state", state)
+    q.Set("nonce", nonce)
+    q.Set("code_challenge", codeChallenge)
+    q.Set("code_challenge_method", "S256")
+
+    sep := "?"
+    if strings.Contains(meta.AuthorizationEndpoint, "?") {
+        sep = "&"
+    }
+    return meta.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*domain.ExternalClaims, error) {
+    meta, err := p.discover(ctx)
+    if err != nil {
+        return nil, err
+    }
+
+    form := url.Values{}
+    form.Set("grant_type", "authorization_code")
+    form.Set("code", code)
+    form.Set("redirect_uri", p.cfg.RedirectURL)
+    form.Set("client_id", p.cfg.ClientID)
+    form.Set("code_verifier", codeVerifier)
+    if p.cfg.ClientSecret != "" {
+        form.Set("client_secret", p.cfg.ClientSecret)
+    }
+
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+    if err != nil {
+        return nil, err
+    }
+    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    req.Header.Set("Accept", "application/json")
+
+    resp, err := p.client.Do(req)
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return nil, fmt.Errorf("token endpoint returned %d", resp.StatusCode)
+    }
+
+    var tokens struct {
+        IDToken string `json:"id_token"`
+    }
+    if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+        return nil, err
+    }
+    if tokens.IDToken == "" {
+        return nil, errInvalidIDToken
+    }
+
+    return p.verifyIDToken(ctx, meta, tokens.IDToken, nonce)
+}
+
+type idTokenClaims struct {
+    Nonce             string `json:"nonce"`
+    Email             string `json:"email"`
+    PreferredUsername string `json:"preferred_username"`
+    Name              string `json:"name"`
+    jwt.RegisteredClaims
+}
+
+func (p *Provider) verifyIDToken(ctx context.Context, meta *discovery, raw, nonce string) (*domain.ExternalClaims, error) {
+    var claims idTokenClaims
+    token, err := jwt.ParseWithClaims(raw, &claims, func(t *jwt.Token) (interface{}, error) {
+        kid, _ := t.Header["kid"].(string)
+        return p.key(ctx, meta, kid)
+    },
+        jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "EdDSA"}),
+        jwt.WithIssuer(meta.Issuer),
+        jwt.WithAudience(p.cfg.ClientID),
+        jwt.WithExpirationRequired(),
+    )
+    if err != nil || !token.Valid {
+        return nil, errInvalidIDToken
+    }
+    if claims.Nonce != nonce || claims.Subject == "" {
+        return nil, errInvalidIDToken
+    }
+
+    return &domain.ExternalClaims{
+        Subject:           claims.Subject,
+        Email:             claims.Email,
+        PreferredUsername: claims.PreferredUsername,
+        Name:              claims.Name,
+    }, nil
+}
+
+func (p *Provider) discover(ctx context.Context) (*discovery, error) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    if p.meta != nil {
+        return p.meta, nil
+    }
+
+    endpoint := strings.TrimSuffix(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+    var meta discovery
+    if err := p.getJSON(ctx, endpoint, &meta); err != nil {
+        return nil, err
+    }
+    if meta.Issuer != p.cfg.Issuer {
+        return nil, fmt.Errorf("issuer mismatch: %q", meta.Issuer)
+    }
+    if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+        return nil, fmt.Errorf("incomplete discovery document")
+    }
+    p.meta = &meta
+    return p.meta, nil
+}
+
+func (p *Provider) key(ctx context.Context, meta *discovery, kid string) (interface{}, error) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    if key, ok := p.keys[kid]; ok {
+        return key, nil
+    }
+    if !p.keysAt.IsZero() && time.Since(p.keysAt) < time.Minute {
+        return nil, fmt.Errorf("unknown key id %q", kid)
+    }
+
+    var set struct {
+        Keys []jwk `json:"keys"`
+    }
+    if err := p.getJSON(ctx, meta.JWKSURI, &set); err != nil {
+        return nil, err
+    }
+
+    keys := make(map[string]interface{}, len(set.Keys))
+    for _, k := range set.Keys {
+        pub, err := k.publicKey()
+        if err != nil {
+            continue
+        }
+        keys[k.Kid] = pub
+    }
+    p.keys = keys
+    p.keysAt = time.Now()
+
+    if key, ok := p.keys[kid]; ok {
+        return key, nil
+    }
+    return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+func (p *Provider) getJSON(ctx context.Context, endpoint string, v interface{}) error {
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Accept", "application/json")
+
+    resp, err := p.client.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return fmt.Errorf("%s returned %d", endpoint, resp.StatusCode)
+    }
+    return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (k jwk) publicKey() (interface{}, error) {
+    switch k.Kty {
+    case "RSA":
+        n, err := decodeBigInt(k.N)
+        if err != nil {
+            return nil, err
+        }
+        e, err := decodeBigInt(k.E)
+        if err != nil {
+            return nil, err
+        }
+        return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+    case "EC":
+        var curve elliptic.Curve
+        switch k.Crv {
+        case "P-256":
+            curve = elliptic.P256()
+        case "P-384":
+            curve = elliptic.P384()
+        default:
+            return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+        }
+        x, err := decodeBigInt(k.X)
+        if err != nil {
+            return nil, err
+        }
+        y, err := decodeBigInt(k.Y)
+        if err != nil {
+            return nil, err
+        }
+        return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+    case "OKP":
+        if k.Crv != "Ed25519" {
+            return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+        }
+        x, err := base64.RawURLEncoding.DecodeString(k.X)
+        if err != nil || len(x) != ed25519.PublicKeySize {
+            return nil, fmt.Errorf("invalid ed25519 key")
+        }
+        return ed25519.PublicKey(x), nil
+    default:
+        return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+    }
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+    b, err := base64.RawURLEncoding.DecodeString(s)
+    if err != nil {
+        return nil, err
+    }
+    return new(big.Int).SetBytes(b), nil
+}
@@ -1,8 +1,12 @@
 ﻿package memory
 
 import (
+    "bytes"
     "context"
+    "encoding/json"
+    "sort"
     "sync"
+    "time"
 
     "github.com/google/uuid"
     "xo-server/internal/domain"
@@ -52,6 +56,92 @@ func (r *UserRepo) GetUserByID(ctx context.Context, id uuid.UUID) (*domain.User,
     return &copy, nil
 }
 
+func (r *UserRepo) UpdatePassword(ctx context.Context, userID uuid.UUID, passwordHash string) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    u, ok := r.byID[userID]
+    if !ok {
+        return domain.ErrNotFound
+    }
+    u.PasswordHash = passwordHash
+    return nil
+}
+
+func (r *UserRepo) UpdateRole(ctx context.Context, userID uuid.UUID, role domain.Role) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    u, ok := r.byID[userID]
+    if !ok {
+        return domain.ErrNotFound
+    }
+    u.Role = role
+    return nil
+}
+
+func (r *UserRepo) UpdateSuspension(ctx context.Context, userID uuid.UUID, banned bool, until *time.Time, reason string) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    u, ok := r.byID[userID]
+    if !ok {
+        return domain.ErrNotFound
+    }
+    u.Banned = banned
+    u.SuspendedUntil = until
+    u.SuspensionReason = reason
+    return nil
+}
+
+func (r *UserRepo) UpgradeGuest(ctx context.Context, userID uuid.UUID, username, passwordHash string) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    u, ok := r.byID[userID]
+    if !ok || !u.IsGuest {
+        return domain.ErrNotFound
+    }
+    if _, taken := r.byName[username]; taken {
+        return domain.ErrInvalidInput
+    }
+    delete(r.byName, u.Username)
+    u.Username = username
+    u.PasswordHash = passwordHash
+    u.IsGuest = false
+    r.byName[username] = u
+    return nil
+}
+
+func (r *UserRepo) AnonymizeUser(ctx context.Context, userID uuid.UUID, username string, at time.Time) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    u, ok := r.byID[userID]
+    if !ok || u.DeletedAt != nil {
+        return domain.ErrNotFound
+    }
+    delete(r.byName, u.Username)
+    u.Username = username
+    u.PasswordHash = ""
+    u.Role = domain.RolePlayer
+    u.IsGuest = false
+    u.Banned = false
+    u.SuspendedUntil = nil
+    u.SuspensionReason = ""
+    u.DeletedAt = &at
+    r.byName[username] = u
+    return nil
+}
+
+func (r *UserRepo) ListUsersByIDs(ctx context.Context, ids []uuid.UUID) ([]*domain.User, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    out := make([]*domain.User, 0, len(ids))
+    for _, id := range ids {
+        if u, ok := r.byID[id]; ok {
+            copy := *u
+            out = append(out, &copy)
+        }
+    }
+    return out, nil
+}
+
 type GameRepo struct {
     mu      sync.RWMutex
     games   map[uuid.UUID]*domain.Game
@@ -67,6 +157,7 @@ func (r *GameRepo) CreateGame(ctx context.Context, game *domain.Game) error {
     r.mu.Lock()
     defer r.mu.Unlock()
     copy := *game
+    copy.Variant, copy.TimeControl = game.RatingPool()
     r.games[game.ID] = &copy
     return nil
 }
@@ -106,6 +197,23 @@ func (r *GameRepo) ListActiveGamesByUser(ctx context.Context, userID uuid.UUID)
     return out, nil
 }
 
+func (r *GameRepo) ListActiveGames(ctx context.Context, limit int) ([]*domain.Game, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    out := make([]*domain.Game, 0)
+    for _, g := range r.games {
+        if g.Status == domain.GameWaiting || g.Status == domain.GameInProgress || g.Status == domain.GameDrawOffer {
+            copy := *g
+            out = append(out, &copy)
+        }
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].UpdatedAt.After(out[j].UpdatedAt) })
+    if len(out) > limit {
+        out = out[:limit]
+    }
+    return out, nil
+}
+
 func (r *GameRepo) AddMove(ctx context.Context, move *domain.GameMove) error {
     r.mu.Lock()
     defer r.mu.Unlock()
@@ -121,3 +229,974 @@ func (r *GameRepo) AddMessage(ctx context.Context, msg *domain.GameMessage) erro
     r.messages = append(r.messages, &copy)
     return nil
 }
+
+func (r *GameRepo) ListGamesByUser(ctx context.Context, userID uuid.UUID) ([]*domain.Game, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    out := make([]*domain.Game, 0)
+    for _, g := range r.games {
+        if g.PlayerX == userID || g.PlayerO == userID {
+            copy := *g
+            out = append(out, &copy)
+        }
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+    return out, nil
+}
+
+func (r *GameRepo) ListMovesByUser(ctx context.Context, userID uuid.UUID) ([]*domain.GameMove, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    out := make([]*domain.GameMove, 0)
+    for _, m := range r.moves {
+        if m.UserID == userID {
+            copy := *m
+            out = append(out, &copy)
+        }
+    }
+    return out, nil
+}
+
+func (r *GameRepo) ListMovesByGame(ctx context.Context, gameID uuid.UUID) ([]*domain.GameMove, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    out := make([]*domain.GameMove, 0)
+    for _, m := range r.moves {
+        if m.GameID == gameID {
+            copy := *m
+            out = append(out, &copy)
+        }
+    }
+    return out, nil
+}
+
+func (r *GameRepo) ListMessagesByUser(ctx context.Context, userID uuid.UUID) ([]*domain.GameMessage, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    out := make([]*domain.GameMessage, 0)
+    for _, m := range r.messages {
+        if m.UserID == userID {
+            copy := *m
+            out = append(out, &copy)
+        }
+    }
+    return out, nil
+}
+
+func (r *GameRepo) RedactMessagesByUser(ctx context.Context, userID uuid.UUID, placeholder string) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    for _, m := range r.messages {
+        if m.UserID == userID {
+            m.Message = placeholder
+        }
+    }
+    return nil
+}
+
+func (r *GameRepo) ListFinishedGamesByUser(ctx context.Context, userID uuid.UUID, limit int) ([]*domain.Game, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    out := make([]*domain.Game, 0)
+    for _, g := range r.games {
+        if (g.PlayerX == userID || g.PlayerO == userID) && g.Status == domain.GameFinished {
+            copy := *g
+            out = append(out, &copy)
+        }
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].UpdatedAt.After(out[j].UpdatedAt) })
+    if len(out) > limit {
+        out = out[:limit]
+    }
+    return out, nil
+}
+
+func (r *GameRepo) ListRatedGamesSince(ctx context.Context, variant, timeControl string, since time.Time) ([]*domain.Game, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    out := make([]*domain.Game, 0)
+    for _, g := range r.games {
+        if g.Status == domain.GameFinished && g.Rated && g.Variant == variant && g.TimeControl == timeControl && !g.UpdatedAt.Before(since) {
+            copy := *g
+            out = append(out, &copy)
+        }
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].UpdatedAt.Before(out[j].UpdatedAt) })
+    return out, nil
+}
+
+func (r *GameRepo) ListFinishedGamesBetween(ctx context.Context, a, b uuid.UUID, limit int) ([]*domain.Game, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    out := make([]*domain.Game, 0)
+    for _, g := range r.games {
+        pair := (g.PlayerX == a && g.PlayerO == b) || (g.PlayerX == b && g.PlayerO == a)
+        if pair && g.Status == domain.GameFinished {
+            copy := *g
+            out = append(out, &copy)
+        }
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].UpdatedAt.After(out[j].UpdatedAt) })
+    if len(out) > limit {
+        out = out[:limit]
+    }
+    return out, nil
+}
+
+func (r *GameRepo) CountFinishedGamesBetween(ctx context.Context, userID, opponentID uuid.UUID) (domain.ColourRecord, domain.ColourRecord, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    var asX, asO domain.ColourRecord
+    for _, g := range r.games {
+        if g.Status != domain.GameFinished {
+            continue
+        }
+        switch {
+        case g.PlayerX == userID && g.PlayerO == opponentID:
+            asX.Add(g, userID)
+        case g.PlayerX == opponentID && g.PlayerO == userID:
+            asO.Add(g, userID)
+        }
+    }
+    return asX, asO, nil
+}
+
+type GameEventRepo struct {
+    mu     sync.RWMutex
+    events map[uuid.UUID][]*domain.GameEvent
+}
+
+func NewGameEventRepo() *GameEventRepo {
+    return &GameEventRepo{events: make(map[uuid.UUID][]*domain.GameEvent)}
+}
+
+func (r *GameEventRepo) AppendGameEvent(ctx context.Context, event *domain.GameEvent) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    event.Seq = int64(len(r.events[event.GameID])) + 1
+    copy := *event
+    r.events[event.GameID] = append(r.events[event.GameID], &copy)
+    return nil
+}
+
+func (r *GameEventRepo) ListGameEventsAfter(ctx context.Context, gameID uuid.UUID, afterSeq int64, limit int) ([]*domain.GameEvent, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    out := make([]*domain.GameEvent, 0)
+    for _, e := range r.events[gameID] {
+        if e.Seq <= afterSeq {
+            continue
+        }
+        if limit > 0 && len(out) >= limit {
+            break
+        }
+        copy := *e
+        out = append(out, &copy)
+    }
+    return out, nil
+}
+
+func (r *GameEventRepo) LastGameEventSeq(ctx context.Context, gameID uuid.UUID) (int64, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    return int64(len(r.events[gameID])), nil
+}
+
+func (r *GameEventRepo) RedactChatEvents(ctx context.Context, userID uuid.UUID, placeholder string) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    for _, events := range r.events {
+        for _, e := range events {
+            if e.Type != domain.GameEventChat {
+                continue
+            }
+            var payload map[string]interface{}
+            if err := json.Unmarshal(e.Payload, &payload); err != nil || payload["user_id"] != userID.String() {
+                continue
+            }
+            payload["message"] = placeholder
+            raw, err := json.Marshal(payload)
+            if err != nil {
+                return err
+            }
+            e.Payload = raw
+        }
+    }
+    return nil
+}
+
+type FriendRepo struct {
+    mu    sync.RWMutex
+    pairs map[[2]uuid.UUID]*domain.Friendship
+}
+
+func NewFriendRepo() *FriendRepo {
+    return &FriendRepo{pairs: make(map[[2]uuid.UUID]*domain.Friendship)}
+}
+
+func friendKey(a, b uuid.UUID) [2]uuid.UUID {
+    if bytes.Compare(a[:], b[:]) > 0 {
+        a, b = b, a
+    }
+    return [2]uuid.UUID{a, b}
+}
+
+func (r *FriendRepo) CreateFriendship(ctx context.Context, f *domain.Friendship) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    key := friendKey(f.RequesterID, f.AddresseeID)
+    if _, ok := r.pairs[key]; ok {
+        return domain.ErrInvalidInput
+    }
+    copy := *f
+    r.pairs[key] = &copy
+    return nil
+}
+
+func (r *FriendRepo) GetFriendship(ctx context.Context, userA, userB uuid.UUID) (*domain.Friendship, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    f, ok := r.pairs[friendKey(userA, userB)]
+    if !ok {
+        return nil, domain.ErrNotFound
+    }
+    copy := *f
+    return &copy, nil
+}
+
+func (r *FriendRepo) UpdateFriendship(ctx context.Context, f *domain.Friendship) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    key := friendKey(f.RequesterID, f.AddresseeID)
+    if _, ok := r.pairs[key]; !ok {
+        return domain.ErrNotFound
+    }
+    copy := *f
+    r.pairs[key] = &copy
+    return nil
+}
+
+func (r *FriendRepo) DeleteFriendship(ctx context.Context, userA, userB uuid.UUID) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    key := friendKey(userA, userB)
+    if _, ok := r.pairs[key]; !ok {
+        return domain.ErrNotFound
+    }
+    delete(r.pairs, key)
+    return nil
+}
+
+func (r *FriendRepo) ListFriendshipsByUser(ctx context.Context, userID uuid.UUID) ([]*domain.Friendship, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    out := make([]*domain.Friendship, 0)
+    for _, f := range r.pairs {
+        if f.RequesterID == userID || f.AddresseeID == userID {
+            copy := *f
+            out = append(out, &copy)
+        }
+    }
+    return out, nil
+}
+
+type RefreshTokenRepo struct {
+    mu     sync.RWMutex
+    tokens map[uuid.UUID]*domain.RefreshToken
+    byHash map[string]uuid.UUID
+}
+
+func NewRefreshTokenRepo() *RefreshTokenRepo {
+    return &RefreshTokenRepo{
+        tokens: make(map[uuid.UUID]*domain.RefreshToken),
+        byHash: make(map[string]uuid.UUID),
+    }
+}
+
+func (r *RefreshTokenRepo) CreateRefreshToken(ctx context.Context, token *domain.RefreshToken) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    if _, ok := r.byHash[token.TokenHash]; ok {
+        return domain.ErrInvalidInput
+    }
+    copy := *token
+    r.tokens[token.ID] = &copy
+    r.byHash[token.TokenHash] = token.ID
+    return nil
+}
+
+func (r *RefreshTokenRepo) GetRefreshTokenByHash(ctx context.Context, hash string) (*domain.RefreshToken, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    id, ok := r.byHash[hash]
+    if !ok {
+        return nil, domain.ErrNotFound
+    }
+    copy := *r.tokens[id]
+    return &copy, nil
+}
+
+func (r *RefreshTokenRepo) MarkRefreshTokenRotated(ctx context.Context, id uuid.UUID, at time.Time) (bool, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    t, ok := r.tokens[id]
+    if !ok {
+        return false, domain.ErrNotFound
+    }
+    if t.RotatedAt != nil {
+        return false, nil
+    }
+    t.RotatedAt = &at
+    return true, nil
+}
+
+func (r *RefreshTokenRepo) RevokeTokenFamily(ctx context.Context, familyID uuid.UUID, at time.Time) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    for _, t := range r.tokens {
+        if t.FamilyID == familyID && t.RevokedAt == nil {
+            t.RevokedAt = &at
+        }
+    }
+    return nil
+}
+
+func (r *RefreshTokenRepo) IsTokenFamilyRevoked(ctx context.Context, familyID uuid.UUID) (bool, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    found := false
+    for _, t := range r.tokens {
+        if t.FamilyID != familyID {
+            continue
+        }
+        if t.RevokedAt != nil {
+            return true, nil
+        }
+        found = true
+    }
+    return !found, nil
+}
+
+func (r *RefreshTokenRepo) RevokeUserTokenFamilies(ctx context.Context, userID, except uuid.UUID, at time.Time) ([]uuid.UUID, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    seen := make(map[uuid.UUID]bool)
+    out := make([]uuid.UUID, 0)
+    for _, t := range r.tokens {
+        if t.UserID != userID || t.FamilyID == except || t.RevokedAt != nil {
+            continue
+        }
+        t.RevokedAt = &at
+        if !seen[t.FamilyID] {
+            seen[t.FamilyID] = true
+            out = append(out, t.FamilyID)
+        }
+    }
+    return out, nil
+}
+
+func (r *RefreshTokenRepo) GetTokenFamilyStart(ctx context.Context, familyID uuid.UUID) (time.Time, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    var started time.Time
+    for _, t := range r.tokens {
+        if t.FamilyID == familyID && (started.IsZero() || t.CreatedAt.Before(started)) {
+            started = t.CreatedAt
+        }
+    }
+    if started.IsZero() {
+        return time.Time{}, domain.ErrNotFound
+    }
+    return started, nil
+}
+
+type IdentityRepo struct {
+    mu         sync.RWMutex
+    identities map[[2]string]*domain.ExternalIdentity
+}
+
+func NewIdentityRepo() *IdentityRepo {
+    return &IdentityRepo{identities: make(map[[2]string]*domain.ExternalIdentity)}
+}
+
+func (r *IdentityRepo) CreateIdentity(ctx context.Context, identity *domain.ExternalIdentity) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    key := [2]string{identity.Provider, identity.Subject}
+    if _, ok := r.identities[key]; ok {
+        return domain.ErrIdentityLinked
+    }
+    copy := *identity
+    r.identities[key] = &copy
+    return nil
+}
+
+func (r *IdentityRepo) GetIdentity(ctx context.Context, provider, subject string) (*domain.ExternalIdentity, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    identity, ok := r.identities[[2]string{provider, subject}]
+    if !ok {
+        return nil, domain.ErrNotFound
+    }
+    copy := *identity
+    return &copy, nil
+}
+
+func (r *IdentityRepo) ListIdentitiesByUser(ctx context.Context, userID uuid.UUID) ([]*domain.ExternalIdentity, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    out := make([]*domain.ExternalIdentity, 0)
+    for _, identity := range r.identities {
+        if identity.UserID == userID {
+            copy := *identity
+            out = append(out, &copy)
+        }
+    }
+    return out, nil
+}
+
+func (r *IdentityRepo) DeleteIdentitiesByUser(ctx context.Context, userID uuid.UUID) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    for key, identity := range r.identities {
+        if identity.UserID == userID {
+            delete(r.identities, key)
+        }
+    }
+    return nil
+}
+
+type PasswordResetRepo struct {
+    mu     sync.RWMutex
+    tokens map[uuid.UUID]*domain.PasswordResetToken
+}
+
+func NewPasswordResetRepo() *PasswordResetRepo {
+    return &PasswordResetRepo{tokens: make(map[uuid.UUID]*domain.PasswordResetToken)}
+}
+
+func (r *PasswordResetRepo) CreateResetToken(ctx context.Context, token *domain.PasswordResetToken) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    copy := *token
+    r.tokens[token.ID] = &copy
+    return nil
+}
+
+func (r *PasswordResetRepo) GetResetTokenByHash(ctx context.Context, hash string) (*domain.PasswordResetToken, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    for _, t := range r.tokens {
+        if t.TokenHash == hash {
+            copy := *t
+            return &copy, nil
+        }
+    }
+    return nil, domain.ErrNotFound
+}
+
+func (r *PasswordResetRepo) MarkResetTokenUsed(ctx context.Context, id uuid.UUID, at time.Time) (bool, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    t, ok := r.tokens[id]
+    if !ok {
+        return false, domain.ErrNotFound
+    }
+    if t.UsedAt != nil {
+        return false, nil
+    }
+    t.UsedAt = &at
+    return true, nil
+}
+
+func (r *PasswordResetRepo) InvalidateResetTokens(ctx context.Context, userID uuid.UUID, at time.Time) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    for _, t := range r.tokens {
+        if t.UserID == userID && t.UsedAt == nil {
+            t.UsedAt = &at
+        }
+    }
+    return nil
+}
+
+type recoveryCode struct {
+    hash   string
+    usedAt *time.Time
+}
+
+type TwoFactorRepo struct {
+    mu       sync.RWMutex
+    secrets  map[uuid.UUID]*domain.TOTPSecret
+    recovery map[uuid.UUID][]*recoveryCode
+}
+
+func NewTwoFactorRepo() *TwoFactorRepo {
+    return &TwoFactorRepo{
+        secrets:  make(map[uuid.UUID]*domain.TOTPSecret),
+        recovery: make(map[uuid.UUID][]*recoveryCode),
+    }
+}
+
+func (r *TwoFactorRepo) GetTOTP(ctx context.Context, userID uuid.UUID) (*domain.TOTPSecret, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    s, ok := r.secrets[userID]
+    if !ok {
+        return nil, domain.ErrNotFound
+    }
+    copy := *s
+    return &copy, nil
+}
+
+func (r *TwoFactorRepo) SaveTOTP(ctx context.Context, secret *domain.TOTPSecret) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    copy := *secret
+    r.secrets[secret.UserID] = &copy
+    return nil
+}
+
+func (r *TwoFactorRepo) AdvanceTOTPStep(ctx context.Context, userID uuid.UUID, from, to int64) (bool, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    s, ok := r.secrets[userID]
+    if !ok || s.LastUsedStep != from {
+        return false, nil
+    }
+    s.LastUsedStep = to
+    return true, nil
+}
+
+func (r *TwoFactorRepo) DeleteTOTP(ctx context.Context, userID uuid.UUID) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    delete(r.secrets, userID)
+    return nil
+}
+
+func (r *TwoFactorRepo) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, hashes []string) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    codes := make([]*recoveryCode, 0, len(hashes))
+    for _, h := range hashes {
+        codes = append(codes, &recoveryCode{hash: h})
+    }
+    r.recovery[userID] = codes
+    return nil
+}
+
+func (r *TwoFactorRepo) UseRecoveryCode(ctx context.Context, userID uuid.UUID, hash string, at time.Time) (bool, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    for _, c := range r.recovery[userID] {
+        if c.hash == hash && c.usedAt == nil {
+            c.usedAt = &at
+            return true, nil
+        }
+    }
+    return false, nil
+}
+
+type ReportRepo struct {
+    mu      sync.RWMutex
+    reports map[uuid.UUID]*domain.Report
+}
+
+func NewReportRepo() *ReportRepo {
+    return &ReportRepo{reports: make(map[uuid.UUID]*domain.Report)}
+}
+
+func (r *ReportRepo) CreateReport(ctx context.Context, report *domain.Report) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    copy := *report
+    r.reports[report.ID] = &copy
+    return nil
+}
+
+func (r *ReportRepo) ListReports(ctx context.Context, status domain.ReportStatus, limit int) ([]*domain.Report, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    out := make([]*domain.Report, 0)
+    for _, rep := range r.reports {
+        if status == "" || rep.Status == status {
+            copy := *rep
+            out = append(out, &copy)
+        }
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+    if len(out) > limit {
+        out = out[:limit]
+    }
+    return out, nil
+}
+
+func (r *ReportRepo) ResolveReport(ctx context.Context, id, resolvedBy uuid.UUID, resolution string, at time.Time) (bool, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    rep, ok := r.reports[id]
+    if !ok || rep.Status != domain.ReportOpen {
+        return false, nil
+    }
+    rep.Status = domain.ReportResolved
+    rep.Resolution = resolution
+    rep.ResolvedBy = &resolvedBy
+    rep.ResolvedAt = &at
+    return true, nil
+}
+
+type AuditRepo struct {
+    mu     sync.RWMutex
+    events []*domain.AuditEvent
+}
+
+func NewAuditRepo() *AuditRepo {
+    return &AuditRepo{}
+}
+
+func (r *AuditRepo) AppendAuditEvent(ctx context.Context, event *domain.AuditEvent) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    copy := *event
+    r.events = append(r.events, &copy)
+    return nil
+}
+
+func (r *AuditRepo) ListAuditEvents(ctx context.Context, filter domain.AuditFilter) ([]*domain.AuditEvent, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    out := make([]*domain.AuditEvent, 0)
+    for i := len(r.events) - 1; i >= 0 && len(out) < filter.Limit; i-- {
+        e := r.events[i]
+        if filter.Action != "" && e.Action != filter.Action {
+            continue
+        }
+        if filter.ActorID != nil && (e.ActorID == nil || *e.ActorID != *filter.ActorID) {
+            continue
+        }
+        if filter.TargetID != nil && (e.TargetID == nil || *e.TargetID != *filter.TargetID) {
+            continue
+        }
+        if filter.Since != nil && e.CreatedAt.Before(*filter.Since) {
+            continue
+        }
+        if filter.Until != nil && !e.CreatedAt.Before(*filter.Until) {
+            continue
+        }
+        copy := *e
+        out = append(out, &copy)
+    }
+    return out, nil
+}
+
+type StatsRepo struct {
+    mu      sync.RWMutex
+    stats   map[uuid.UUID]*domain.PlayerStats
+    ratings map[uuid.UUID]map[string]*domain.Rating
+    changes map[uuid.UUID][]*domain.RatingChange
+}
+
+func NewStatsRepo() *StatsRepo {
+    return &StatsRepo{
+        stats:   make(map[uuid.UUID]*domain.PlayerStats),
+        ratings: make(map[uuid.UUID]map[string]*domain.Rating),
+        changes: make(map[uuid.UUID][]*domain.RatingChange),
+    }
+}
+
+func (r *StatsRepo) GetPlayerStats(ctx context.Context, userID uuid.UUID) (*domain.PlayerStats, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    st, ok := r.stats[userID]
+    if !ok {
+        return nil, domain.ErrNotFound
+    }
+    copy := *st
+    return &copy, nil
+}
+
+func (r *StatsRepo) SaveStatsUpdate(ctx context.Context, update *domain.StatsUpdate) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    for _, stats := range update.Stats {
+        copy := *stats
+        r.stats[stats.UserID] = &copy
+    }
+    for _, gameID := range update.Cleared {
+        delete(r.changes, gameID)
+    }
+    for _, change := range update.Changes {
+        r.saveRatingChange(change)
+    }
+    for _, rating := range update.Ratings {
+        if r.ratings[rating.UserID] == nil {
+            r.ratings[rating.UserID] = make(map[string]*domain.Rating)
+        }
+        copy := *rating
+        r.ratings[rating.UserID][ratingKey(rating.Variant, rating.TimeControl)] = &copy
+    }
+    return nil
+}
+
+func (r *StatsRepo) GetRating(ctx context.Context, userID uuid.UUID, variant, timeControl string) (*domain.Rating, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    rating, ok := r.ratings[userID][ratingKey(variant, timeControl)]
+    if !ok {
+        return nil, domain.ErrNotFound
+    }
+    copy := *rating
+    return &copy, nil
+}
+
+func (r *StatsRepo) ListRatings(ctx context.Context, userID uuid.UUID) ([]*domain.Rating, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    out := make([]*domain.Rating, 0, len(r.ratings[userID]))
+    for _, rating := range r.ratings[userID] {
+        copy := *rating
+        out = append(out, &copy)
+    }
+    sort.Slice(out, func(i, j int) bool {
+        return ratingKey(out[i].Variant, out[i].TimeControl) < ratingKey(out[j].Variant, out[j].TimeControl)
+    })
+    return out, nil
+}
+
+func (r *StatsRepo) ListRatingsByVariant(ctx context.Context, variant, timeControl string, minGames int) ([]*domain.Rating, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    out := make([]*domain.Rating, 0)
+    for _, byPool := range r.ratings {
+        if rating, ok := byPool[ratingKey(variant, timeControl)]; ok && rating.Games >= minGames {
+            copy := *rating
+            out = append(out, &copy)
+        }
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].Rating > out[j].Rating })
+    return out, nil
+}
+
+func ratingKey(variant, timeControl string) string {
+    return variant + "/" + timeControl
+}
+
+func (r *StatsRepo) saveRatingChange(change *domain.RatingChange) {
+    copy := *change
+    list := r.changes[change.GameID]
+    for i, c := range list {
+        if c.UserID == change.UserID {
+            list[i] = &copy
+            return
+        }
+    }
+    r.changes[change.GameID] = append(list, &copy)
+}
+
+type AchievementRepo struct {
+    mu     sync.RWMutex
+    awards map[uuid.UUID][]*domain.UserAchievement
+}
+
+func NewAchievementRepo() *AchievementRepo {
+    return &AchievementRepo{awards: make(map[uuid.UUID][]*domain.UserAchievement)}
+}
+
+func (r *AchievementRepo) AwardAchievement(ctx context.Context, achievement *domain.UserAchievement) (bool, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    for _, a := range r.awards[achievement.UserID] {
+        if a.Code == achievement.Code {
+            return false, nil
+        }
+    }
+    copy := *achievement
+    r.awards[achievement.UserID] = append(r.awards[achievement.UserID], &copy)
+    return true, nil
+}
+
+func (r *AchievementRepo) ListAchievements(ctx context.Context, userID uuid.UUID) ([]*domain.UserAchievement, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    out := make([]*domain.UserAchievement, 0, len(r.awards[userID]))
+    for _, a := range r.awards[userID] {
+        copy := *a
+        out = append(out, &copy)
+    }
+    return out, nil
+}
+
+type SeasonRepo struct {
+    mu        sync.RWMutex
+    stats     *StatsRepo
+    seasons   map[uuid.UUID]*domain.Season
+    standings map[uuid.UUID][]*domain.SeasonStanding
+}
+
+func NewSeasonRepo(stats *StatsRepo) *SeasonRepo {
+    return &SeasonRepo{
+        stats:     stats,
+        seasons:   make(map[uuid.UUID]*domain.Season),
+        standings: make(map[uuid.UUID][]*domain.SeasonStanding),
+    }
+}
+
+func (r *SeasonRepo) OpenSeason(ctx context.Context, season *domain.Season) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    for _, s := range r.seasons {
+        if s.Status == domain.SeasonOpen {
+            return domain.ErrSeasonOpen
+        }
+    }
+    r.open(season)
+    return nil
+}
+
+func (r *SeasonRepo) RolloverSeason(ctx context.Context, closingID uuid.UUID, standings []*domain.SeasonStanding, next *domain.Season) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    if err := r.close(closingID, next.StartsAt, standings); err != nil {
+        return err
+    }
+    r.open(next)
+    return nil
+}
+
+func (r *SeasonRepo) open(season *domain.Season) {
+    copy := *season
+    r.seasons[season.ID] = &copy
+
+    r.stats.mu.Lock()
+    defer r.stats.mu.Unlock()
+    for _, byPool := range r.stats.ratings {
+        for _, rating := range byPool {
+            rating.Rating = domain.SoftReset(rating.Rating, season.CarryOver)
+            rating.UpdatedAt = season.StartsAt
+        }
+    }
+}
+
+func (r *SeasonRepo) GetSeason(ctx context.Context, id uuid.UUID) (*domain.Season, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    s, ok := r.seasons[id]
+    if !ok {
+        return nil, domain.ErrNotFound
+    }
+    copy := *s
+    return &copy, nil
+}
+
+func (r *SeasonRepo) GetOpenSeason(ctx context.Context) (*domain.Season, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    for _, s := range r.seasons {
+        if s.Status == domain.SeasonOpen {
+            copy := *s
+            return &copy, nil
+        }
+    }
+    return nil, domain.ErrNotFound
+}
+
+func (r *SeasonRepo) ListSeasons(ctx context.Context) ([]*domain.Season, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    out := make([]*domain.Season, 0, len(r.seasons))
+    for _, s := range r.seasons {
+        copy := *s
+        out = append(out, &copy)
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].StartsAt.After(out[j].StartsAt) })
+    return out, nil
+}
+
+func (r *SeasonRepo) CloseSeason(ctx context.Context, id uuid.UUID, closedAt time.Time, standings []*domain.SeasonStanding) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    return r.close(id, closedAt, standings)
+}
+
+func (r *SeasonRepo) close(id uuid.UUID, closedAt time.Time, standings []*domain.SeasonStanding) error {
+    s, ok := r.seasons[id]
+    if !ok || s.Status != domain.SeasonOpen {
+        return domain.ErrNotFound
+    }
+    s.Status = domain.SeasonClosed
+    s.ClosedAt = &closedAt
+    archived := make([]*domain.SeasonStanding, 0, len(standings))
+    for _, st := range standings {
+        copy := *st
+        archived = append(archived, &copy)
+    }
+    r.standings[id] = archived
+    return nil
+}
+
+func (r *SeasonRepo) ListStandings(ctx context.Context, seasonID uuid.UUID) ([]*domain.SeasonStanding, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    out := make([]*domain.SeasonStanding, 0, len(r.standings[seasonID]))
+    for _, st := range r.standings[seasonID] {
+        copy := *st
+        out = append(out, &copy)
+    }
+    return out, nil
+}
+
+func (r *SeasonRepo) RenameStandings(ctx context.Context, userID uuid.UUID, username string) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    for _, standings := range r.standings {
+        for _, st := range standings {
+            if st.UserID == userID {
+                st.Username = username
+            }
+        }
+    }
+    return nil
+}
+
+type AccountRepo struct {
+    users      *UserRepo
+    games      *GameRepo
+    events     *GameEventRepo
+    friends    *FriendRepo
+    identities *IdentityRepo
+    twoFactor  *TwoFactorRepo
+    resets     *PasswordResetRepo
+    seasons    *SeasonRepo
+}
+
+func NewAccountRepo(users *UserRepo, games *GameRepo, events *GameEventRepo, friends *FriendRepo, identities *IdentityRepo, twoFactor *TwoFactorRepo, resets *PasswordResetRepo, seasons *SeasonRepo) *AccountRepo {
+    return &AccountRepo{
+        users:      users,
+        games:      games,
+        events:     events,
+        friends:    friends,
+        identities: identities,
+        twoFactor:  twoFactor,
+        resets:     resets,
+        seasons:    seasons,
+    }
+}
+
+func (r *AccountRepo) AnonymizeAccount(ctx context.Context, userID uuid.UUID, username, placeholder string, at time.Time) error {
+    if err := r.users.AnonymizeUser(ctx, userID, username, at); err != nil {
+        return err
+    }
+    _ = r.resets.InvalidateResetTokens(ctx, userID, at)
+    _ = r.twoFactor.ReplaceRecoveryCodes(ctx, userID, nil)
+    _ = r.twoFactor.DeleteTOTP(ctx, userID)
+    _ = r.identities.DeleteIdentitiesByUser(ctx, userID)
+    friendships, _ := r.friends.ListFriendshipsByUser(ctx, userID)
+    for _, f := range friendships {
+        _ = r.friends.DeleteFriendship(ctx, f.RequesterID, f.AddresseeID)
+    }
+    _ = r.games.RedactMessagesByUser(ctx, userID, placeholder)
+    _ = r.events.RedactChatEvents(ctx, userID, placeholder)
+    _ = r.seasons.RenameStandings(ctx, userID, username)
+    return nil
+}
@@ -5,6 +5,7 @@ import (
     "time"
 
     "github.com/google/uuid"
+    "github.com/jackc/pgx/v5"
     "github.com/jackc/pgx/v5/pgxpool"
     "xo-server/internal/domain"
 )
@@ -19,40 +20,128 @@ func NewUserRepo(db *pgxpool.Pool) *UserRepo {
 
 func (r *UserRepo) CreateUser(ctx context.Context, user *domain.User) error {
     _, err := r.db.Exec(ctx, `
-        INSERT INTO users (id, username, password_hash, created_at)
-        VALUES ($1, $2, $3, $4)
-    `, user.ID, user.Username, user.PasswordHash, user.CreatedAt)
+        INSERT INTO users (id, username, password_hash, role, is_guest, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+    `, user.ID, user.Username, user.PasswordHash, string(user.Role), user.IsGuest, user.CreatedAt)
     return err
 }
 
 func (r *UserRepo) GetUserByUsername(ctx context.Context, username string) (*domain.User, error) {
     row := r.db.QueryRow(ctx, `
-        SELECT id, username, password_hash, created_at
+        SELECT id, username, password_hash, role, is_guest, banned, suspended_until, suspension_reason, created_at, deleted_at
         FROM users
         WHERE username = $1
     `, username)
 
     var u domain.User
-    if err := row.Scan(&u.ID, &u.Username, &u.PasswordHash, &u.CreatedAt); err != nil {
+    var role string
+    if err := row.Scan(&u.ID, &u.Username, &u.PasswordHash, &role, &u.IsGuest, &u.Banned, &u.SuspendedUntil, &u.SuspensionReason, &u.CreatedAt, &u.DeletedAt); err != nil {
         return nil, domain.ErrNotFound
     }
+    u.Role = domain.Role(role)
     return &u, nil
 }
 
 func (r *UserRepo) GetUserByID(ctx context.Context, id uuid.UUID) (*domain.User, error) {
     row := r.db.QueryRow(ctx, `
-        SELECT id, username, password_hash, created_at
+        SELECT id, username, password_hash, role, is_guest, banned, suspended_until, suspension_reason, created_at, deleted_at
         FROM users
         WHERE id = $1
     `, id)
 
     var u domain.User
-    if err := row.Scan(&u.ID, &u.Username, &u.PasswordHash, &u.CreatedAt); err != nil {
+    var role string
+    if err := row.Scan(&u.ID, &u.Username, &u.PasswordHash, &role, &u.IsGuest, &u.Banned, &u.SuspendedUntil, &u.SuspensionReason, &u.CreatedAt, &u.DeletedAt); err != nil {
         return nil, domain.ErrNotFound
     }
+    u.Role = domain.Role(role)
     return &u, nil
 }
 
+func (r *UserRepo) UpdatePassword(ctx context.Context, userID uuid.UUID, passwordHash string) error {
+    tag, err := r.db.Exec(ctx, `
+        UPDATE users
+        SET password_hash = $2
+        WHERE id = $1
+    `, userID, passwordHash)
+    if err != nil {
+        return err
+    }
+    if tag.RowsAffected() == 0 {
+        return domain.ErrNotFound
+    }
+    return nil
+}
+
+func (r *UserRepo) UpdateRole(ctx context.Context, userID uuid.UUID, role domain.Role) error {
+    tag, err := r.db.Exec(ctx, `
+        UPDATE users
+        SET role = $2
+        WHERE id = $1
+    `, userID, string(role))
+    if err != nil {
+        return err
+    }
+    if tag.RowsAffected() == 0 {
+        return domain.ErrNotFound
+    }
+    return nil
+}
+
+func (r *UserRepo) UpdateSuspension(ctx context.Context, userID uuid.UUID, banned bool, until *time.Time, reason string) error {
+    tag, err := r.db.Exec(ctx, `
+        UPDATE users
+        SET banned = $2, suspended_until = $3, suspension_reason = $4
+        WHERE id = $1
+    `, userID, banned, until, reason)
+    if err != nil {
+        return err
+    }
+    if tag.RowsAffected() == 0 {
+        return domain.ErrNotFound
+    }
+    return nil
+}
+
+func (r *UserRepo) UpgradeGuest(ctx context.Context, userID uuid.UUID, username, passwordHash string) error {
+    tag, err := r.db.Exec(ctx, `
+        UPDATE users
+        SET username = $2, password_hash = $3, is_guest = FALSE
+        WHERE id = $1 AND is_guest
+    `, userID, username, passwordHash)
+    if err != nil {
+        return err
+    }
+    if tag.RowsAffected() == 0 {
+        return domain.ErrNotFound
+    }
+    return nil
+}
+
+func (r *UserRepo) ListUsersByIDs(ctx context.Context, ids []uuid.UUID) ([]*domain.User, error) {
+    rows, err := r.db.Query(ctx, `
+        SELECT id, username, password_hash, role, is_guest, banned, suspended_until, suspension_reason, created_at, deleted_at
+        FROM users
+        WHERE id = ANY($1)
+    `, ids)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var out []*domain.User
+    for rows.Next() {
+        var u domain.User
+        var role string
+        if err := rows.Scan(&u.ID, &u.Username, &u.PasswordHash, &role, &u.IsGuest, &u.Banned, &u.SuspendedUntil, &u.SuspensionReason, &u.CreatedAt, &u.DeletedAt); err != nil {
+            return nil, err
+        }
+        u.Role = domain.Role(role)
+        out = append(out, &u)
+    }
+    return out, nil
+}
+
 type GameRepo struct {
     db *pgxpool.Pool
 }
@@ -62,16 +151,17 @@ func NewGameRepo(db *pgxpool.Pool) *GameRepo {
 }
 
 func (r *GameRepo) CreateGame(ctx context.Context, game *domain.Game) error {
+    variant, timeControl := game.RatingPool()
     _, err := r.db.Exec(ctx, `
-        INSERT INTO games (id, player_x, player_o, board, next_turn, status, winner_user_id, draw_offered_by, created_at, updated_at)
-        VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)
-    `, game.ID, game.PlayerX, game.PlayerO, domain.BoardToString(game.Board), game.NextTurn, string(game.Status), game.WinnerUserID, game.DrawOfferedBy, game.CreatedAt, game.UpdatedAt)
+        INSERT INTO games (id, player_x, player_o, board, next_turn, status, winner_user_id, draw_offered_by, rated, end_reason, variant, time_control, created_at, updated_at)
+        VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14)
+    `, game.ID, game.PlayerX, game.PlayerO, domain.BoardToString(game.Board), game.NextTurn, string(game.Status), game.WinnerUserID, game.DrawOfferedBy, game.Rated, game.EndReason, variant, timeControl, game.CreatedAt, game.UpdatedAt)
     return err
 }
 
 func (r *GameRepo) GetGameByID(ctx context.Context, id uuid.UUID) (*domain.Game, error) {
     row := r.db.QueryRow(ctx, `
-        SELECT id, player_x, player_o, board, next_turn, status, winner_user_id, draw_offered_by, created_at, updated_at
+        SELECT id, player_x, player_o, board, next_turn, status, winner_user_id, draw_offered_by, rated, end_reason, variant, time_control, created_at, updated_at
         FROM games
         WHERE id = $1
     `, id)
@@ -79,7 +169,7 @@ func (r *GameRepo) GetGameByID(ctx context.Context, id uuid.UUID) (*domain.Game,
     var g domain.Game
     var boardStr string
     var status string
-    if err := row.Scan(&g.ID, &g.PlayerX, &g.PlayerO, &boardStr, &g.NextTurn, &status, &g.WinnerUserID, &g.DrawOfferedBy, &g.CreatedAt, &g.UpdatedAt); err != nil {
+    if err := row.Scan(&g.ID, &g.PlayerX, &g.PlayerO, &boardStr, &g.NextTurn, &status, &g.WinnerUserID, &g.DrawOfferedBy, &g.Rated, &g.EndReason, &g.Variant, &g.TimeControl, &g.CreatedAt, &g.UpdatedAt); err != nil {
         return nil, domain.ErrNotFound
     }
 
@@ -96,15 +186,15 @@ func (r *GameRepo) GetGameByID(ctx context.Context, id uuid.UUID) (*domain.Game,
 func (r *GameRepo) UpdateGame(ctx context.Context, game *domain.Game) error {
     _, err := r.db.Exec(ctx, `
         UPDATE games
-        SET board=$2, next_turn=$3, status=$4, winner_user_id=$5, draw_offered_by=$6, updated_at=$7
+        SET board=$2, next_turn=$3, status=$4, winner_user_id=$5, draw_offered_by=$6, end_reason=$7, updated_at=$8
         WHERE id=$1
-    `, game.ID, domain.BoardToString(game.Board), game.NextTurn, string(game.Status), game.WinnerUserID, game.DrawOfferedBy, game.UpdatedAt)
+    `, game.ID, domain.BoardToString(game.Board), game.NextTurn, string(game.Status), game.WinnerUserID, game.DrawOfferedBy, game.EndReason, game.UpdatedAt)
     return err
 }
 
 func (r *GameRepo) ListActiveGamesByUser(ctx context.Context, userID uuid.UUID) ([]*domain.Game, error) {
     rows, err := r.db.Query(ctx, `
-        SELECT id, player_x, player_o, board, next_turn, status, winner_user_id, draw_offered_by, created_at, updated_at
+        SELECT id, player_x, player_o, board, next_turn, status, winner_user_id, draw_offered_by, rated, end_reason, variant, time_control, created_at, updated_at
         FROM games
         WHERE (player_x = $1 OR player_o = $1) AND status IN ('waiting','in_progress','draw_offered')
         ORDER BY updated_at DESC
@@ -112,6 +202,191 @@ func (r *GameRepo) ListActiveGamesByUser(ctx context.Context, userID uuid.UUID)
     if err != nil {
         return nil, err
     }
+    return scanGames(rows)
+}
+
+func (r *GameRepo) ListActiveGames(ctx context.Context, limit int) ([]*domain.Game, error) {
+    rows, err := r.db.Query(ctx, `
+        SELECT id, player_x, player_o, board, next_turn, status, winner_user_id, draw_offered_by, rated, end_reason, variant, time_control, created_at, updated_at
+        FROM games
+        WHERE status IN ('waiting','in_progress','draw_offered')
+        ORDER BY updated_at DESC
+        LIMIT $1
+    `, limit)
+    if err != nil {
+        return nil, err
+    }
+    return scanGames(rows)
+}
+
+func (r *GameRepo) AddMove(ctx context.Context, move *domain.GameMove) error {
+    _, err := r.db.Exec(ctx, `
+        INSERT INTO game_moves (game_id, user_id, position, symbol, created_at)
+        VALUES ($1,$2,$3,$4,$5)
+    `, move.GameID, move.UserID, move.Position, move.Symbol, move.CreatedAt)
+    return err
+}
+
+func (r *GameRepo) AddMessage(ctx context.Context, msg *domain.GameMessage) error {
+    _, err := r.db.Exec(ctx, `
+        INSERT INTO game_messages (game_id, user_id, message, created_at)
+        VALUES ($1,$2,$3,$4)
+    `, msg.GameID, msg.UserID, msg.Message, msg.CreatedAt)
+    return err
+}
+
+func (r *GameRepo) ListGamesByUser(ctx context.Context, userID uuid.UUID) ([]*domain.Game, error) {
+    rows, err := r.db.Query(ctx, `
+        SELECT id, player_x, player_o, board, next_turn, status, winner_user_id, draw_offered_by, rated, end_reason, variant, time_control, created_at, updated_at
+        FROM games
+        WHERE player_x = $1 OR player_o = $1
+        ORDER BY created_at
+    `, userID)
+    if err != nil {
+        return nil, err
+    }
+    return scanGames(rows)
+}
+
+func (r *GameRepo) ListMovesByUser(ctx context.Context, userID uuid.UUID) ([]*domain.GameMove, error) {
+    rows, err := r.db.Query(ctx, `
+        SELECT id, game_id, user_id, position, symbol, created_at
+        FROM game_moves
+        WHERE user_id = $1
+        ORDER BY id
+    `, userID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var out []*domain.GameMove
+    for rows.Next() {
+        var m domain.GameMove
+        if err := rows.Scan(&m.ID, &m.GameID, &m.UserID, &m.Position, &m.Symbol, &m.CreatedAt); err != nil {
+            return nil, err
+        }
+        out = append(out, &m)
+    }
+    return out, nil
+}
+
+func (r *GameRepo) ListMovesByGame(ctx context.Context, gameID uuid.UUID) ([]*domain.GameMove, error) {
+    rows, err := r.db.Query(ctx, `
+        SELECT id, game_id, user_id, position, symbol, created_at
+        FROM game_moves
+        WHERE game_id = $1
+        ORDER BY id
+    `, gameID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var out []*domain.GameMove
+    for rows.Next() {
+        var m domain.GameMove
+        if err := rows.Scan(&m.ID, &m.GameID, &m.UserID, &m.Position, &m.Symbol, &m.CreatedAt); err != nil {
+            return nil, err
+        }
+        out = append(out, &m)
+    }
+    return out, nil
+}
+
+func (r *GameRepo) ListMessagesByUser(ctx context.Context, userID uuid.UUID) ([]*domain.GameMessage, error) {
+    rows, err := r.db.Query(ctx, `
+        SELECT id, game_id, user_id, message, created_at
+        FROM game_messages
+        WHERE user_id = $1
+        ORDER BY id
+    `, userID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var out []*domain.GameMessage
+    for rows.Next() {
+        var m domain.GameMessage
+        if err := rows.Scan(&m.ID, &m.GameID, &m.UserID, &m.Message, &m.CreatedAt); err != nil {
+            return nil, err
+        }
+        out = append(out, &m)
+    }
+    return out, nil
+}
+
+func (r *GameRepo) ListFinishedGamesByUser(ctx context.Context, userID uuid.UUID, limit int) ([]*domain.Game, error) {
+    rows, err := r.db.Query(ctx, `
+        SELECT id, player_x, player_o, board, next_turn, status, winner_user_id, draw_offered_by, rated, end_reason, variant, time_control, created_at, updated_at
+        FROM games
+        WHERE (player_x = $1 OR player_o = $1) AND status = 'finished'
+        ORDER BY updated_at DESC
+        LIMIT $2
+    `, userID, limit)
+    if err != nil {
+        return nil, err
+    }
+    return scanGames(rows)
+}
+
+func (r *GameRepo) ListRatedGamesSince(ctx context.Context, variant, timeControl string, since time.Time) ([]*domain.Game, error) {
+    rows, err := r.db.Query(ctx, `
+        SELECT id, player_x, player_o, board, next_turn, status, winner_user_id, draw_offered_by, rated, end_reason, variant, time_control, created_at, updated_at
+        FROM games
+        WHERE status = 'finished' AND rated AND variant = $1 AND time_control = $2 AND updated_at >= $3
+        ORDER BY updated_at
+    `, variant, timeControl, since)
+    if err != nil {
+        return nil, err
+    }
+    return scanGames(rows)
+}
+
+func (r *GameRepo) CountFinishedGamesBetween(ctx context.Context, userID, opponentID uuid.UUID) (domain.ColourRecord, domain.ColourRecord, error) {
+    row := r.db.QueryRow(ctx, `
+        SELECT
+            COUNT(*) FILTER (WHERE player_x = $1 AND winner_user_id = $1),
+            COUNT(*) FILTER (WHERE player_x = $1 AND winner_user_id <> $1),
+            COUNT(*) FILTER (WHERE player_x = $1 AND winner_user_id IS NULL),
+            COUNT(*) FILTER (WHERE player_o = $1 AND winner_user_id = $1),
+            COUNT(*) FILTER (WHERE player_o = $1 AND winner_user_id <> $1),
+            COUNT(*) FILTER (WHERE player_o = $1 AND winner_user_id IS NULL)
+        FROM games
+        WHERE ((player_x = $1 AND player_o = $2) OR (player_x = $2 AND player_o = $1)) AND status = 'finished'
+    `, userID, opponentID)
+
+    var asX, asO domain.ColourRecord
+    if err := row.Scan(&asX.Wins, &asX.Losses, &asX.Draws, &asO.Wins, &asO.Losses, &asO.Draws); err != nil {
+        return asX, asO, err
+    }
+    return asX, asO, nil
+}
+
+func (r *GameRepo) ListFinishedGamesBetween(ctx context.Context, a, b uuid.UUID, limit int) ([]*domain.Game, error) {
+    rows, err := r.db.Query(ctx, `
+        (SELECT id, player_x, player_o, board, next_turn, status, winner_user_id, draw_offered_by, rated, end_reason, variant, time_control, created_at, updated_at
+         FROM games
+         WHERE player_x = $1 AND player_o = $2 AND status = 'finished'
+         ORDER BY updated_at DESC
+         LIMIT $3)
+        UNION ALL
+        (SELECT id, player_x, player_o, board, next_turn, status, winner_user_id, draw_offered_by, rated, end_reason, variant, time_control, created_at, updated_at
+         FROM games
+         WHERE player_x = $2 AND player_o = $1 AND status = 'finished'
+         ORDER BY updated_at DESC
+         LIMIT $3)
+        ORDER BY updated_at DESC
+        LIMIT $3
+    `, a, b, limit)
+    if err != nil {
+        return nil, err
+    }
+    return scanGames(rows)
+}
+
+func scanGames(rows pgx.Rows) ([]*domain.Game, error) {
     defer rows.Close()
 
     var out []*domain.Game
@@ -119,7 +394,7 @@ func (r *GameRepo) ListActiveGamesByUser(ctx context.Context, userID uuid.UUID)
         var g domain.Game
         var boardStr string
         var status string
-        if err := rows.Scan(&g.ID, &g.PlayerX, &g.PlayerO, &boardStr, &g.NextTurn, &status, &g.WinnerUserID, &g.DrawOfferedBy, &g.CreatedAt, &g.UpdatedAt); err != nil {
+        if err := rows.Scan(&g.ID, &g.PlayerX, &g.PlayerO, &boardStr, &g.NextTurn, &status, &g.WinnerUserID, &g.DrawOfferedBy, &g.Rated, &g.EndReason, &g.Variant, &g.TimeControl, &g.CreatedAt, &g.UpdatedAt); err != nil {
             return nil, err
         }
         board, err := domain.StringToBoard(boardStr)
@@ -130,25 +405,156 @@ func (r *GameRepo) ListActiveGamesByUser(ctx context.Context, userID uuid.UUID)
         g.Status = domain.GameStatus(status)
         out = append(out, &g)
     }
+    return out, rows.Err()
+}
+
+type GameEventRepo struct {
+    db *pgxpool.Pool
+}
+
+func NewGameEventRepo(db *pgxpool.Pool) *GameEventRepo {
+    return &GameEventRepo{db: db}
+}
+
+func (r *GameEventRepo) AppendGameEvent(ctx context.Context, event *domain.GameEvent) error {
+    tx, err := r.db.Begin(ctx)
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback(ctx)
+
+    var gameID uuid.UUID
+    if err := tx.QueryRow(ctx, `
+        SELECT id FROM games WHERE id = $1 FOR UPDATE
+    `, event.GameID).Scan(&gameID); err != nil {
+        return domain.ErrNotFound
+    }
+    if err := tx.QueryRow(ctx, `
+        INSERT INTO game_events (game_id, seq, type, payload, created_at)
+        SELECT $1, COALESCE(MAX(seq), 0) + 1, $2, $3, $4
+        FROM game_events
+        WHERE game_id = $1
+        RETURNING seq
+    `, event.GameID, event.Type, event.Payload, event.CreatedAt).Scan(&event.Seq); err != nil {
+        return err
+    }
+    return tx.Commit(ctx)
+}
+
+func (r *GameEventRepo) ListGameEventsAfter(ctx context.Context, gameID uuid.UUID, afterSeq int64, limit int) ([]*domain.GameEvent, error) {
+    rows, err := r.db.Query(ctx, `
+        SELECT game_id, seq, type, payload, created_at
+        FROM game_events
+        WHERE game_id = $1 AND seq > $2
+        ORDER BY seq
+        LIMIT $3
+    `, gameID, afterSeq, limit)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var out []*domain.GameEvent
+    for rows.Next() {
+        var e domain.GameEvent
+        if err := rows.Scan(&e.GameID, &e.Seq, &e.Type, &e.Payload, &e.CreatedAt); err != nil {
+            return nil, err
+        }
+        out = append(out, &e)
+    }
     return out, nil
 }
 
-func (r *GameRepo) AddMove(ctx context.Context, move *domain.GameMove) error {
+func (r *GameEventRepo) LastGameEventSeq(ctx context.Context, gameID uuid.UUID) (int64, error) {
+    var seq int64
+    err := r.db.QueryRow(ctx, `
+        SELECT COALESCE(MAX(seq), 0)
+        FROM game_events
+        WHERE game_id = $1
+    `, gameID).Scan(&seq)
+    return seq, err
+}
+
+type FriendRepo struct {
+    db *pgxpool.Pool
+}
+
+func NewFriendRepo(db *pgxpool.Pool) *FriendRepo {
+    return &FriendRepo{db: db}
+}
+
+func (r *FriendRepo) CreateFriendship(ctx context.Context, f *domain.Friendship) error {
     _, err := r.db.Exec(ctx, `
-        INSERT INTO game_moves (game_id, user_id, position, symbol, created_at)
+        INSERT INTO friendships (requester_id, addressee_id, status, created_at, updated_at)
         VALUES ($1,$2,$3,$4,$5)
-    `, move.GameID, move.UserID, move.Position, move.Symbol, move.CreatedAt)
+    `, f.RequesterID, f.AddresseeID, string(f.Status), f.CreatedAt, f.UpdatedAt)
     return err
 }
 
-func (r *GameRepo) AddMessage(ctx context.Context, msg *domain.GameMessage) error {
+func (r *FriendRepo) GetFriendship(ctx context.Context, userA, userB uuid.UUID) (*domain.Friendship, error) {
+    row := r.db.QueryRow(ctx, `
+        SELECT requester_id, addressee_id, status, created_at, updated_at
+        FROM friendships
+        WHERE (requester_id = $1 AND addressee_id = $2) OR (requester_id = $2 AND addressee_id = $1)
+    `, userA, userB)
+
+    var f domain.Friendship
+    var status string
+    if err := row.Scan(&f.RequesterID, &f.AddresseeID, &status, &f.CreatedAt, &f.UpdatedAt); err != nil {
+        return nil, domain.ErrNotFound
+    }
+    f.Status = domain.FriendshipStatus(status)
+    return &f, nil
+}
+
+func (r *FriendRepo) UpdateFriendship(ctx context.Context, f *domain.Friendship) error {
     _, err := r.db.Exec(ctx, `
-        INSERT INTO game_messages (game_id, user_id, message, created_at)
-        VALUES ($1,$2,$3,$4)
-    `, msg.GameID, msg.UserID, msg.Message, msg.CreatedAt)
+        UPDATE friendships
+        SET status=$3, updated_at=$4
+        WHERE requester_id=$1 AND addressee_id=$2
+    `, f.RequesterID, f.AddresseeID, string(f.Status), f.UpdatedAt)
     return err
 }
 
+func (r *FriendRepo) DeleteFriendship(ctx context.Context, userA, userB uuid.UUID) error {
+    tag, err := r.db.Exec(ctx, `
+        DELETE FROM friendships
+        WHERE (requester_id = $1 AND addressee_id = $2) OR (requester_id = $2 AND addressee_id = $1)
+    `, userA, userB)
+    if err != nil {
+        return err
+    }
+    if tag.RowsAffected() == 0 {
+        return domain.ErrNotFound
+    }
+    return nil
+}
+
+func (r *FriendRepo) ListFriendshipsByUser(ctx context.Context, userID uuid.UUID) ([]*domain.Friendship, error) {
+    rows, err := r.db.Query(ctx, `
+        SELECT requester_id, addressee_id, status, created_at, updated_at
+        FROM friendships
+        WHERE requester_id = $1 OR addressee_id = $1
+        ORDER BY updated_at DESC
+    `, userID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var out []*domain.Friendship
+    for rows.Next() {
+        var f domain.Friendship
+        var status string
+        if err := rows.Scan(&f.RequesterID, &f.AddresseeID, &status, &f.CreatedAt, &f.UpdatedAt); err != nil {
+            return nil, err
+        }
+        f.Status = domain.FriendshipStatus(status)
+        out = append(out, &f)
+    }
+    return out, nil
+}
+
 func NewPool(ctx context.Context, conn string, max int32) (*pgxpool.Pool, error) {
     cfg, err := pgxpool.ParseConfig(conn)
     if err != nil {
@@ -160,3 +566,823 @@ func NewPool(ctx context.Context, conn string, max int32) (*pgxpool.Pool, error)
     cfg.MaxConnLifetime = 2 * time.Hour
     return pgxpool.NewWithConfig(ctx, cfg)
 }
+
+type RefreshTokenRepo struct {
+    db *pgxpool.Pool
+}
+
+func NewRefreshTokenRepo(db *pgxpool.Pool) *RefreshTokenRepo {
+    return &RefreshTokenRepo{db: db}
+}
+
+func (r *RefreshTokenRepo) CreateRefreshToken(ctx context.Context, token *domain.RefreshToken) error {
+    _, err := r.db.Exec(ctx, `
+        INSERT INTO refresh_tokens (id, family_id, user_id, token_hash, expires_at, created_at)
+        VALUES ($1,$2,$3,$4,$5,$6)
+    `, token.ID, token.FamilyID, token.UserID, token.TokenHash, token.ExpiresAt, token.CreatedAt)
+    return err
+}
+
+func (r *RefreshTokenRepo) GetRefreshTokenByHash(ctx context.Context, hash string) (*domain.RefreshToken, error) {
+    row := r.db.QueryRow(ctx, `
+        SELECT id, family_id, user_id, token_hash, expires_at, created_at, rotated_at, revoked_at
+        FROM refresh_tokens
+        WHERE token_hash = $1
+    `, hash)
+
+    var t domain.RefreshToken
+    if err := row.Scan(&t.ID, &t.FamilyID, &t.UserID, &t.TokenHash, &t.ExpiresAt, &t.CreatedAt, &t.RotatedAt, &t.RevokedAt); err != nil {
+        return nil, domain.ErrNotFound
+    }
+    return &t, nil
+}
+
+func (r *RefreshTokenRepo) MarkRefreshTokenRotated(ctx context.Context, id uuid.UUID, at time.Time) (bool, error) {
+    tag, err := r.db.Exec(ctx, `
+        UPDATE refresh_tokens
+        SET rotated_at = $2
+        WHERE id = $1 AND rotated_at IS NULL
+    `, id, at)
+    if err != nil {
+        return false, err
+    }
+    return tag.RowsAffected() == 1, nil
+}
+
+func (r *RefreshTokenRepo) RevokeTokenFamily(ctx context.Context, familyID uuid.UUID, at time.Time) error {
+    _, err := r.db.Exec(ctx, `
+        UPDATE refresh_tokens
+        SET revoked_at = $2
+        WHERE family_id = $1 AND revoked_at IS NULL
+    `, familyID, at)
+    return err
+}
+
+func (r *RefreshTokenRepo) IsTokenFamilyRevoked(ctx context.Context, familyID uuid.UUID) (bool, error) {
+    row := r.db.QueryRow(ctx, `
+        SELECT COUNT(*), COUNT(revoked_at)
+        FROM refresh_tokens
+        WHERE family_id = $1
+    `, familyID)
+
+    var total, revoked int64
+    if err := row.Scan(&total, &revoked); err != nil {
+        return false, err
+    }
+    return total == 0 || revoked > 0, nil
+}
+
+func (r *RefreshTokenRepo) RevokeUserTokenFamilies(ctx context.Context, userID, except uuid.UUID, at time.Time) ([]uuid.UUID, error) {
+    rows, err := r.db.Query(ctx, `
+        UPDATE refresh_tokens
+        SET revoked_at = $3
+        WHERE user_id = $1 AND family_id <> $2 AND revoked_at IS NULL
+        RETURNING family_id
+    `, userID, except, at)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    seen := make(map[uuid.UUID]bool)
+    var out []uuid.UUID
+    for rows.Next() {
+        var familyID uuid.UUID
+        if err := rows.Scan(&familyID); err != nil {
+            return nil, err
+        }
+        if !seen[familyID] {
+            seen[familyID] = true
+            out = append(out, familyID)
+        }
+    }
+    return out, nil
+}
+
+func (r *RefreshTokenRepo) GetTokenFamilyStart(ctx context.Context, familyID uuid.UUID) (time.Time, error) {
+    row := r.db.QueryRow(ctx, `
+        SELECT MIN(created_at)
+        FROM refresh_tokens
+        WHERE family_id = $1
+    `, familyID)
+
+    var started *time.Time
+    if err := row.Scan(&started); err != nil {
+        return time.Time{}, err
+    }
+    if started == nil {
+        return time.Time{}, domain.ErrNotFound
+    }
+    return *started, nil
+}
+
+type IdentityRepo struct {
+    db *pgxpool.Pool
+}
+
+func NewIdentityRepo(db *pgxpool.Pool) *IdentityRepo {
+    return &IdentityRepo{db: db}
+}
+
+func (r *IdentityRepo) CreateIdentity(ctx context.Context, identity *domain.ExternalIdentity) error {
+    tag, err := r.db.Exec(ctx, `
+        INSERT INTO external_identities (provider, subject, user_id, email, created_at)
+        VALUES ($1,$2,$3,$4,$5)
+        ON CONFLICT (provider, subject) DO NOTHING
+    `, identity.Provider, identity.Subject, identity.UserID, identity.Email, identity.CreatedAt)
+    if err != nil {
+        return err
+    }
+    if tag.RowsAffected() == 0 {
+        return domain.ErrIdentityLinked
+    }
+    return nil
+}
+
+func (r *IdentityRepo) GetIdentity(ctx context.Context, provider, subject string) (*domain.ExternalIdentity, error) {
+    row := r.db.QueryRow(ctx, `
+        SELECT provider, subject, user_id, email, created_at
+        FROM external_identities
+        WHERE provider = $1 AND subject = $2
+    `, provider, subject)
+
+    var identity domain.ExternalIdentity
+    if err := row.Scan(&identity.Provider, &identity.Subject, &identity.UserID, &identity.Email, &identity.CreatedAt); err != nil {
+        return nil, domain.ErrNotFound
+    }
+    return &identity, nil
+}
+
+func (r *IdentityRepo) ListIdentitiesByUser(ctx context.Context, userID uuid.UUID) ([]*domain.ExternalIdentity, error) {
+    rows, err := r.db.Query(ctx, `
+        SELECT provider, subject, user_id, email, created_at
+        FROM external_identities
+        WHERE user_id = $1
+        ORDER BY created_at
+    `, userID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var out []*domain.ExternalIdentity
+    for rows.Next() {
+        var identity domain.ExternalIdentity
+        if err := rows.Scan(&identity.Provider, &identity.Subject, &identity.UserID, &identity.Email, &identity.CreatedAt); err != nil {
+            return nil, err
+        }
+        out = append(out, &identity)
+    }
+    return out, nil
+}
+
+type PasswordResetRepo struct {
+    db *pgxpool.Pool
+}
+
+func NewPasswordResetRepo(db *pgxpool.Pool) *PasswordResetRepo {
+    return &PasswordResetRepo{db: db}
+}
+
+func (r *PasswordResetRepo) CreateResetToken(ctx context.Context, token *domain.PasswordResetToken) error {
+    _, err := r.db.Exec(ctx, `
+        INSERT INTO password_reset_tokens (id, user_id, token_hash, expires_at, created_at)
+        VALUES ($1,$2,$3,$4,$5)
+    `, token.ID, token.UserID, token.TokenHash, token.ExpiresAt, token.CreatedAt)
+    return err
+}
+
+func (r *PasswordResetRepo) GetResetTokenByHash(ctx context.Context, hash string) (*domain.PasswordResetToken, error) {
+    row := r.db.QueryRow(ctx, `
+        SELECT id, user_id, token_hash, expires_at, created_at, used_at
+        FROM password_reset_tokens
+        WHERE token_hash = $1
+    `, hash)
+
+    var t domain.PasswordResetToken
+    if err := row.Scan(&t.ID, &t.UserID, &t.TokenHash, &t.ExpiresAt, &t.CreatedAt, &t.UsedAt); err != nil {
+        return nil, domain.ErrNotFound
+    }
+    return &t, nil
+}
+
+func (r *PasswordResetRepo) MarkResetTokenUsed(ctx context.Context, id uuid.UUID, at time.Time) (bool, error) {
+    tag, err := r.db.Exec(ctx, `
+        UPDATE password_reset_tokens
+        SET used_at = $2
+        WHERE id = $1 AND used_at IS NULL
+    `, id, at)
+    if err != nil {
+        return false, err
+    }
+    return tag.RowsAffected() == 1, nil
+}
+
+func (r *PasswordResetRepo) InvalidateResetTokens(ctx context.Context, userID uuid.UUID, at time.Time) error {
+    _, err := r.db.Exec(ctx, `
+        UPDATE password_reset_tokens
+        SET used_at = $2
+        WHERE user_id = $1 AND used_at IS NULL
+    `, userID, at)
+    return err
+}
+
+type TwoFactorRepo struct {
+    db *pgxpool.Pool
+}
+
+func NewTwoFactorRepo(db *pgxpool.Pool) *TwoFactorRepo {
+    return &TwoFactorRepo{db: db}
+}
+
+func (r *TwoFactorRepo) GetTOTP(ctx context.Context, userID uuid.UUID) (*domain.TOTPSecret, error) {
+    row := r.db.QueryRow(ctx, `
+        SELECT user_id, secret, created_at, enabled_at, last_used_step
+        FROM user_totp
+        WHERE user_id = $1
+    `, userID)
+
+    var s domain.TOTPSecret
+    if err := row.Scan(&s.UserID, &s.Secret, &s.CreatedAt, &s.EnabledAt, &s.LastUsedStep); err != nil {
+        return nil, domain.ErrNotFound
+    }
+    return &s, nil
+}
+
+func (r *TwoFactorRepo) SaveTOTP(ctx context.Context, secret *domain.TOTPSecret) error {
+    _, err := r.db.Exec(ctx, `
+        INSERT INTO user_totp (user_id, secret, created_at, enabled_at, last_used_step)
+        VALUES ($1,$2,$3,$4,$5)
+        ON CONFLICT (user_id) DO UPDATE
+        SET secret = EXCLUDED.secret,
+            created_at = EXCLUDED.created_at,
+            enabled_at = EXCLUDED.enabled_at,
+            last_used_step = EXCLUDED.last_used_step
+    `, secret.UserID, secret.Secret, secret.CreatedAt, secret.EnabledAt, secret.LastUsedStep)
+    return err
+}
+
+func (r *TwoFactorRepo) AdvanceTOTPStep(ctx context.Context, userID uuid.UUID, from, to int64) (bool, error) {
+    tag, err := r.db.Exec(ctx, `
+        UPDATE user_totp
+        SET last_used_step = $3
+        WHERE user_id = $1 AND last_used_step = $2
+    `, userID, from, to)
+    if err != nil {
+        return false, err
+    }
+    return tag.RowsAffected() == 1, nil
+}
+
+func (r *TwoFactorRepo) DeleteTOTP(ctx context.Context, userID uuid.UUID) error {
+    _, err := r.db.Exec(ctx, `DELETE FROM user_totp WHERE user_id = $1`, userID)
+    return err
+}
+
+func (r *TwoFactorRepo) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, hashes []string) error {
+    tx, err := r.db.Begin(ctx)
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback(ctx)
+
+    if _, err := tx.Exec(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+        return err
+    }
+    for _, h := range hashes {
+        if _, err := tx.Exec(ctx, `
+            INSERT INTO user_recovery_codes (user_id, code_hash)
+            VALUES ($1,$2)
+        `, userID, h); err != nil {
+            return err
+        }
+    }
+    return tx.Commit(ctx)
+}
+
+func (r *TwoFactorRepo) UseRecoveryCode(ctx context.Context, userID uuid.UUID, hash string, at time.Time) (bool, error) {
+    tag, err := r.db.Exec(ctx, `
+        UPDATE user_recovery_codes
+        SET used_at = $3
+        WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+    `, userID, hash, at)
+    if err != nil {
+        return false, err
+    }
+    return tag.RowsAffected() == 1, nil
+}
+
+type ReportRepo struct {
+    db *pgxpool.Pool
+}
+
+func NewReportRepo(db *pgxpool.Pool) *ReportRepo {
+    return &ReportRepo{db: db}
+}
+
+func (r *ReportRepo) CreateReport(ctx context.Context, report *domain.Report) error {
+    _, err := r.db.Exec(ctx, `
+        INSERT INTO reports (id, reporter_id, user_id, game_id, reason, status, created_at)
+        VALUES ($1,$2,$3,$4,$5,$6,$7)
+    `, report.ID, report.ReporterID, report.UserID, report.GameID, report.Reason, string(report.Status), report.CreatedAt)
+    return err
+}
+
+func (r *ReportRepo) ListReports(ctx context.Context, status domain.ReportStatus, limit int) ([]*domain.Report, error) {
+    rows, err := r.db.Query(ctx, `
+        SELECT id, reporter_id, user_id, game_id, reason, status, resolution, resolved_by, created_at, resolved_at
+        FROM reports
+        WHERE $1 = '' OR status = $1
+        ORDER BY created_at DESC
+        LIMIT $2
+    `, string(status), limit)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var out []*domain.Report
+    for rows.Next() {
+        var rep domain.Report
+        var st string
+        if err := rows.Scan(&rep.ID, &rep.ReporterID, &rep.UserID, &rep.GameID, &rep.Reason, &st, &rep.Resolution, &rep.ResolvedBy, &rep.CreatedAt, &rep.ResolvedAt); err != nil {
+            return nil, err
+        }
+        rep.Status = domain.ReportStatus(st)
+        out = append(out, &rep)
+    }
+    return out, nil
+}
+
+func (r *ReportRepo) ResolveReport(ctx context.Context, id, resolvedBy uuid.UUID, resolution string, at time.Time) (bool, error) {
+    tag, err := r.db.Exec(ctx, `
+        UPDATE reports
+        SET status = 'resolved', resolution = $3, resolved_by = $2, resolved_at = $4
+        WHERE id = $1 AND status = 'open'
+    `, id, resolvedBy, resolution, at)
+    if err != nil {
+        return false, err
+    }
+    return tag.RowsAffected() == 1, nil
+}
+
+type AuditRepo struct {
+    db *pgxpool.Pool
+}
+
+func NewAuditRepo(db *pgxpool.Pool) *AuditRepo {
+    return &AuditRepo{db: db}
+}
+
+func (r *AuditRepo) AppendAuditEvent(ctx context.Context, event *domain.AuditEvent) error {
+    _, err := r.db.Exec(ctx, `
+        INSERT INTO audit_log (id, action, actor_id, target_id, ip, detail, created_at)
+        VALUES ($1,$2,$3,$4,$5,$6,$7)
+    `, event.ID, string(event.Action), event.ActorID, event.TargetID, event.IP, event.Detail, event.CreatedAt)
+    return err
+}
+
+func (r *AuditRepo) ListAuditEvents(ctx context.Context, filter domain.AuditFilter) ([]*domain.AuditEvent, error) {
+    rows, err := r.db.Query(ctx, `
+        SELECT id, action, actor_id, target_id, ip, detail, created_at
+        FROM audit_log
+        WHERE ($1 = '' OR action = $1)
+          AND ($2::uuid IS NULL OR actor_id = $2)
+          AND ($3::uuid IS NULL OR target_id = $3)
+          AND ($4::timestamptz IS NULL OR created_at >= $4)
+          AND ($5::timestamptz IS NULL OR created_at < $5)
+        ORDER BY created_at DESC
+        LIMIT $6
+    `, string(filter.Action), filter.ActorID, filter.TargetID, filter.Since, filter.Until, filter.Limit)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var out []*domain.AuditEvent
+    for rows.Next() {
+        var e domain.AuditEvent
+        var action string
+        if err := rows.Scan(&e.ID, &action, &e.ActorID, &e.TargetID, &e.IP, &e.Detail, &e.CreatedAt); err != nil {
+            return nil, err
+        }
+        e.Action = domain.AuditAction(action)
+        out = append(out, &e)
+    }
+    return out, nil
+}
+
+type StatsRepo struct {
+    db *pgxpool.Pool
+}
+
+func NewStatsRepo(db *pgxpool.Pool) *StatsRepo {
+    return &StatsRepo{db: db}
+}
+
+func (r *StatsRepo) GetPlayerStats(ctx context.Context, userID uuid.UUID) (*domain.PlayerStats, error) {
+    row := r.db.QueryRow(ctx, `
+        SELECT user_id, wins, losses, draws, games_as_x, wins_as_x, games_as_o, wins_as_o, current_streak, best_streak, updated_at
+        FROM player_stats
+        WHERE user_id = $1
+    `, userID)
+
+    var st domain.PlayerStats
+    if err := row.Scan(&st.UserID, &st.Wins, &st.Losses, &st.Draws, &st.GamesAsX, &st.WinsAsX, &st.GamesAsO, &st.WinsAsO, &st.CurrentStreak, &st.BestStreak, &st.UpdatedAt); err != nil {
+        return nil, domain.ErrNotFound
+    }
+    return &st, nil
+}
+
+func (r *StatsRepo) SaveStatsUpdate(ctx context.Context, update *domain.StatsUpdate) error {
+    tx, err := r.db.Begin(ctx)
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback(ctx)
+
+    for _, stats := range update.Stats {
+        if _, err := tx.Exec(ctx, `
+            INSERT INTO player_stats (user_id, wins, losses, draws, games_as_x, wins_as_x, games_as_o, wins_as_o, current_streak, best_streak, updated_at)
+            VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
+            ON CONFLICT (user_id) DO UPDATE
+            SET wins = EXCLUDED.wins, losses = EXCLUDED.losses, draws = EXCLUDED.draws,
+                games_as_x = EXCLUDED.games_as_x, wins_as_x = EXCLUDED.wins_as_x,
+                games_as_o = EXCLUDED.games_as_o, wins_as_o = EXCLUDED.wins_as_o,
+                current_streak = EXCLUDED.current_streak, best_streak = EXCLUDED.best_streak,
+                updated_at = EXCLUDED.updated_at
+        `, stats.UserID, stats.Wins, stats.Losses, stats.Draws, stats.GamesAsX, stats.WinsAsX, stats.GamesAsO, stats.WinsAsO, stats.CurrentStreak, stats.BestStreak, stats.UpdatedAt); err != nil {
+            return err
+        }
+    }
+    for _, gameID := range update.Cleared {
+        if _, err := tx.Exec(ctx, `
+            DELETE FROM rating_changes
+            WHERE game_id = $1
+        `, gameID); err != nil {
+            return err
+        }
+    }
+    for _, change := range update.Changes {
+        if _, err := tx.Exec(ctx, `
+            INSERT INTO rating_changes (game_id, user_id, variant, time_control, rating_before, rating_after, created_at)
+            VALUES ($1,$2,$3,$4,$5,$6,$7)
+            ON CONFLICT (game_id, user_id) DO UPDATE
+            SET variant = EXCLUDED.variant, time_control = EXCLUDED.time_control, rating_before = EXCLUDED.rating_before,
+                rating_after = EXCLUDED.rating_after, created_at = EXCLUDED.created_at
+        `, change.GameID, change.UserID, change.Variant, change.TimeControl, change.Before, change.After, change.CreatedAt); err != nil {
+            return err
+        }
+    }
+    for _, rating := range update.Ratings {
+        if _, err := tx.Exec(ctx, `
+            INSERT INTO ratings (user_id, variant, time_control, rating, games, updated_at)
+            VALUES ($1,$2,$3,$4,$5,$6)
+            ON CONFLICT (user_id, variant, time_control) DO UPDATE
+            SET rating = EXCLUDED.rating, games = EXCLUDED.games, updated_at = EXCLUDED.updated_at
+        `, rating.UserID, rating.Variant, rating.TimeControl, rating.Rating, rating.Games, rating.UpdatedAt); err != nil {
+            return err
+        }
+    }
+    return tx.Commit(ctx)
+}
+
+func (r *StatsRepo) GetRating(ctx context.Context, userID uuid.UUID, variant, timeControl string) (*domain.Rating, error) {
+    row := r.db.QueryRow(ctx, `
+        SELECT user_id, variant, time_control, rating, games, updated_at
+        FROM ratings
+        WHERE user_id = $1 AND variant = $2 AND time_control = $3
+    `, userID, variant, timeControl)
+
+    var rating domain.Rating
+    if err := row.Scan(&rating.UserID, &rating.Variant, &rating.TimeControl, &rating.Rating, &rating.Games, &rating.UpdatedAt); err != nil {
+        return nil, domain.ErrNotFound
+    }
+    return &rating, nil
+}
+
+func (r *StatsRepo) ListRatings(ctx context.Context, userID uuid.UUID) ([]*domain.Rating, error) {
+    rows, err := r.db.Query(ctx, `
+        SELECT user_id, variant, time_control, rating, games, updated_at
+        FROM ratings
+        WHERE user_id = $1
+        ORDER BY variant, time_control
+    `, userID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var out []*domain.Rating
+    for rows.Next() {
+        var rating domain.Rating
+        if err := rows.Scan(&rating.UserID, &rating.Variant, &rating.TimeControl, &rating.Rating, &rating.Games, &rating.UpdatedAt); err != nil {
+            return nil, err
+        }
+        out = append(out, &rating)
+    }
+    return out, nil
+}
+
+func (r *StatsRepo) ListRatingsByVariant(ctx context.Context, variant, timeControl string, minGames int) ([]*domain.Rating, error) {
+    rows, err := r.db.Query(ctx, `
+        SELECT user_id, variant, time_control, rating, games, updated_at
+        FROM ratings
+        WHERE variant = $1 AND time_control = $2 AND games >= $3
+        ORDER BY rating DESC
+    `, variant, timeControl, minGames)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var out []*domain.Rating
+    for rows.Next() {
+        var rating domain.Rating
+        if err := rows.Scan(&rating.UserID, &rating.Variant, &rating.TimeControl, &rating.Rating, &rating.Games, &rating.UpdatedAt); err != nil {
+            return nil, err
+        }
+        out = append(out, &rating)
+    }
+    return out, nil
+}
+
+type AchievementRepo struct {
+    db *pgxpool.Pool
+}
+
+func NewAchievementRepo(db *pgxpool.Pool) *AchievementRepo {
+    return &AchievementRepo{db: db}
+}
+
+func (r *AchievementRepo) AwardAchievement(ctx context.Context, achievement *domain.UserAchievement) (bool, error) {
+    tag, err := r.db.Exec(ctx, `
+        INSERT INTO user_achievements (user_id, code, game_id, unlocked_at)
+        VALUES ($1,$2,$3,$4)
+        ON CONFLICT (user_id, code) DO NOTHING
+    `, achievement.UserID, string(achievement.Code), achievement.GameID, achievement.UnlockedAt)
+    if err != nil {
+        return false, err
+    }
+    return tag.RowsAffected() == 1, nil
+}
+
+func (r *AchievementRepo) ListAchievements(ctx context.Context, userID uuid.UUID) ([]*domain.UserAchievement, error) {
+    rows, err := r.db.Query(ctx, `
+        SELECT user_id, code, game_id, unlocked_at
+        FROM user_achievements
+        WHERE user_id = $1
+        ORDER BY unlocked_at
+    `, userID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var out []*domain.UserAchievement
+    for rows.Next() {
+        var a domain.UserAchievement
+        var code string
+        if err := rows.Scan(&a.UserID, &code, &a.GameID, &a.UnlockedAt); err != nil {
+            return nil, err
+        }
+        a.Code = domain.AchievementCode(code)
+        out = append(out, &a)
+    }
+    return out, nil
+}
+
+type SeasonRepo struct {
+    db *pgxpool.Pool
+}
+
+func NewSeasonRepo(db *pgxpool.Pool) *SeasonRepo {
+    return &SeasonRepo{db: db}
+}
+
+func (r *SeasonRepo) OpenSeason(ctx context.Context, season *domain.Season) error {
+    tx, err := r.db.Begin(ctx)
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback(ctx)
+
+    if err := openSeason(ctx, tx, season); err != nil {
+        return err
+    }
+    return tx.Commit(ctx)
+}
+
+func (r *SeasonRepo) RolloverSeason(ctx context.Context, closingID uuid.UUID, standings []*domain.SeasonStanding, next *domain.Season) error {
+    tx, err := r.db.Begin(ctx)
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback(ctx)
+
+    if err := closeSeason(ctx, tx, closingID, next.StartsAt, standings); err != nil {
+        return err
+    }
+    if err := openSeason(ctx, tx, next); err != nil {
+        return err
+    }
+    return tx.Commit(ctx)
+}
+
+func openSeason(ctx context.Context, tx pgx.Tx, season *domain.Season) error {
+    var open bool
+    if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM seasons WHERE status = 'open')`).Scan(&open); err != nil {
+        return err
+    }
+    if open {
+        return domain.ErrSeasonOpen
+    }
+
+    if _, err := tx.Exec(ctx, `
+        INSERT INTO seasons (id, name, status, starts_at, ends_at, closed_at, carry_over)
+        VALUES ($1,$2,$3,$4,$5,$6,$7)
+    `, season.ID, season.Name, string(season.Status), season.StartsAt, season.EndsAt, season.ClosedAt, season.CarryOver); err != nil {
+        return err
+    }
+
+    _, err := tx.Exec(ctx, `
+        UPDATE ratings
+        SET rating = $1 + ROUND((rating - $1)::numeric * $2::numeric)::int, updated_at = $3
+    `, domain.DefaultRating, season.CarryOver, season.StartsAt)
+    return err
+}
+
+func (r *SeasonRepo) GetSeason(ctx context.Context, id uuid.UUID) (*domain.Season, error) {
+    row := r.db.QueryRow(ctx, `
+        SELECT id, name, status, starts_at, ends_at, closed_at, carry_over
+        FROM seasons
+        WHERE id = $1
+    `, id)
+
+    var season domain.Season
+    var status string
+    if err := row.Scan(&season.ID, &season.Name, &status, &season.StartsAt, &season.EndsAt, &season.ClosedAt, &season.CarryOver); err != nil {
+        return nil, domain.ErrNotFound
+    }
+    season.Status = domain.SeasonStatus(status)
+    return &season, nil
+}
+
+func (r *SeasonRepo) GetOpenSeason(ctx context.Context) (*domain.Season, error) {
+    row := r.db.QueryRow(ctx, `
+        SELECT id, name, status, starts_at, ends_at, closed_at, carry_over
+        FROM seasons
+        WHERE status = 'open'
+    `)
+
+    var season domain.Season
+    var status string
+    if err := row.Scan(&season.ID, &season.Name, &status, &season.StartsAt, &season.EndsAt, &season.ClosedAt, &season.CarryOver); err != nil {
+        return nil, domain.ErrNotFound
+    }
+    season.Status = domain.SeasonStatus(status)
+    return &season, nil
+}
+
+func (r *SeasonRepo) ListSeasons(ctx context.Context) ([]*domain.Season, error) {
+    rows, err := r.db.Query(ctx, `
+        SELECT id, name, status, starts_at, ends_at, closed_at, carry_over
+        FROM seasons
+        ORDER BY starts_at DESC
+    `)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var out []*domain.Season
+    for rows.Next() {
+        var season domain.Season
+        var status string
+        if err := rows.Scan(&season.ID, &season.Name, &status, &season.StartsAt, &season.EndsAt, &season.ClosedAt, &season.CarryOver); err != nil {
+            return nil, err
+        }
+        season.Status = domain.SeasonStatus(status)
+        out = append(out, &season)
+    }
+    return out, nil
+}
+
+func (r *SeasonRepo) CloseSeason(ctx context.Context, id uuid.UUID, closedAt time.Time, standings []*domain.SeasonStanding) error {
+    tx, err := r.db.Begin(ctx)
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback(ctx)
+
+    if err := closeSeason(ctx, tx, id, closedAt, standings); err != nil {
+        return err
+    }
+    return tx.Commit(ctx)
+}
+
+func closeSeason(ctx context.Context, tx pgx.Tx, id uuid.UUID, closedAt time.Time, standings []*domain.SeasonStanding) error {
+    tag, err := tx.Exec(ctx, `
+        UPDATE seasons
+        SET status = 'closed', closed_at = $2
+        WHERE id = $1 AND status = 'open'
+    `, id, closedAt)
+    if err != nil {
+        return err
+    }
+    if tag.RowsAffected() == 0 {
+        return domain.ErrNotFound
+    }
+
+    for _, st := range standings {
+        if _, err := tx.Exec(ctx, `
+            INSERT INTO season_standings (season_id, user_id, rank, username, rating, games, wins, draws, losses, reward)
+            VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)
+        `, id, st.UserID, st.Rank, st.Username, st.Rating, st.Games, st.Wins, st.Draws, st.Losses, st.Reward); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (r *SeasonRepo) ListStandings(ctx context.Context, seasonID uuid.UUID) ([]*domain.SeasonStanding, error) {
+    rows, err := r.db.Query(ctx, `
+        SELECT season_id, user_id, rank, username, rating, games, wins, draws, losses, reward
+        FROM season_standings
+        WHERE season_id = $1
+        ORDER BY rank, username
+    `, seasonID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var out []*domain.SeasonStanding
+    for rows.Next() {
+        var st domain.SeasonStanding
+        if err := rows.Scan(&st.SeasonID, &st.UserID, &st.Rank, &st.Username, &st.Rating, &st.Games, &st.Wins, &st.Draws, &st.Losses, &st.Reward); err != nil {
+            return nil, err
+        }
+        out = append(out, &st)
+    }
+    return out, nil
+}
+
+type AccountRepo struct {
+    db *pgxpool.Pool
+}
+
+func NewAccountRepo(db *pgxpool.Pool) *AccountRepo {
+    return &AccountRepo{db: db}
+}
+
+func (r *AccountRepo) AnonymizeAccount(ctx context.Context, userID uuid.UUID, username, placeholder string, at time.Time) error {
+    tx, err := r.db.Begin(ctx)
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback(ctx)
+
+    tag, err := tx.Exec(ctx, `
+        UPDATE users
+        SET username = $2, password_hash = '', role = 'player', is_guest = FALSE,
+            banned = FALSE, suspended_until = NULL, suspension_reason = '', deleted_at = $3
+        WHERE id = $1 AND deleted_at IS NULL
+    `, userID, username, at)
+    if err != nil {
+        return err
+    }
+    if tag.RowsAffected() == 0 {
+        return domain.ErrNotFound
+    }
+
+    if _, err := tx.Exec(ctx, `UPDATE password_reset_tokens SET used_at = $2 WHERE user_id = $1 AND used_at IS NULL`, userID, at); err != nil {
+        return err
+    }
+    if _, err := tx.Exec(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+        return err
+    }
+    if _, err := tx.Exec(ctx, `DELETE FROM user_totp WHERE user_id = $1`, userID); err != nil {
+        return err
+    }
+    if _, err := tx.Exec(ctx, `DELETE FROM external_identities WHERE user_id = $1`, userID); err != nil {
+        return err
+    }
+    if _, err := tx.Exec(ctx, `DELETE FROM friendships WHERE requester_id = $1 OR addressee_id = $1`, userID); err != nil {
+        return err
+    }
+    if _, err := tx.Exec(ctx, `UPDATE game_messages SET message = $2 WHERE user_id = $1`, userID, placeholder); err != nil {
+        return err
+    }
+    if _, err := tx.Exec(ctx, `
+        UPDATE game_events
+        SET payload = jsonb_set(payload, '{message}', to_jsonb($2::text))
+        WHERE type = 'chat' AND payload->>'user_id' = $1
+    `, userID.String(), placeholder); err != nil {
+        return err
+    }
+    if _, err := tx.Exec(ctx, `UPDATE season_standings SET username = $2 WHERE user_id = $1`, userID, username); err != nil {
+        return err
+    }
+    return tx.Commit(ctx)
+}
@@ -1,81 +1,145 @@
 ﻿package ws
 
 import (
-    "encoding/json"
     "time"
 
     "github.com/gorilla/websocket"
     "github.com/google/uuid"
+    "xo-server/internal/domain"
 )
 
 type Client struct {
-    hub      *Hub
-    conn     *websocket.Conn
-    send     chan []byte
-    userID   uuid.UUID
-    username string
-    handler  *Handler
+    *outbox
+    hub       *Hub
+    conn      *websocket.Conn
+    userID    uuid.UUID
+    sessionID uuid.UUID
+    username  string
+    role      domain.Role
+    guest     bool
+    handler   *Handler
+    codec     Codec
 }
 
-const (
-    writeWait      = 10 * time.Second
-    pongWait       = 60 * time.Second
-    pingPeriod     = (pongWait * 9) / 10
-    maxMessageSize = 4096
-)
+func newClient(hub *Hub, conn *websocket.Conn, user *domain.User, sessionID uuid.UUID, handler *Handler) *Client {
+    return &Client{
+        outbox:    newOutbox(handler.opts.SendBufferSize, handler.opts.SlowConsumerTimeout),
+        hub:       hub,
+        conn:      conn,
+        userID:    user.ID,
+        sessionID: sessionID,
+        username:  user.Username,
+        role:      user.Role,
+        guest:     user.IsGuest,
+        handler:   handler,
+        codec:     codecFor(conn.Subprotocol()),
+    }
+}
+
+func (c *Client) UserID() uuid.UUID {
+    return c.userID
+}
+
+func (c *Client) SessionID() uuid.UUID {
+    return c.sessionID
+}
 
 func (c *Client) readPump() {
     defer func() {
-        c.hub.Unregister(c)
+        last := c.hub.Unregister(c)
         _ = c.conn.Close()
+        if last {
+            c.handler.handleDisconnect(c.userID)
+        }
     }()
 
-    c.conn.SetReadLimit(maxMessageSize)
-    _ = c.conn.SetReadDeadline(time.Now().Add(pongWait))
+    opts := c.handler.opts
+    c.conn.SetReadLimit(opts.MaxMessageSize)
+    _ = c.conn.SetReadDeadline(time.Now().Add(opts.PongWait))
     c.conn.SetPongHandler(func(string) error {
-        _ = c.conn.SetReadDeadline(time.Now().Add(pongWait))
+        _ = c.conn.SetReadDeadline(time.Now().Add(opts.PongWait))
         return nil
     })
 
     for {
-        _, message, err := c.conn.ReadMessage()
+        _, data, err := c.conn.ReadMessage()
         if err != nil {
             break
         }
 
-        var env Envelope
-        if err := json.Unmarshal(message, &env); err != nil {
-            sendError(c, "invalid message")
+        var req Request
+        if err := c.codec.Unmarshal(data, &req); err != nil {
+            sendError(c, "", CodeInvalidMessage, "invalid message")
             continue
         }
-        c.handler.handleMessage(c, env)
+        c.handler.handleMessage(c, req)
     }
 }
 
 func (c *Client) writePump() {
-    ticker := time.NewTicker(pingPeriod)
+    ticker := time.NewTicker(c.handler.opts.PongWait * 9 / 10)
     defer func() {
         ticker.Stop()
+        c.Close(websocket.CloseAbnormalClosure, "")
         _ = c.conn.Close()
     }()
 
     for {
         select {
-        case message, ok := <-c.send:
-            _ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-            if !ok {
-                _ = c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+        case message := <-c.send:
+            if err := c.write(message); err != nil {
                 return
             }
-
-            if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+            if !c.writePending() {
+                return
+            }
+        case <-c.wake:
+            if !c.writePending() {
                 return
             }
+        case <-c.done:
+            if c.closeCode != websocket.CloseTryAgainLater {
+                c.flush()
+            }
+            _ = c.conn.SetWriteDeadline(time.Now().Add(c.handler.opts.WriteWait))
+            _ = c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(c.closeCode, c.closeReason))
+            return
         case <-ticker.C:
-            _ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+            _ = c.conn.SetWriteDeadline(time.Now().Add(c.handler.opts.WriteWait))
             if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
                 return
             }
         }
     }
 }
+
+func (c *Client) writePending() bool {
+    for _, message := range c.takePending() {
+        if err := c.write(message); err != nil {
+            return false
+        }
+    }
+    return true
+}
+
+func (c *Client) write(message *Message) error {
+    data, err := message.Encode(c.codec)
+    if err != nil {
+        return err
+    }
+    _ = c.conn.SetWriteDeadline(time.Now().Add(c.handler.opts.WriteWait))
+    return c.conn.WriteMessage(c.codec.FrameType(), data)
+}
+
+func (c *Client) flush() {
+    for {
+        select {
+        case message := <-c.send:
+            if err := c.write(message); err != nil {
+                return
+            }
+        default:
+            return
+        }
+    }
+}
@@ -0,0 +1,82 @@
+﻿package ws
+
+import (
+    "encoding/json"
+    "sync"
+
+    "github.com/gorilla/websocket"
+    "github.com/vmihailenco/msgpack/v5"
+)
+
+type Codec interface {
+    Subprotocol() string
+    FrameType() int
+    Marshal(v interface{}) ([]byte, error)
+    Unmarshal(data []byte, v interface{}) error
+}
+
+const (
+    SubprotocolJSON    = "xo.json"
+    SubprotocolMsgPack = "xo.msgpack"
+)
+
+var codecs = map[string]Codec{
+    SubprotocolJSON:    jsonCodec{},
+    SubprotocolMsgPack: msgpackCodec{},
+}
+
+func codecFor(subprotocol string) Codec {
+    if c, ok := codecs[subprotocol]; ok {
+        return c
+    }
+    return jsonCodec{}
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Subprotocol() string { return SubprotocolJSON }
+
+func (jsonCodec) FrameType() int { return websocket.TextMessage }
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Subprotocol() string { return SubprotocolMsgPack }
+
+func (msgpackCodec) FrameType() int { return websocket.BinaryMessage }
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) { return msgpack.Marshal(v) }
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error { return msgpack.Unmarshal(data, v) }
+
+type Message struct {
+    env Envelope
+
+    mu      sync.Mutex
+    encoded map[string][]byte
+}
+
+func newMessage(env Envelope) *Message {
+    return &Message{env: env, encoded: make(map[string][]byte, len(codecs))}
+}
+
+func (m *Message) Type() string {
+    return m.env.Type
+}
+
+func (m *Message) Encode(c Codec) ([]byte, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    if data, ok := m.encoded[c.Subprotocol()]; ok {
+        return data, nil
+    }
+    data, err := c.Marshal(m.env)
+    if err != nil {
+        return nil, err
+    }
+    m.encoded[c.Subprotocol()] = data
+    return data, nil
+}
@@ -0,0 +1,57 @@
+﻿package ws
+
+import (
+    "errors"
+
+    "xo-server/internal/domain"
+)
+
+const (
+    CodeInvalidMessage   = "invalid_message"
+    CodeInvalidPayload   = "invalid_payload"
+    CodeInvalidGameID    = "invalid_game_id"
+    CodeInvalidUserID    = "invalid_user_id"
+    CodeUnknownType      = "unknown_type"
+    CodeInvalidInput     = "invalid_input"
+    CodeNotFound         = "not_found"
+    CodeUnauthorized     = "unauthorized"
+    CodeForbidden        = "forbidden"
+    CodeGameNotActive    = "game_not_active"
+    CodeNotYourTurn      = "not_your_turn"
+    CodePositionTaken    = "position_taken"
+    CodeInvalidPosition  = "invalid_position"
+    CodeAlreadyInQueue   = "already_in_queue"
+    CodeDrawNotOffered   = "draw_not_offered"
+    CodeNotFriends       = "not_friends"
+    CodeGuestNotAllowed  = "guest_not_allowed"
+    CodeAccountSuspended = "account_suspended"
+    CodeInternal         = "internal_error"
+)
+
+var errorCodes = []struct {
+    err  error
+    code string
+}{
+    {domain.ErrInvalidInput, CodeInvalidInput},
+    {domain.ErrNotFound, CodeNotFound},
+    {domain.ErrUnauthorized, CodeUnauthorized},
+    {domain.ErrForbidden, CodeForbidden},
+    {domain.ErrGameNotActive, CodeGameNotActive},
+    {domain.ErrNotYourTurn, CodeNotYourTurn},
+    {domain.ErrPositionTaken, CodePositionTaken},
+    {domain.ErrInvalidPosition, CodeInvalidPosition},
+    {domain.ErrAlreadyInQueue, CodeAlreadyInQueue},
+    {domain.ErrDrawNotOffered, CodeDrawNotOffered},
+    {domain.ErrNotFriends, CodeNotFriends},
+    {domain.ErrGuestNotAllowed, CodeGuestNotAllowed},
+    {domain.ErrAccountSuspended, CodeAccountSuspended},
+}
+
+func errorCode(err error) string {
+    for _, e := range errorCodes {
+        if errors.Is(err, e.err) {
+            return e.code
+        }
+    }
+    return CodeInternal
+}
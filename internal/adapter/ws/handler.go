@@ -3,233 +3,379 @@
 import (
     "context"
     "encoding/json"
+    "log"
     "net/http"
+    "strings"
     "time"
 
     "github.com/gorilla/websocket"
     "github.com/google/uuid"
+    "xo-server/internal/adapter/auth"
     "xo-server/internal/domain"
     "xo-server/internal/usecase"
 )
 
 type Handler struct {
     hub         *Hub
-    auth        usecase.TokenProvider
     games       usecase.GameService
     matchmaking usecase.MatchmakingService
+    friends     usecase.FriendService
+    events      usecase.GameEventService
+    opts        Options
+    upgrader    websocket.Upgrader
 }
 
-func NewHandler(hub *Hub, auth usecase.TokenProvider, games usecase.GameService, matchmaking usecase.MatchmakingService) *Handler {
-    return &Handler{hub: hub, auth: auth, games: games, matchmaking: matchmaking}
+type Options struct {
+    ReadBufferSize      int
+    WriteBufferSize     int
+    SendBufferSize      int
+    MaxMessageSize      int64
+    WriteWait           time.Duration
+    PongWait            time.Duration
+    SlowConsumerTimeout time.Duration
+    Compression         bool
+    CompressionLevel    int
+    AllowedOrigins      []string
 }
 
-var upgrader = websocket.Upgrader{
-    ReadBufferSize:  1024,
-    WriteBufferSize: 1024,
-    CheckOrigin: func(r *http.Request) bool {
+func NewHandler(hub *Hub, games usecase.GameService, matchmaking usecase.MatchmakingService, friends usecase.FriendService, events usecase.GameEventService, opts Options) *Handler {
+    h := &Handler{hub: hub, games: games, matchmaking: matchmaking, friends: friends, events: events, opts: opts}
+    h.upgrader = websocket.Upgrader{
+        ReadBufferSize:    opts.ReadBufferSize,
+        WriteBufferSize:   opts.WriteBufferSize,
+        Subprotocols:      []string{SubprotocolJSON, SubprotocolMsgPack},
+        EnableCompression: opts.Compression,
+        CheckOrigin:       h.checkOrigin,
+    }
+    return h
+}
+
+func (h *Handler) checkOrigin(r *http.Request) bool {
+    if len(h.opts.AllowedOrigins) == 0 {
+        return true
+    }
+    origin := r.Header.Get("Origin")
+    if origin == "" {
         return true
-    },
+    }
+    for _, allowed := range h.opts.AllowedOrigins {
+        if allowed == "*" || strings.EqualFold(allowed, origin) {
+            return true
+        }
+    }
+    return false
 }
 
 func (h *Handler) ServeWS(w http.ResponseWriter, r *http.Request) {
-    token := r.URL.Query().Get("token")
-    if token == "" {
-        http.Error(w, "missing token", http.StatusUnauthorized)
+    user, ok := auth.UserFromContext(r.Context())
+    if !ok {
+        http.Error(w, "unauthorized", http.StatusUnauthorized)
         return
     }
 
-    user, err := h.auth.ParseToken(token)
-    if err != nil {
-        http.Error(w, "invalid token", http.StatusUnauthorized)
+    if !offersEncoding(websocket.Subprotocols(r)) {
+        http.Error(w, "offer xo.json or xo.msgpack alongside the token subprotocol", http.StatusBadRequest)
         return
     }
 
-    conn, err := upgrader.Upgrade(w, r, nil)
+    conn, err := h.upgrader.Upgrade(w, r, nil)
     if err != nil {
         return
     }
-
-    client := &Client{
-        hub:      h.hub,
-        conn:     conn,
-        send:     make(chan []byte, 256),
-        userID:   user.ID,
-        username: user.Username,
-        handler:  h,
+    if h.opts.Compression {
+        conn.EnableWriteCompression(true)
+        _ = conn.SetCompressionLevel(h.opts.CompressionLevel)
     }
 
-    h.hub.Register(client)
+    client := newClient(h.hub, conn, user, auth.SessionIDFromContext(r.Context()), h)
+
+    first := h.hub.Register(client)
 
     go client.writePump()
     go client.readPump()
 
-    h.sendSync(client)
+    h.sendSync(client, "")
+    if first {
+        h.broadcastPresence(client.userID, true)
+    }
+}
+
+func offersEncoding(protocols []string) bool {
+    token := false
+    for _, p := range protocols {
+        if _, ok := codecs[p]; ok {
+            return true
+        }
+        if strings.HasPrefix(p, auth.SubprotocolTokenPrefix) {
+            token = true
+        }
+    }
+    return !token
+}
+
+func (h *Handler) handleDisconnect(userID uuid.UUID) {
+    h.broadcastPresence(userID, false)
 }
 
-func (h *Handler) sendSync(c *Client) {
-    games, err := h.games.GetActiveGames(context.Background(), c.userID)
+func (h *Handler) sendSync(c Subscriber, id string) {
+    games, err := h.games.GetActiveGames(context.Background(), c.UserID())
     if err != nil {
+        sendDomainError(c, id, err)
         return
     }
 
-    payload := SyncPayload{Games: make([]*GameResponse, 0, len(games))}
+    payload := SyncPayload{Games: make([]*GameResponse, 0, len(games)), Seqs: make(map[string]int64, len(games))}
     for _, g := range games {
         payload.Games = append(payload.Games, toGameResponse(g))
+        seq, err := h.events.LastSeq(context.Background(), g.ID)
+        if err == nil {
+            payload.Seqs[g.ID.String()] = seq
+        }
     }
 
-    sendJSON(c, "sync", payload)
+    sendReply(c, id, "sync", payload)
 }
 
-func (h *Handler) handleMessage(c *Client, msg Envelope) {
+func (h *Handler) handleResume(c *Client, msg Request) {
+    var req ResumeRequest
+    if err := c.codec.Unmarshal(msg.Payload, &req); err != nil {
+        sendError(c, msg.ID, CodeInvalidPayload, "invalid payload")
+        return
+    }
+
+    resumed := ResumedPayload{Games: make(map[string]int64, len(req.Games))}
+    for rawID, afterSeq := range req.Games {
+        gameID, err := uuid.Parse(rawID)
+        if err != nil {
+            sendError(c, msg.ID, CodeInvalidGameID, "invalid game_id")
+            return
+        }
+
+        events, err := h.events.Replay(context.Background(), c.userID, gameID, afterSeq)
+        if err != nil {
+            sendDomainError(c, msg.ID, err)
+            return
+        }
+
+        last := afterSeq
+        for _, e := range events {
+            c.enqueue(gameEventMessage(e))
+            last = e.Seq
+        }
+        resumed.Games[rawID] = last
+    }
+
+    sendReply(c, msg.ID, "resumed", resumed)
+}
+
+var messagePermissions = map[string]domain.Permission{
+    "join_queue":   domain.PermPlay,
+    "move":         domain.PermPlay,
+    "resign":       domain.PermPlay,
+    "draw_offer":   domain.PermPlay,
+    "draw_accept":  domain.PermPlay,
+    "draw_decline": domain.PermPlay,
+    "chat":         domain.PermChat,
+    "challenge":    domain.PermSocial,
+}
+
+func (h *Handler) handleMessage(c *Client, msg Request) {
+    if perm, ok := messagePermissions[msg.Type]; ok && !c.role.Can(perm) {
+        sendDomainError(c, msg.ID, domain.ErrForbidden)
+        return
+    }
+
     switch msg.Type {
     case "join_queue":
-        h.handleJoinQueue(c)
+        h.handleJoinQueue(c, msg)
     case "move":
-        h.handleMove(c, msg.Payload)
+        h.handleMove(c, msg)
     case "chat":
-        h.handleChat(c, msg.Payload)
+        h.handleChat(c, msg)
     case "resign":
-        h.handleResign(c, msg.Payload)
+        h.handleResign(c, msg)
     case "draw_offer":
-        h.handleDrawOffer(c, msg.Payload)
+        h.handleDrawOffer(c, msg)
     case "draw_accept":
-        h.handleDrawAccept(c, msg.Payload)
+        h.handleDrawAccept(c, msg)
     case "draw_decline":
-        h.handleDrawDecline(c, msg.Payload)
+        h.handleDrawDecline(c, msg)
+    case "challenge":
+        h.handleChallenge(c, msg)
     case "sync":
-        h.sendSync(c)
+        h.sendSync(c, msg.ID)
+    case "resume":
+        h.handleResume(c, msg)
     default:
-        sendError(c, "unknown message type")
+        sendError(c, msg.ID, CodeUnknownType, "unknown message type")
     }
 }
 
-func (h *Handler) handleJoinQueue(c *Client) {
-    matched, game, err := h.matchmaking.JoinQueue(c.userID)
+func (h *Handler) handleJoinQueue(c *Client, msg Request) {
+    var req QueueRequest
+    if len(msg.Payload) > 0 {
+        if err := c.codec.Unmarshal(msg.Payload, &req); err != nil {
+            sendError(c, msg.ID, CodeInvalidPayload, "invalid payload")
+            return
+        }
+    }
+
+    user := &domain.User{ID: c.userID, Username: c.username, Role: c.role, IsGuest: c.guest}
+    matched, game, err := h.matchmaking.JoinQueue(user, domain.QueuePool(req.Pool))
     if err != nil {
-        sendError(c, err.Error())
+        sendDomainError(c, msg.ID, err)
         return
     }
     if !matched {
-        sendJSON(c, "queue_joined", map[string]string{"status": "waiting"})
+        sendReply(c, msg.ID, "queue_joined", map[string]string{"status": "waiting"})
         return
     }
 
-    resp := toGameResponse(game)
-    payload := GamePayload{Game: resp}
-    msg := mustJSON(Envelope{Type: "game_found", Payload: mustRaw(payload)})
-    h.hub.BroadcastToUsers([]uuid.UUID{game.PlayerX, game.PlayerO}, msg)
+    sendAck(c, msg)
+    h.announce(game)
 }
 
-func (h *Handler) handleMove(c *Client, raw json.RawMessage) {
-    var req MoveRequest
-    if err := json.Unmarshal(raw, &req); err != nil {
-        sendError(c, "invalid payload")
+func (h *Handler) handleChallenge(c *Client, msg Request) {
+    var req ChallengeRequest
+    if err := c.codec.Unmarshal(msg.Payload, &req); err != nil {
+        sendError(c, msg.ID, CodeInvalidPayload, "invalid payload")
         return
     }
 
-    gameID, err := uuid.Parse(req.GameID)
+    friendID, err := uuid.Parse(req.UserID)
     if err != nil {
-        sendError(c, "invalid game_id")
+        sendError(c, msg.ID, CodeInvalidUserID, "invalid user_id")
         return
     }
 
-    game, err := h.games.MakeMove(context.Background(), c.userID, gameID, req.Position)
+    game, err := h.friends.Challenge(context.Background(), c.userID, friendID)
     if err != nil {
-        sendError(c, err.Error())
+        sendDomainError(c, msg.ID, err)
         return
     }
 
-    h.broadcastGameUpdate(game)
+    sendAck(c, msg)
+    h.announce(game)
 }
 
-func (h *Handler) handleChat(c *Client, raw json.RawMessage) {
-    var req ChatRequest
-    if err := json.Unmarshal(raw, &req); err != nil {
-        sendError(c, "invalid payload")
+func (h *Handler) handleMove(c *Client, msg Request) {
+    var req MoveRequest
+    if err := c.codec.Unmarshal(msg.Payload, &req); err != nil {
+        sendError(c, msg.ID, CodeInvalidPayload, "invalid payload")
         return
     }
 
     gameID, err := uuid.Parse(req.GameID)
     if err != nil {
-        sendError(c, "invalid game_id")
+        sendError(c, msg.ID, CodeInvalidGameID, "invalid game_id")
         return
     }
 
-    if err := h.games.AddChat(context.Background(), c.userID, gameID, req.Message); err != nil {
-        sendError(c, err.Error())
+    if _, err := h.games.MakeMove(context.Background(), c.userID, gameID, req.Position); err != nil {
+        sendDomainError(c, msg.ID, err)
         return
     }
 
-    payload := ChatPayload{
-        GameID:  gameID.String(),
-        UserID:  c.userID.String(),
-        Message: req.Message,
-        At:      time.Now().UTC().Format(time.RFC3339),
+    sendAck(c, msg)
+}
+
+func (h *Handler) handleChat(c *Client, msg Request) {
+    var req ChatRequest
+    if err := c.codec.Unmarshal(msg.Payload, &req); err != nil {
+        sendError(c, msg.ID, CodeInvalidPayload, "invalid payload")
+        return
     }
-    msg := mustJSON(Envelope{Type: "chat", Payload: mustRaw(payload)})
-    game, err := h.games.GetGame(context.Background(), gameID)
+
+    gameID, err := uuid.Parse(req.GameID)
     if err != nil {
+        sendError(c, msg.ID, CodeInvalidGameID, "invalid game_id")
         return
     }
-    h.hub.BroadcastToUsers([]uuid.UUID{game.PlayerX, game.PlayerO}, msg)
+
+    if _, err := h.games.AddChat(context.Background(), c.userID, gameID, req.Message); err != nil {
+        sendDomainError(c, msg.ID, err)
+        return
+    }
+
+    sendAck(c, msg)
 }
 
-func (h *Handler) handleResign(c *Client, raw json.RawMessage) {
-    gameID, ok := parseGameID(c, raw)
+func (h *Handler) handleResign(c *Client, msg Request) {
+    gameID, ok := parseGameID(c, msg)
     if !ok {
         return
     }
-    game, err := h.games.Resign(context.Background(), c.userID, gameID)
-    if err != nil {
-        sendError(c, err.Error())
+    if _, err := h.games.Resign(context.Background(), c.userID, gameID); err != nil {
+        sendDomainError(c, msg.ID, err)
         return
     }
-    h.broadcastGameUpdate(game)
+    sendAck(c, msg)
 }
 
-func (h *Handler) handleDrawOffer(c *Client, raw json.RawMessage) {
-    gameID, ok := parseGameID(c, raw)
+func (h *Handler) handleDrawOffer(c *Client, msg Request) {
+    gameID, ok := parseGameID(c, msg)
     if !ok {
         return
     }
-    game, err := h.games.OfferDraw(context.Background(), c.userID, gameID)
-    if err != nil {
-        sendError(c, err.Error())
+    if _, err := h.games.OfferDraw(context.Background(), c.userID, gameID); err != nil {
+        sendDomainError(c, msg.ID, err)
         return
     }
-    h.broadcastGameUpdate(game)
+    sendAck(c, msg)
 }
 
-func (h *Handler) handleDrawAccept(c *Client, raw json.RawMessage) {
-    gameID, ok := parseGameID(c, raw)
+func (h *Handler) handleDrawAccept(c *Client, msg Request) {
+    gameID, ok := parseGameID(c, msg)
     if !ok {
         return
     }
-    game, err := h.games.AcceptDraw(context.Background(), c.userID, gameID)
-    if err != nil {
-        sendError(c, err.Error())
+    if _, err := h.games.AcceptDraw(context.Background(), c.userID, gameID); err != nil {
+        sendDomainError(c, msg.ID, err)
         return
     }
-    h.broadcastGameUpdate(game)
+    sendAck(c, msg)
 }
 
-func (h *Handler) handleDrawDecline(c *Client, raw json.RawMessage) {
-    gameID, ok := parseGameID(c, raw)
+func (h *Handler) handleDrawDecline(c *Client, msg Request) {
+    gameID, ok := parseGameID(c, msg)
     if !ok {
         return
     }
-    game, err := h.games.DeclineDraw(context.Background(), c.userID, gameID)
-    if err != nil {
-        sendError(c, err.Error())
+    if _, err := h.games.DeclineDraw(context.Background(), c.userID, gameID); err != nil {
+        sendDomainError(c, msg.ID, err)
         return
     }
-    h.broadcastGameUpdate(game)
+    sendAck(c, msg)
 }
 
-func (h *Handler) broadcastGameUpdate(game *domain.Game) {
-    payload := GamePayload{Game: toGameResponse(game)}
-    msg := mustJSON(Envelope{Type: "game_update", Payload: mustRaw(payload)})
-    h.hub.BroadcastToUsers([]uuid.UUID{game.PlayerX, game.PlayerO}, msg)
+func (h *Handler) NotifyFriendRequest(to uuid.UUID, from *domain.User) {
+    payload := FriendPayload{UserID: from.ID.String(), Username: from.Username}
+    h.hub.SendToUser(to, newMessage(Envelope{Type: "friend_request", Payload: payload}))
+}
+
+func (h *Handler) NotifyFriendAccepted(to uuid.UUID, by *domain.User) {
+    payload := FriendPayload{UserID: by.ID.String(), Username: by.Username}
+    h.hub.SendToUser(to, newMessage(Envelope{Type: "friend_accepted", Payload: payload}))
+}
+
+func (h *Handler) NotifyGameFound(game *domain.Game) {
+    h.announce(game)
+}
+
+func (h *Handler) announce(game *domain.Game) {
+    if _, err := h.games.AnnounceGame(context.Background(), game.ID); err != nil {
+        log.Printf("announce game %s error: %v", game.ID, err)
+    }
+}
+
+func (h *Handler) broadcastPresence(userID uuid.UUID, online bool) {
+    ids, err := h.friends.FriendIDs(context.Background(), userID)
+    if err != nil || len(ids) == 0 {
+        return
+    }
+    payload := PresencePayload{UserID: userID.String(), Online: online}
+    msg := newMessage(Envelope{Type: "presence", Payload: payload})
+    h.hub.BroadcastToUsers(ids, msg)
 }
 
 func toGameResponse(game *domain.Game) *GameResponse {
@@ -252,18 +398,20 @@ func toGameResponse(game *domain.Game) *GameResponse {
         Status:        string(game.Status),
         WinnerUserID:  winner,
         DrawOfferedBy: drawBy,
+        Rated:         game.Rated,
+        EndReason:     game.EndReason,
     }
 }
 
-func parseGameID(c *Client, raw json.RawMessage) (uuid.UUID, bool) {
+func parseGameID(c *Client, msg Request) (uuid.UUID, bool) {
     var req GameIDRequest
-    if err := json.Unmarshal(raw, &req); err != nil {
-        sendError(c, "invalid payload")
+    if err := c.codec.Unmarshal(msg.Payload, &req); err != nil {
+        sendError(c, msg.ID, CodeInvalidPayload, "invalid payload")
         return uuid.UUID{}, false
     }
     id, err := uuid.Parse(req.GameID)
     if err != nil {
-        sendError(c, "invalid game_id")
+        sendError(c, msg.ID, CodeInvalidGameID, "invalid game_id")
         return uuid.UUID{}, false
     }
     return id, true
@@ -274,17 +422,26 @@ func mustJSON(v interface{}) []byte {
     return b
 }
 
-func mustRaw(v interface{}) json.RawMessage {
-    b, _ := json.Marshal(v)
-    return json.RawMessage(b)
+func sendReply(c Subscriber, id, msgType string, payload interface{}) {
+    c.enqueue(newMessage(Envelope{Type: msgType, ID: id, Payload: payload}))
+}
+
+func sendAck(c Subscriber, req Request) {
+    if req.ID == "" {
+        return
+    }
+    sendReply(c, req.ID, "ack", AckPayload{Type: req.Type})
 }
 
-func sendJSON(c *Client, msgType string, payload interface{}) {
-    env := Envelope{Type: msgType, Payload: mustRaw(payload)}
-    c.send <- mustJSON(env)
+func sendError(c Subscriber, id, code, message string) {
+    sendReply(c, id, "error", ErrorPayload{Code: code, Message: message})
 }
 
-func sendError(c *Client, message string) {
-    env := Envelope{Type: "error", Payload: mustRaw(ErrorPayload{Message: message})}
-    c.send <- mustJSON(env)
+func sendDomainError(c Subscriber, id string, err error) {
+    code := errorCode(err)
+    message := err.Error()
+    if code == CodeInternal {
+        message = "internal error"
+    }
+    sendError(c, id, code, message)
 }
@@ -0,0 +1,115 @@
+﻿package ws
+
+import (
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/gorilla/websocket"
+    "xo-server/internal/adapter/auth"
+    "xo-server/internal/adapter/repo/memory"
+    "xo-server/internal/usecase"
+)
+
+func TestTokenSubprotocolHandshake(t *testing.T) {
+    ctx := context.Background()
+    userRepo := memory.NewUserRepo()
+    gameRepo := memory.NewGameRepo()
+    eventRepo := memory.NewGameEventRepo()
+    refreshRepo := memory.NewRefreshTokenRepo()
+    tokens := auth.NewJWTProvider("secret", time.Minute, refreshRepo)
+    authSvc := usecase.NewAuthService(userRepo, tokens, refreshRepo, memory.NewPasswordResetRepo(), memory.NewTwoFactorRepo(), nil, nil, nil, usecase.AuthOptions{})
+    _, _ = authSvc.Register(ctx, "alice", "password")
+    login, err := authSvc.Login(ctx, "alice", "password")
+    if err != nil {
+        t.Fatalf("login error: %v", err)
+    }
+
+    hub := NewHub(SessionMulti)
+    go hub.Run()
+    games := usecase.NewGameService(gameRepo, eventRepo, hub, nil)
+    handler := NewHandler(hub, games, usecase.NewMatchmakingService(gameRepo, usecase.MatchmakingOptions{}), usecase.NewFriendService(memory.NewFriendRepo(), userRepo, gameRepo, hub), usecase.NewGameEventService(eventRepo, gameRepo), Options{
+        SendBufferSize:      16,
+        MaxMessageSize:      4096,
+        WriteWait:           time.Second,
+        PongWait:            time.Minute,
+        SlowConsumerTimeout: time.Second,
+    })
+    server := httptest.NewServer(auth.NewMiddleware(tokens, "", false).Require(handler.ServeWS))
+    defer server.Close()
+    url := "ws" + strings.TrimPrefix(server.URL, "http")
+    token := auth.SubprotocolTokenPrefix + login.Tokens.AccessToken
+
+    dialer := websocket.Dialer{Subprotocols: []string{token}}
+    if _, resp, err := dialer.Dial(url, nil); err == nil || resp == nil || resp.StatusCode != http.StatusBadRequest {
+        t.Fatalf("expected token-only offer to be refused, got %v", err)
+    }
+
+    for _, encoding := range []string{SubprotocolJSON, SubprotocolMsgPack} {
+        dialer := websocket.Dialer{Subprotocols: []string{token, encoding}}
+        conn, _, err := dialer.Dial(url, nil)
+        if err != nil {
+            t.Fatalf("dial error: %v", err)
+        }
+        if conn.Subprotocol() != encoding {
+            t.Fatalf("expected %s to be selected, got %q", encoding, conn.Subprotocol())
+        }
+        _ = conn.SetReadDeadline(time.Now().Add(time.Second))
+        if _, _, err := conn.ReadMessage(); err != nil {
+            t.Fatalf("expected sync message: %v", err)
+        }
+
+        codec := codecFor(encoding)
+        data, _ := codec.Marshal(Envelope{Type: "sync", ID: "1"})
+        if err := conn.WriteMessage(codec.FrameType(), data); err != nil {
+            t.Fatalf("write error: %v", err)
+        }
+        frame, data, err := conn.ReadMessage()
+        if err != nil || frame != codec.FrameType() {
+            t.Fatalf("expected a %s reply, got frame %d (%v)", encoding, frame, err)
+        }
+        var reply Request
+        var sync SyncPayload
+        if err := codec.Unmarshal(data, &reply); err != nil || reply.Type != "sync" || reply.ID != "1" {
+            t.Fatalf("expected a sync reply over %s, got %+v (%v)", encoding, reply, err)
+        }
+        if err := codec.Unmarshal(reply.Payload, &sync); err != nil || sync.Games == nil {
+            t.Fatalf("expected a sync payload over %s, got %+v (%v)", encoding, sync, err)
+        }
+        _ = conn.Close()
+    }
+}
+
+func TestRepliesWaitForRoomInsteadOfDropping(t *testing.T) {
+    sub := &sseSubscriber{outbox: newOutbox(1, time.Second)}
+    sub.Deliver(newMessage(Envelope{Type: "chat", Payload: struct{}{}}), uuid.Nil)
+
+    sent := make(chan struct{})
+    go func() {
+        sendAck(sub, Request{Type: "chat", ID: "1"})
+        close(sent)
+    }()
+    if first := <-sub.send; first.Type() != "chat" {
+        t.Fatalf("expected the queued broadcast first, got %s", first.Type())
+    }
+    <-sent
+    if reply := <-sub.send; reply.Type() != "ack" {
+        t.Fatalf("expected the ack to be delivered once the buffer drained, got %s", reply.Type())
+    }
+
+    stalled := &sseSubscriber{outbox: newOutbox(1, 20*time.Millisecond)}
+    stalled.Deliver(newMessage(Envelope{Type: "chat", Payload: struct{}{}}), uuid.Nil)
+    sendError(stalled, "2", CodeInvalidPayload, "invalid payload")
+    select {
+    case <-stalled.done:
+    default:
+        t.Fatal("expected a reply that cannot be queued to close the connection")
+    }
+    if stalled.closeReason != "slow consumer" {
+        t.Fatalf("expected a slow consumer close, got %q", stalled.closeReason)
+    }
+}
@@ -1,68 +1,207 @@
 ﻿package ws
 
 import (
+    "encoding/json"
     "sync"
+    "time"
 
+    "github.com/gorilla/websocket"
     "github.com/google/uuid"
+    "xo-server/internal/domain"
 )
 
+type SessionPolicy string
+
+const (
+    SessionMulti  SessionPolicy = "multi"
+    SessionSingle SessionPolicy = "single"
+)
+
+type hubRequest struct {
+    sub    Subscriber
+    result chan bool
+}
+
 type Hub struct {
-    register   chan *Client
-    unregister chan *Client
+    register   chan hubRequest
+    unregister chan hubRequest
+    policy     SessionPolicy
 
     mu      sync.RWMutex
-    clients map[uuid.UUID]*Client
+    clients map[uuid.UUID]map[Subscriber]struct{}
 }
 
-func NewHub() *Hub {
+func NewHub(policy SessionPolicy) *Hub {
+    if policy == "" {
+        policy = SessionMulti
+    }
     return &Hub{
-        register:   make(chan *Client),
-        unregister: make(chan *Client),
-        clients:    make(map[uuid.UUID]*Client),
+        register:   make(chan hubRequest),
+        unregister: make(chan hubRequest),
+        policy:     policy,
+        clients:    make(map[uuid.UUID]map[Subscriber]struct{}),
     }
 }
 
 func (h *Hub) Run() {
     for {
         select {
-        case c := <-h.register:
-            h.mu.Lock()
-            h.clients[c.userID] = c
-            h.mu.Unlock()
-        case c := <-h.unregister:
-            h.mu.Lock()
-            if existing, ok := h.clients[c.userID]; ok && existing == c {
-                delete(h.clients, c.userID)
-                close(c.send)
-            }
-            h.mu.Unlock()
+        case req := <-h.register:
+            req.result <- h.add(req.sub)
+        case req := <-h.unregister:
+            req.result <- h.remove(req.sub)
+        }
+    }
+}
+
+func (h *Hub) add(s Subscriber) bool {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    conns, ok := h.clients[s.UserID()]
+    if !ok {
+        conns = make(map[Subscriber]struct{})
+        h.clients[s.UserID()] = conns
+    }
+    first := len(conns) == 0
+    if h.policy == SessionSingle {
+        replaced := newMessage(Envelope{Type: "session_replaced", Payload: struct{}{}})
+        for old := range conns {
+            delete(conns, old)
+            old.Deliver(replaced, uuid.Nil)
+            old.Close(websocket.ClosePolicyViolation, "session_replaced")
         }
     }
+    conns[s] = struct{}{}
+    return first
 }
 
-func (h *Hub) Register(c *Client) {
-    h.register <- c
+func (h *Hub) remove(s Subscriber) bool {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    conns := h.clients[s.UserID()]
+    if _, ok := conns[s]; !ok {
+        return false
+    }
+    delete(conns, s)
+    s.Close(websocket.CloseNormalClosure, "")
+    if len(conns) > 0 {
+        return false
+    }
+    delete(h.clients, s.UserID())
+    return true
+}
+
+func (h *Hub) Register(s Subscriber) bool {
+    req := hubRequest{sub: s, result: make(chan bool, 1)}
+    h.register <- req
+    return <-req.result
+}
+
+func (h *Hub) Unregister(s Subscriber) bool {
+    req := hubRequest{sub: s, result: make(chan bool, 1)}
+    h.unregister <- req
+    return <-req.result
 }
 
-func (h *Hub) Unregister(c *Client) {
-    h.unregister <- c
+func (h *Hub) IsOnline(userID uuid.UUID) bool {
+    h.mu.RLock()
+    defer h.mu.RUnlock()
+    return len(h.clients[userID]) > 0
 }
 
-func (h *Hub) SendToUser(userID uuid.UUID, msg []byte) {
+func (h *Hub) CloseSession(sessionID uuid.UUID) {
+    revoked := newMessage(Envelope{Type: "session_revoked", Payload: struct{}{}})
     h.mu.RLock()
-    c := h.clients[userID]
-    h.mu.RUnlock()
-    if c == nil {
+    defer h.mu.RUnlock()
+    for _, conns := range h.clients {
+        for s := range conns {
+            if s.SessionID() != sessionID {
+                continue
+            }
+            s.Deliver(revoked, uuid.Nil)
+            s.Close(websocket.ClosePolicyViolation, "session_revoked")
+        }
+    }
+}
+
+func (h *Hub) CloseUser(userID uuid.UUID, reason string) {
+    suspended := newMessage(Envelope{Type: "account_suspended", Payload: struct {
+        Reason string `json:"reason" msgpack:"reason"`
+    }{Reason: reason}})
+    h.mu.RLock()
+    defer h.mu.RUnlock()
+    for s := range h.clients[userID] {
+        s.Deliver(suspended, uuid.Nil)
+        s.Close(websocket.ClosePolicyViolation, "account_suspended")
+    }
+}
+
+func (h *Hub) NotifyAchievement(userID uuid.UUID, achievement *domain.UserAchievement) {
+    info, _ := domain.AchievementByCode(achievement.Code)
+    payload := AchievementPayload{
+        Code:        string(achievement.Code),
+        Name:        info.Name,
+        Description: info.Description,
+        UnlockedAt:  achievement.UnlockedAt.Format(time.RFC3339),
+    }
+    if achievement.GameID != nil {
+        gameID := achievement.GameID.String()
+        payload.GameID = &gameID
+    }
+    h.SendToUser(userID, newMessage(Envelope{Type: "achievement_unlocked", Payload: payload}))
+}
+
+func (h *Hub) EncodeGameEvent(eventType string, game *domain.Game, msg *domain.GameMessage) []byte {
+    if eventType == domain.GameEventChat && msg != nil {
+        return mustJSON(ChatPayload{
+            GameID:  msg.GameID.String(),
+            UserID:  msg.UserID.String(),
+            Message: msg.Message,
+            At:      msg.CreatedAt.Format(time.RFC3339),
+        })
+    }
+    return mustJSON(GamePayload{Game: toGameResponse(game)})
+}
+
+func (h *Hub) PublishGameEvent(game *domain.Game, event *domain.GameEvent) {
+    msg := gameEventMessage(event)
+    players := []uuid.UUID{game.PlayerX, game.PlayerO}
+    if event.Type == domain.GameEventChat {
+        h.BroadcastToUsers(players, msg)
         return
     }
-    select {
-    case c.send <- msg:
-    default:
+    h.BroadcastGameState(players, game.ID, msg)
+}
+
+func gameEventMessage(event *domain.GameEvent) *Message {
+    var payload interface{} = &GamePayload{}
+    if event.Type == domain.GameEventChat {
+        payload = &ChatPayload{}
     }
+    _ = json.Unmarshal(event.Payload, payload)
+    return newMessage(Envelope{Type: event.Type, GameID: event.GameID.String(), Seq: event.Seq, Payload: payload})
+}
+
+func (h *Hub) SendToUser(userID uuid.UUID, msg *Message) {
+    h.deliver(userID, msg, uuid.Nil)
 }
 
-func (h *Hub) BroadcastToUsers(users []uuid.UUID, msg []byte) {
+func (h *Hub) BroadcastToUsers(users []uuid.UUID, msg *Message) {
     for _, id := range users {
-        h.SendToUser(id, msg)
+        h.deliver(id, msg, uuid.Nil)
+    }
+}
+
+func (h *Hub) BroadcastGameState(users []uuid.UUID, gameID uuid.UUID, msg *Message) {
+    for _, id := range users {
+        h.deliver(id, msg, gameID)
+    }
+}
+
+func (h *Hub) deliver(userID uuid.UUID, msg *Message, key uuid.UUID) {
+    h.mu.RLock()
+    defer h.mu.RUnlock()
+    for s := range h.clients[userID] {
+        s.Deliver(msg, key)
     }
 }
@@ -0,0 +1,9 @@
+﻿package ws
+
+import "expvar"
+
+var (
+    metricDropped         = expvar.NewInt("ws_messages_dropped")
+    metricCoalesced       = expvar.NewInt("ws_messages_coalesced")
+    metricSlowDisconnects = expvar.NewInt("ws_slow_consumer_disconnects")
+)
@@ -0,0 +1,141 @@
+﻿package ws
+
+import (
+    "sync"
+    "time"
+
+    "github.com/gorilla/websocket"
+    "github.com/google/uuid"
+)
+
+type Subscriber interface {
+    UserID() uuid.UUID
+    SessionID() uuid.UUID
+    Deliver(msg *Message, key uuid.UUID)
+    Close(code int, reason string)
+    enqueue(msg *Message)
+}
+
+type outbox struct {
+    send        chan *Message
+    wake        chan struct{}
+    done        chan struct{}
+    slowTimeout time.Duration
+
+    closeOnce   sync.Once
+    closeCode   int
+    closeReason string
+
+    pendingMu    sync.Mutex
+    pending      map[uuid.UUID]*Message
+    blockedSince time.Time
+    slowTimer    *time.Timer
+}
+
+func newOutbox(size int, slowTimeout time.Duration) *outbox {
+    return &outbox{
+        send:        make(chan *Message, size),
+        wake:        make(chan struct{}, 1),
+        done:        make(chan struct{}),
+        slowTimeout: slowTimeout,
+        pending:     make(map[uuid.UUID]*Message),
+    }
+}
+
+func (o *outbox) enqueue(msg *Message) {
+    timer := time.NewTimer(o.slowTimeout)
+    defer timer.Stop()
+
+    select {
+    case o.send <- msg:
+    case <-o.done:
+    case <-timer.C:
+        o.closeSlow()
+    }
+}
+
+func (o *outbox) Deliver(msg *Message, key uuid.UUID) {
+    o.pendingMu.Lock()
+    defer o.pendingMu.Unlock()
+
+    if key != uuid.Nil {
+        if _, ok := o.pending[key]; ok {
+            o.pending[key] = msg
+            metricCoalesced.Add(1)
+            return
+        }
+    }
+
+    select {
+    case o.send <- msg:
+        o.unblock()
+        return
+    default:
+    }
+
+    if key != uuid.Nil {
+        o.pending[key] = msg
+        select {
+        case o.wake <- struct{}{}:
+        default:
+        }
+    } else {
+        metricDropped.Add(1)
+    }
+
+    if o.blockedSince.IsZero() {
+        o.blockedSince = time.Now()
+        o.slowTimer = time.AfterFunc(o.slowTimeout, o.checkSlow)
+    }
+}
+
+func (o *outbox) checkSlow() {
+    o.pendingMu.Lock()
+    defer o.pendingMu.Unlock()
+    if !o.blockedSince.IsZero() && time.Since(o.blockedSince) >= o.slowTimeout {
+        o.closeSlow()
+    }
+}
+
+func (o *outbox) unblock() {
+    o.blockedSince = time.Time{}
+    if o.slowTimer != nil {
+        o.slowTimer.Stop()
+        o.slowTimer = nil
+    }
+}
+
+func (o *outbox) takePending() []*Message {
+    o.pendingMu.Lock()
+    defer o.pendingMu.Unlock()
+    if len(o.send) < cap(o.send) {
+        o.unblock()
+    }
+    if len(o.pending) == 0 || len(o.send) > 0 {
+        return nil
+    }
+    out := make([]*Message, 0, len(o.pending))
+    for key, msg := range o.pending {
+        out = append(out, msg)
+        delete(o.pending, key)
+    }
+    return out
+}
+
+func (o *outbox) closeSlow() {
+    select {
+    case <-o.done:
+        return
+    default:
+    }
+    metricSlowDisconnects.Add(1)
+    o.Close(websocket.CloseTryAgainLater, "slow consumer")
+}
+
+func (o *outbox) Close(code int, reason string) {
+    o.closeOnce.Do(func() {
+        o.closeCode = code
+        o.closeReason = reason
+        close(o.done)
+    })
+}
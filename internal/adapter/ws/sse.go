@@ -0,0 +1,113 @@
+﻿package ws
+
+import (
+    "fmt"
+    "net/http"
+    "time"
+
+    "github.com/google/uuid"
+    "xo-server/internal/adapter/auth"
+)
+
+type sseSubscriber struct {
+    *outbox
+    userID    uuid.UUID
+    sessionID uuid.UUID
+}
+
+func (s *sseSubscriber) UserID() uuid.UUID {
+    return s.userID
+}
+
+func (s *sseSubscriber) SessionID() uuid.UUID {
+    return s.sessionID
+}
+
+func (h *Handler) ServeSSE(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        w.WriteHeader(http.StatusMethodNotAllowed)
+        return
+    }
+
+    user, ok := auth.UserFromContext(r.Context())
+    if !ok {
+        http.Error(w, "unauthorized", http.StatusUnauthorized)
+        return
+    }
+
+    rc := http.NewResponseController(w)
+    w.Header().Set("Content-Type", "text/event-stream")
+    w.Header().Set("Cache-Control", "no-cache")
+    w.Header().Set("Connection", "keep-alive")
+    w.Header().Set("X-Accel-Buffering", "no")
+    w.WriteHeader(http.StatusOK)
+    if err := rc.Flush(); err != nil {
+        return
+    }
+
+    sub := &sseSubscriber{
+        outbox:    newOutbox(h.opts.SendBufferSize, h.opts.SlowConsumerTimeout),
+        userID:    user.ID,
+        sessionID: auth.SessionIDFromContext(r.Context()),
+    }
+
+    first := h.hub.Register(sub)
+    defer func() {
+        if h.hub.Unregister(sub) {
+            h.handleDisconnect(sub.userID)
+        }
+    }()
+
+    h.sendSync(sub, "")
+    if first {
+        h.broadcastPresence(sub.userID, true)
+    }
+
+    keepAlive := time.NewTicker(h.opts.PongWait * 9 / 10)
+    defer keepAlive.Stop()
+
+    write := func(message *Message) bool {
+        data, err := message.Encode(jsonCodec{})
+        if err != nil {
+            return false
+        }
+        _ = rc.SetWriteDeadline(time.Now().Add(h.opts.WriteWait))
+        if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", message.Type(), data); err != nil {
+            return false
+        }
+        return rc.Flush() == nil
+    }
+
+    for {
+        select {
+        case <-r.Context().Done():
+            return
+        case message := <-sub.send:
+            if !write(message) {
+                return
+            }
+            for _, pending := range sub.takePending() {
+                if !write(pending) {
+                    return
+                }
+            }
+        case <-sub.wake:
+            for _, pending := range sub.takePending() {
+                if !write(pending) {
+                    return
+                }
+            }
+        case <-sub.done:
+            write(newMessage(Envelope{Type: "close", Payload: map[string]string{"reason": sub.closeReason}}))
+            return
+        case <-keepAlive.C:
+            _ = rc.SetWriteDeadline(time.Now().Add(h.opts.WriteWait))
+            if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+                return
+            }
+            if rc.Flush() != nil {
+                return
+            }
+        }
+    }
+}
@@ -1,54 +1,121 @@
 ﻿package ws
 
 import (
-    "encoding/json"
+    "github.com/vmihailenco/msgpack/v5"
 )
 
 type Envelope struct {
-    Type    string          `json:"type"`
-    Payload json.RawMessage `json:"payload"`
+    Type    string      `json:"type" msgpack:"type"`
+    ID      string      `json:"id,omitempty" msgpack:"id,omitempty"`
+    GameID  string      `json:"game_id,omitempty" msgpack:"game_id,omitempty"`
+    Seq     int64       `json:"seq,omitempty" msgpack:"seq,omitempty"`
+    Payload interface{} `json:"payload" msgpack:"payload"`
+}
+
+type Request struct {
+    Type    string     `json:"type" msgpack:"type"`
+    ID      string     `json:"id,omitempty" msgpack:"id,omitempty"`
+    Payload RawPayload `json:"payload" msgpack:"payload"`
+}
+
+type RawPayload []byte
+
+func (p *RawPayload) UnmarshalJSON(data []byte) error {
+    *p = append((*p)[:0], data...)
+    return nil
+}
+
+func (p *RawPayload) DecodeMsgpack(dec *msgpack.Decoder) error {
+    raw, err := dec.DecodeRaw()
+    if err != nil {
+        return err
+    }
+    *p = RawPayload(raw)
+    return nil
 }
 
 type ErrorPayload struct {
-    Message string `json:"message"`
+    Code    string `json:"code" msgpack:"code"`
+    Message string `json:"message" msgpack:"message"`
+}
+
+type AckPayload struct {
+    Type string `json:"type" msgpack:"type"`
 }
 
 type GamePayload struct {
-    Game *GameResponse `json:"game"`
+    Game *GameResponse `json:"game" msgpack:"game"`
 }
 
 type GameResponse struct {
-    ID            string  `json:"id"`
-    PlayerX       string  `json:"player_x"`
-    PlayerO       string  `json:"player_o"`
-    Board         string  `json:"board"`
-    NextTurn      string  `json:"next_turn"`
-    Status        string  `json:"status"`
-    WinnerUserID  *string `json:"winner_user_id"`
-    DrawOfferedBy *string `json:"draw_offered_by"`
+    ID            string  `json:"id" msgpack:"id"`
+    PlayerX       string  `json:"player_x" msgpack:"player_x"`
+    PlayerO       string  `json:"player_o" msgpack:"player_o"`
+    Board         string  `json:"board" msgpack:"board"`
+    NextTurn      string  `json:"next_turn" msgpack:"next_turn"`
+    Status        string  `json:"status" msgpack:"status"`
+    WinnerUserID  *string `json:"winner_user_id" msgpack:"winner_user_id"`
+    DrawOfferedBy *string `json:"draw_offered_by" msgpack:"draw_offered_by"`
+    Rated         bool    `json:"rated" msgpack:"rated"`
+    EndReason     string  `json:"end_reason,omitempty" msgpack:"end_reason,omitempty"`
 }
 
 type SyncPayload struct {
-    Games []*GameResponse `json:"games"`
+    Games []*GameResponse  `json:"games" msgpack:"games"`
+    Seqs  map[string]int64 `json:"seqs" msgpack:"seqs"`
+}
+
+type ResumeRequest struct {
+    Games map[string]int64 `json:"games" msgpack:"games"`
+}
+
+type ResumedPayload struct {
+    Games map[string]int64 `json:"games" msgpack:"games"`
 }
 
 type ChatPayload struct {
-    GameID  string `json:"game_id"`
-    UserID  string `json:"user_id"`
-    Message string `json:"message"`
-    At      string `json:"at"`
+    GameID  string `json:"game_id" msgpack:"game_id"`
+    UserID  string `json:"user_id" msgpack:"user_id"`
+    Message string `json:"message" msgpack:"message"`
+    At      string `json:"at" msgpack:"at"`
 }
 
 type MoveRequest struct {
-    GameID   string `json:"game_id"`
-    Position int    `json:"position"`
+    GameID   string `json:"game_id" msgpack:"game_id"`
+    Position int    `json:"position" msgpack:"position"`
 }
 
 type GameIDRequest struct {
-    GameID string `json:"game_id"`
+    GameID string `json:"game_id" msgpack:"game_id"`
+}
+
+type QueueRequest struct {
+    Pool string `json:"pool" msgpack:"pool"`
 }
 
 type ChatRequest struct {
-    GameID  string `json:"game_id"`
-    Message string `json:"message"`
+    GameID  string `json:"game_id" msgpack:"game_id"`
+    Message string `json:"message" msgpack:"message"`
+}
+
+type ChallengeRequest struct {
+    UserID string `json:"user_id" msgpack:"user_id"`
+}
+
+type PresencePayload struct {
+    UserID string `json:"user_id" msgpack:"user_id"`
+    Online bool   `json:"online" msgpack:"online"`
+}
+
+type FriendPayload struct {
+    UserID   string `json:"user_id" msgpack:"user_id"`
+    Username string `json:"username" msgpack:"username"`
+}
+
+type AchievementPayload struct {
+    Code        string  `json:"code" msgpack:"code"`
+    Name        string  `json:"name" msgpack:"name"`
+    Description string  `json:"description" msgpack:"description"`
+    GameID      *string `json:"game_id,omitempty" msgpack:"game_id,omitempty"`
+    UnlockedAt  string  `json:"unlocked_at" msgpack:"unlocked_at"`
 }
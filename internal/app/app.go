@@ -2,20 +2,26 @@
 
 import (
     "context"
+    "expvar"
     "net/http"
     "time"
 
     "xo-server/internal/adapter/auth"
     httpadapter "xo-server/internal/adapter/http"
+    "xo-server/internal/adapter/notify"
+    "xo-server/internal/adapter/oidc"
     "xo-server/internal/adapter/repo/postgres"
     "xo-server/internal/adapter/ws"
     "xo-server/internal/config"
+    "xo-server/internal/domain"
     "xo-server/internal/usecase"
 )
 
 type App struct {
-    Server *http.Server
-    Hub    *ws.Hub
+    Server  *http.Server
+    Hub     *ws.Hub
+    Keys    *auth.KeySet
+    Seasons usecase.SeasonService
 }
 
 func Build(ctx context.Context, cfg *config.Config) (*App, error) {
@@ -26,27 +32,122 @@ func Build(ctx context.Context, cfg *config.Config) (*App, error) {
 
     userRepo := postgres.NewUserRepo(db)
     gameRepo := postgres.NewGameRepo(db)
+    friendRepo := postgres.NewFriendRepo(db)
+    eventRepo := postgres.NewGameEventRepo(db)
+    refreshRepo := postgres.NewRefreshTokenRepo(db)
+    identityRepo := postgres.NewIdentityRepo(db)
+    resetRepo := postgres.NewPasswordResetRepo(db)
+    twoFactorRepo := postgres.NewTwoFactorRepo(db)
+    reportRepo := postgres.NewReportRepo(db)
+    auditRepo := postgres.NewAuditRepo(db)
+    statsRepo := postgres.NewStatsRepo(db)
+    achievementRepo := postgres.NewAchievementRepo(db)
+    seasonRepo := postgres.NewSeasonRepo(db)
+    accountRepo := postgres.NewAccountRepo(db)
 
-    tokenProvider := auth.NewJWTProvider(cfg.JWT.Secret, cfg.JWT.ParsedTTL)
-    authSvc := usecase.NewAuthService(userRepo, tokenProvider)
-    gameSvc := usecase.NewGameService(gameRepo)
-    matchmaking := usecase.NewMatchmakingService(gameRepo)
+    hub := ws.NewHub(ws.SessionPolicy(cfg.WS.SessionPolicy))
+    keys := auth.NewHMACKeySet(cfg.JWT.Secret)
+    if cfg.JWT.Algorithm != auth.AlgHS256 {
+        keys, err = auth.NewKeySet(auth.KeySetOptions{
+            Algorithm:      cfg.JWT.Algorithm,
+            Dir:            cfg.JWT.KeyDir,
+            RotateAfter:    cfg.JWT.ParsedRotateAfter,
+            ReloadInterval: cfg.JWT.ParsedReloadInterval,
+            TokenTTL:       cfg.JWT.ParsedTTL,
+        })
+        if err != nil {
+            return nil, err
+        }
+    }
+    tokenProvider := auth.NewJWTProviderWithKeys(keys, cfg.JWT.ParsedTTL, refreshRepo)
+    authn := auth.NewMiddleware(tokenProvider, cfg.Auth.CookieName, cfg.Auth.AllowQueryToken)
+    var resetNotifier usecase.PasswordResetNotifier = notify.NewLogNotifier(cfg.Auth.ResetURL)
+    if cfg.Auth.ResetNotifier == "file" {
+        resetNotifier = notify.NewFileNotifier(cfg.Auth.ResetFile, cfg.Auth.ResetURL)
+    }
+    authSvc := usecase.NewAuthService(userRepo, tokenProvider, refreshRepo, resetRepo, twoFactorRepo, auditRepo, resetNotifier, hub, usecase.AuthOptions{
+        RefreshTTL:       cfg.JWT.ParsedRefreshTTL,
+        ResetTokenTTL:    cfg.Auth.ParsedResetTokenTTL,
+        LockoutThreshold: cfg.Auth.LockoutThreshold,
+        LockoutBase:      cfg.Auth.ParsedLockoutBase,
+        LockoutMax:       cfg.Auth.ParsedLockoutMax,
+        TOTPIssuer:       cfg.Auth.TOTPIssuer,
+    })
+    for _, username := range cfg.Auth.Admins {
+        if err := authSvc.GrantRole(ctx, username, domain.RoleAdmin); err != nil && err != domain.ErrNotFound {
+            return nil, err
+        }
+    }
+    providers := make([]usecase.ExternalIdentityProvider, 0, len(cfg.OIDC.Providers))
+    for _, p := range cfg.OIDC.Providers {
+        providers = append(providers, oidc.NewProvider(oidc.Config{
+            Name:         p.Name,
+            Issuer:       p.Issuer,
+            ClientID:     p.ClientID,
+            ClientSecret: p.ClientSecret,
+            RedirectURL:  p.RedirectURL,
+            Scopes:       p.Scopes,
+        }, nil))
+    }
+    externalSvc := usecase.NewExternalLoginService(providers, identityRepo, userRepo, authSvc)
+    achievementSvc := usecase.NewAchievementService(achievementRepo, userRepo, gameRepo, hub)
+    statsSvc := usecase.NewStatsService(statsRepo, userRepo, gameRepo, seasonRepo, achievementSvc)
+    leaderboardSvc := usecase.NewLeaderboardService(statsRepo, userRepo, gameRepo, seasonRepo, usecase.LeaderboardOptions{
+        MinGamesAllTime: cfg.Leaderboard.MinGamesAllTime,
+        MinGamesMonthly: cfg.Leaderboard.MinGamesMonthly,
+        MinGamesWeekly:  cfg.Leaderboard.MinGamesWeekly,
+        MinGamesSeason:  cfg.Leaderboard.MinGamesSeason,
+    })
+    rewards := make([]domain.SeasonReward, 0, len(cfg.Seasons.Rewards))
+    for _, r := range cfg.Seasons.Rewards {
+        rewards = append(rewards, domain.SeasonReward{Top: r.Top, Reward: r.Reward})
+    }
+    seasonSvc := usecase.NewSeasonService(seasonRepo, leaderboardSvc, auditRepo, usecase.SeasonOptions{
+        Schedule:      domain.SeasonSchedule(cfg.Seasons.Schedule),
+        CarryOver:     cfg.Seasons.ParsedCarryOver,
+        Rewards:       rewards,
+        CheckInterval: cfg.Seasons.ParsedCheckInterval,
+    })
+    gameSvc := usecase.NewGameService(gameRepo, eventRepo, hub, auditRepo, statsSvc, leaderboardSvc)
+    matchmaking := usecase.NewMatchmakingService(gameRepo, usecase.MatchmakingOptions{GuestsRated: cfg.Matchmaking.GuestsRated})
+    eventSvc := usecase.NewGameEventService(eventRepo, gameRepo)
 
-    hub := ws.NewHub()
-    wsHandler := ws.NewHandler(hub, tokenProvider, gameSvc, matchmaking)
-    httpHandler := httpadapter.NewHandler(authSvc)
+    friendSvc := usecase.NewFriendService(friendRepo, userRepo, gameRepo, hub)
+    reportSvc := usecase.NewReportService(reportRepo, userRepo, gameRepo, auditRepo)
+    auditSvc := usecase.NewAuditService(auditRepo)
+    accountSvc := usecase.NewAccountService(userRepo, accountRepo, gameRepo, friendRepo, identityRepo, refreshRepo, hub, auditRepo)
+    wsOpts := ws.Options{
+        ReadBufferSize:      cfg.WS.ReadBufferSize,
+        WriteBufferSize:     cfg.WS.WriteBufferSize,
+        SendBufferSize:      cfg.WS.SendBufferSize,
+        MaxMessageSize:      cfg.WS.MaxMessageSize,
+        WriteWait:           cfg.WS.ParsedWriteWait,
+        PongWait:            cfg.WS.ParsedPongWait,
+        SlowConsumerTimeout: cfg.WS.ParsedSlowConsumerTimeout,
+        Compression:         cfg.WS.Compression,
+        CompressionLevel:    cfg.WS.CompressionLevel,
+        AllowedOrigins:      cfg.WS.AllowedOrigins,
+    }
+    wsHandler := ws.NewHandler(hub, gameSvc, matchmaking, friendSvc, eventSvc, wsOpts)
+    httpHandler := httpadapter.NewHandler(authSvc, authn, gameSvc, matchmaking, friendSvc, reportSvc, auditSvc, accountSvc, statsSvc, leaderboardSvc, achievementSvc, seasonSvc, externalSvc, wsHandler, httpadapter.Options{
+        PostLoginRedirect: cfg.OIDC.PostLoginRedirect,
+        TrustForwardedFor: cfg.Auth.TrustForwardedFor,
+    })
 
     mux := http.NewServeMux()
     httpHandler.RegisterRoutes(mux)
-    mux.HandleFunc("/ws", wsHandler.ServeWS)
+    mux.HandleFunc("/ws", authn.Require(wsHandler.ServeWS))
+    mux.HandleFunc("/api/events", authn.Require(wsHandler.ServeSSE))
+    mux.HandleFunc("/.well-known/jwks.json", keys.ServeJWKS)
+    mux.HandleFunc("/debug/vars", authn.RequirePermission(domain.PermAudit, expvar.Handler().ServeHTTP))
 
     server := &http.Server{
         Addr:              httpAddress(cfg.Server.HTTPPort),
-        Handler:           mux,
+        Handler:           httpHandler.WithClientIP(mux),
         ReadHeaderTimeout: 5 * time.Second,
     }
 
-    return &App{Server: server, Hub: hub}, nil
+    return &App{Server: server, Hub: hub, Keys: keys, Seasons: seasonSvc}, nil
 }
 
 func httpAddress(port int) string {
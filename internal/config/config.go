@@ -9,9 +9,15 @@ import (
 )
 
 type Config struct {
-    Server ServerConfig `yaml:"server"`
-    JWT    JWTConfig    `yaml:"jwt"`
-    DB     DBConfig     `yaml:"db"`
+    Server      ServerConfig      `yaml:"server"`
+    JWT         JWTConfig         `yaml:"jwt"`
+    Auth        AuthConfig        `yaml:"auth"`
+    OIDC        OIDCConfig        `yaml:"oidc"`
+    Matchmaking MatchmakingConfig `yaml:"matchmaking"`
+    Leaderboard LeaderboardConfig `yaml:"leaderboard"`
+    Seasons     SeasonsConfig     `yaml:"seasons"`
+    DB          DBConfig          `yaml:"db"`
+    WS          WSConfig          `yaml:"ws"`
 }
 
 type ServerConfig struct {
@@ -19,10 +25,77 @@ type ServerConfig struct {
 }
 
 type JWTConfig struct {
-    Secret string `yaml:"secret"`
-    TTL    string `yaml:"ttl"`
+    Secret         string `yaml:"secret"`
+    TTL            string `yaml:"ttl"`
+    RefreshTTL     string `yaml:"refresh_ttl"`
+    Algorithm      string `yaml:"algorithm"`
+    KeyDir         string `yaml:"key_dir"`
+    RotateAfter    string `yaml:"rotate_after"`
+    ReloadInterval string `yaml:"reload_interval"`
 
-    ParsedTTL time.Duration `yaml:"-"`
+    ParsedTTL            time.Duration `yaml:"-"`
+    ParsedRefreshTTL     time.Duration `yaml:"-"`
+    ParsedRotateAfter    time.Duration `yaml:"-"`
+    ParsedReloadInterval time.Duration `yaml:"-"`
+}
+
+type AuthConfig struct {
+    CookieName        string   `yaml:"cookie_name"`
+    AllowQueryToken   bool     `yaml:"allow_query_token"`
+    TrustForwardedFor bool     `yaml:"trust_forwarded_for"`
+    ResetTokenTTL     string   `yaml:"reset_token_ttl"`
+    ResetNotifier     string   `yaml:"reset_notifier"`
+    ResetFile         string   `yaml:"reset_file"`
+    ResetURL          string   `yaml:"reset_url"`
+    LockoutThreshold  int      `yaml:"lockout_threshold"`
+    LockoutBase       string   `yaml:"lockout_base"`
+    LockoutMax        string   `yaml:"lockout_max"`
+    TOTPIssuer        string   `yaml:"totp_issuer"`
+    Admins            []string `yaml:"admins"`
+
+    ParsedResetTokenTTL time.Duration `yaml:"-"`
+    ParsedLockoutBase   time.Duration `yaml:"-"`
+    ParsedLockoutMax    time.Duration `yaml:"-"`
+}
+
+type OIDCConfig struct {
+    PostLoginRedirect string               `yaml:"post_login_redirect"`
+    Providers         []OIDCProviderConfig `yaml:"providers"`
+}
+
+type OIDCProviderConfig struct {
+    Name         string   `yaml:"name"`
+    Issuer       string   `yaml:"issuer"`
+    ClientID     string   `yaml:"client_id"`
+    ClientSecret string   `yaml:"client_secret"`
+    RedirectURL  string   `yaml:"redirect_url"`
+    Scopes       []string `yaml:"scopes"`
+}
+
+type MatchmakingConfig struct {
+    GuestsRated bool `yaml:"guests_rated"`
+}
+
+type LeaderboardConfig struct {
+    MinGamesAllTime int `yaml:"min_games_all_time"`
+    MinGamesMonthly int `yaml:"min_games_monthly"`
+    MinGamesWeekly  int `yaml:"min_games_weekly"`
+    MinGamesSeason  int `yaml:"min_games_season"`
+}
+
+type SeasonsConfig struct {
+    Schedule      string               `yaml:"schedule"`
+    CarryOver     *float64             `yaml:"carry_over"`
+    CheckInterval string               `yaml:"check_interval"`
+    Rewards       []SeasonRewardConfig `yaml:"rewards"`
+
+    ParsedCarryOver     float64       `yaml:"-"`
+    ParsedCheckInterval time.Duration `yaml:"-"`
+}
+
+type SeasonRewardConfig struct {
+    Top    int    `yaml:"top"`
+    Reward string `yaml:"reward"`
 }
 
 type DBConfig struct {
@@ -30,6 +103,24 @@ type DBConfig struct {
     MaxConns   int32  `yaml:"max_conns"`
 }
 
+type WSConfig struct {
+    SessionPolicy       string   `yaml:"session_policy"`
+    ReadBufferSize      int      `yaml:"read_buffer_size"`
+    WriteBufferSize     int      `yaml:"write_buffer_size"`
+    SendBufferSize      int      `yaml:"send_buffer_size"`
+    MaxMessageSize      int64    `yaml:"max_message_size"`
+    WriteWait           string   `yaml:"write_wait"`
+    PongWait            string   `yaml:"pong_wait"`
+    SlowConsumerTimeout string   `yaml:"slow_consumer_timeout"`
+    Compression         bool     `yaml:"compression"`
+    CompressionLevel    int      `yaml:"compression_level"`
+    AllowedOrigins      []string `yaml:"allowed_origins"`
+
+    ParsedWriteWait           time.Duration `yaml:"-"`
+    ParsedPongWait            time.Duration `yaml:"-"`
+    ParsedSlowConsumerTimeout time.Duration `yaml:"-"`
+}
+
 func Load(path string) (*Config, error) {
     b, err := os.ReadFile(path)
     if err != nil {
@@ -45,12 +136,24 @@ func Load(path string) (*Config, error) {
         cfg.Server.HTTPPort = 8080
     }
 
-    if cfg.JWT.Secret == "" {
-        return nil, fmt.Errorf("jwt.secret is required")
+    if cfg.JWT.Algorithm == "" {
+        cfg.JWT.Algorithm = "HS256"
+    }
+    switch cfg.JWT.Algorithm {
+    case "HS256":
+        if cfg.JWT.Secret == "" {
+            return nil, fmt.Errorf("jwt.secret is required")
+        }
+    case "RS256", "EdDSA":
+        if cfg.JWT.KeyDir == "" {
+            return nil, fmt.Errorf("jwt.key_dir is required for %s", cfg.JWT.Algorithm)
+        }
+    default:
+        return nil, fmt.Errorf("invalid jwt.algorithm: %q", cfg.JWT.Algorithm)
     }
 
     if cfg.JWT.TTL == "" {
-        cfg.JWT.TTL = "24h"
+        cfg.JWT.TTL = "15m"
     }
 
     ttl, err := time.ParseDuration(cfg.JWT.TTL)
@@ -59,6 +162,137 @@ func Load(path string) (*Config, error) {
     }
     cfg.JWT.ParsedTTL = ttl
 
+    if cfg.JWT.RefreshTTL == "" {
+        cfg.JWT.RefreshTTL = "720h"
+    }
+
+    refreshTTL, err := time.ParseDuration(cfg.JWT.RefreshTTL)
+    if err != nil {
+        return nil, fmt.Errorf("invalid jwt.refresh_ttl: %w", err)
+    }
+    cfg.JWT.ParsedRefreshTTL = refreshTTL
+
+    if cfg.JWT.RotateAfter != "" {
+        rotateAfter, err := time.ParseDuration(cfg.JWT.RotateAfter)
+        if err != nil {
+            return nil, fmt.Errorf("invalid jwt.rotate_after: %w", err)
+        }
+        cfg.JWT.ParsedRotateAfter = rotateAfter
+    }
+
+    if cfg.JWT.ReloadInterval == "" {
+        cfg.JWT.ReloadInterval = "1m"
+    }
+
+    reload, err := time.ParseDuration(cfg.JWT.ReloadInterval)
+    if err != nil {
+        return nil, fmt.Errorf("invalid jwt.reload_interval: %w", err)
+    }
+    cfg.JWT.ParsedReloadInterval = reload
+
+    if cfg.Auth.CookieName == "" {
+        cfg.Auth.CookieName = "xo_token"
+    }
+    if cfg.Auth.ResetTokenTTL == "" {
+        cfg.Auth.ResetTokenTTL = "30m"
+    }
+    if cfg.Auth.ResetNotifier == "" {
+        cfg.Auth.ResetNotifier = "log"
+    }
+    if cfg.Auth.ResetNotifier != "log" && cfg.Auth.ResetNotifier != "file" {
+        return nil, fmt.Errorf("invalid auth.reset_notifier: %q", cfg.Auth.ResetNotifier)
+    }
+    if cfg.Auth.ResetNotifier == "file" && cfg.Auth.ResetFile == "" {
+        return nil, fmt.Errorf("auth.reset_file is required for the file notifier")
+    }
+    if cfg.Auth.LockoutThreshold == 0 {
+        cfg.Auth.LockoutThreshold = 5
+    }
+    if cfg.Auth.LockoutThreshold < 0 {
+        return nil, fmt.Errorf("invalid auth.lockout_threshold: %d", cfg.Auth.LockoutThreshold)
+    }
+    if cfg.Auth.LockoutBase == "" {
+        cfg.Auth.LockoutBase = "30s"
+    }
+    if cfg.Auth.LockoutMax == "" {
+        cfg.Auth.LockoutMax = "15m"
+    }
+    if cfg.Auth.TOTPIssuer == "" {
+        cfg.Auth.TOTPIssuer = "XO Server"
+    }
+
+    resetTTL, err := time.ParseDuration(cfg.Auth.ResetTokenTTL)
+    if err != nil {
+        return nil, fmt.Errorf("invalid auth.reset_token_ttl: %w", err)
+    }
+    cfg.Auth.ParsedResetTokenTTL = resetTTL
+
+    lockoutBase, err := time.ParseDuration(cfg.Auth.LockoutBase)
+    if err != nil {
+        return nil, fmt.Errorf("invalid auth.lockout_base: %w", err)
+    }
+    cfg.Auth.ParsedLockoutBase = lockoutBase
+
+    lockoutMax, err := time.ParseDuration(cfg.Auth.LockoutMax)
+    if err != nil {
+        return nil, fmt.Errorf("invalid auth.lockout_max: %w", err)
+    }
+    cfg.Auth.ParsedLockoutMax = lockoutMax
+
+    seen := make(map[string]bool)
+    for _, p := range cfg.OIDC.Providers {
+        if p.Name == "" || p.Issuer == "" || p.ClientID == "" || p.RedirectURL == "" {
+            return nil, fmt.Errorf("oidc provider %q: name, issuer, client_id and redirect_url are required", p.Name)
+        }
+        if seen[p.Name] {
+            return nil, fmt.Errorf("duplicate oidc provider %q", p.Name)
+        }
+        seen[p.Name] = true
+    }
+
+    if cfg.Leaderboard.MinGamesAllTime == 0 {
+        cfg.Leaderboard.MinGamesAllTime = 10
+    }
+    if cfg.Leaderboard.MinGamesMonthly == 0 {
+        cfg.Leaderboard.MinGamesMonthly = 5
+    }
+    if cfg.Leaderboard.MinGamesWeekly == 0 {
+        cfg.Leaderboard.MinGamesWeekly = 3
+    }
+    if cfg.Leaderboard.MinGamesSeason == 0 {
+        cfg.Leaderboard.MinGamesSeason = 5
+    }
+    if cfg.Leaderboard.MinGamesAllTime < 0 || cfg.Leaderboard.MinGamesMonthly < 0 || cfg.Leaderboard.MinGamesWeekly < 0 || cfg.Leaderboard.MinGamesSeason < 0 {
+        return nil, fmt.Errorf("leaderboard min games must not be negative")
+    }
+
+    if cfg.Seasons.Schedule == "" {
+        cfg.Seasons.Schedule = "manual"
+    }
+    if cfg.Seasons.Schedule != "manual" && cfg.Seasons.Schedule != "monthly" && cfg.Seasons.Schedule != "quarterly" {
+        return nil, fmt.Errorf("invalid seasons.schedule: %q", cfg.Seasons.Schedule)
+    }
+    cfg.Seasons.ParsedCarryOver = 0.5
+    if cfg.Seasons.CarryOver != nil {
+        cfg.Seasons.ParsedCarryOver = *cfg.Seasons.CarryOver
+    }
+    if cfg.Seasons.ParsedCarryOver < 0 || cfg.Seasons.ParsedCarryOver > 1 {
+        return nil, fmt.Errorf("invalid seasons.carry_over: %v", cfg.Seasons.ParsedCarryOver)
+    }
+    if cfg.Seasons.CheckInterval == "" {
+        cfg.Seasons.CheckInterval = "1m"
+    }
+    checkInterval, err := time.ParseDuration(cfg.Seasons.CheckInterval)
+    if err != nil {
+        return nil, fmt.Errorf("invalid seasons.check_interval: %w", err)
+    }
+    cfg.Seasons.ParsedCheckInterval = checkInterval
+    for _, r := range cfg.Seasons.Rewards {
+        if r.Top <= 0 || r.Reward == "" {
+            return nil, fmt.Errorf("season reward %q: top and reward are required", r.Reward)
+        }
+    }
+
     if cfg.DB.ConnString == "" {
         return nil, fmt.Errorf("db.conn_string is required")
     }
@@ -66,5 +300,57 @@ func Load(path string) (*Config, error) {
         cfg.DB.MaxConns = 10
     }
 
+    if cfg.WS.SessionPolicy == "" {
+        cfg.WS.SessionPolicy = "multi"
+    }
+    if cfg.WS.SessionPolicy != "multi" && cfg.WS.SessionPolicy != "single" {
+        return nil, fmt.Errorf("invalid ws.session_policy: %q", cfg.WS.SessionPolicy)
+    }
+    if cfg.WS.ReadBufferSize == 0 {
+        cfg.WS.ReadBufferSize = 1024
+    }
+    if cfg.WS.WriteBufferSize == 0 {
+        cfg.WS.WriteBufferSize = 1024
+    }
+    if cfg.WS.SendBufferSize == 0 {
+        cfg.WS.SendBufferSize = 256
+    }
+    if cfg.WS.MaxMessageSize == 0 {
+        cfg.WS.MaxMessageSize = 4096
+    }
+    if cfg.WS.WriteWait == "" {
+        cfg.WS.WriteWait = "10s"
+    }
+    if cfg.WS.PongWait == "" {
+        cfg.WS.PongWait = "60s"
+    }
+    if cfg.WS.SlowConsumerTimeout == "" {
+        cfg.WS.SlowConsumerTimeout = "10s"
+    }
+    if cfg.WS.CompressionLevel == 0 {
+        cfg.WS.CompressionLevel = 1
+    }
+    if cfg.WS.CompressionLevel < -2 || cfg.WS.CompressionLevel > 9 {
+        return nil, fmt.Errorf("invalid ws.compression_level: %d", cfg.WS.CompressionLevel)
+    }
+
+    writeWait, err := time.ParseDuration(cfg.WS.WriteWait)
+    if err != nil {
+        return nil, fmt.Errorf("invalid ws.write_wait: %w", err)
+    }
+    cfg.WS.ParsedWriteWait = writeWait
+
+    pongWait, err := time.ParseDuration(cfg.WS.PongWait)
+    if err != nil {
+        return nil, fmt.Errorf("invalid ws.pong_wait: %w", err)
+    }
+    cfg.WS.ParsedPongWait = pongWait
+
+    slow, err := time.ParseDuration(cfg.WS.SlowConsumerTimeout)
+    if err != nil {
+        return nil, fmt.Errorf("invalid ws.slow_consumer_timeout: %w", err)
+    }
+    cfg.WS.ParsedSlowConsumerTimeout = slow
+
     return &cfg, nil
 }
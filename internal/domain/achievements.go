@@ -0,0 +1,100 @@
+﻿package domain
+
+import (
+    "time"
+
+    "github.com/google/uuid"
+)
+
+type AchievementCode string
+
+const (
+    AchievementFirstGame   AchievementCode = "first_game"
+    AchievementFirstWin    AchievementCode = "first_win"
+    AchievementStreak3     AchievementCode = "win_streak_3"
+    AchievementStreak10    AchievementCode = "win_streak_10"
+    AchievementForkWin     AchievementCode = "fork_win"
+    AchievementBotDraw     AchievementCode = "bot_draw"
+    AchievementHundredGame AchievementCode = "games_100"
+)
+
+type Achievement struct {
+    Code        AchievementCode
+    Name        string
+    Description string
+}
+
+var Achievements = []Achievement{
+    {Code: AchievementFirstGame, Name: "First game", Description: "Finish a game"},
+    {Code: AchievementFirstWin, Name: "First win", Description: "Win a game"},
+    {Code: AchievementStreak3, Name: "On a roll", Description: "Win 3 games in a row"},
+    {Code: AchievementStreak10, Name: "Unstoppable", Description: "Win 10 games in a row"},
+    {Code: AchievementForkWin, Name: "Forked", Description: "Win after creating two threats with one move"},
+    {Code: AchievementBotDraw, Name: "Even with the machine", Description: "Draw against a bot"},
+    {Code: AchievementHundredGame, Name: "Centurion", Description: "Finish 100 games"},
+}
+
+func AchievementByCode(code AchievementCode) (Achievement, bool) {
+    for _, a := range Achievements {
+        if a.Code == code {
+            return a, true
+        }
+    }
+    return Achievement{}, false
+}
+
+type UserAchievement struct {
+    UserID     uuid.UUID
+    Code       AchievementCode
+    GameID     *uuid.UUID
+    UnlockedAt time.Time
+}
+
+var boardLines = [8][3]int{{0, 1, 2}, {3, 4, 5}, {6, 7, 8}, {0, 3, 6}, {1, 4, 7}, {2, 5, 8}, {0, 4, 8}, {2, 4, 6}}
+
+func CreatedFork(moves []*GameMove, symbol string) bool {
+    if len(symbol) != 1 {
+        return false
+    }
+    mark := rune(symbol[0])
+    board := NewEmptyBoard()
+    for _, m := range moves {
+        if m.Position < 0 || m.Position >= len(board) || len(m.Symbol) != 1 {
+            return false
+        }
+        before := winningCells(board, mark)
+        board[m.Position] = rune(m.Symbol[0])
+        if m.Symbol != symbol {
+            continue
+        }
+        opened := 0
+        for i, open := range winningCells(board, mark) {
+            if open && !before[i] {
+                opened++
+            }
+        }
+        if opened >= 2 {
+            return true
+        }
+    }
+    return false
+}
+
+func winningCells(board [9]rune, mark rune) [9]bool {
+    var cells [9]bool
+    for _, line := range boardLines {
+        own, empty := 0, -1
+        for _, i := range line {
+            switch board[i] {
+            case mark:
+                own++
+            case '.':
+                empty = i
+            }
+        }
+        if own == 2 && empty >= 0 {
+            cells[empty] = true
+        }
+    }
+    return cells
+}
@@ -3,14 +3,23 @@
 import "errors"
 
 var (
-    ErrNotFound        = errors.New("not found")
-    ErrInvalidInput    = errors.New("invalid input")
-    ErrUnauthorized    = errors.New("unauthorized")
-    ErrForbidden       = errors.New("forbidden")
-    ErrGameNotActive   = errors.New("game not active")
-    ErrNotYourTurn     = errors.New("not your turn")
-    ErrPositionTaken   = errors.New("position taken")
-    ErrInvalidPosition = errors.New("invalid position")
-    ErrAlreadyInQueue  = errors.New("already in queue")
-    ErrDrawNotOffered  = errors.New("draw not offered")
+    ErrNotFound         = errors.New("not found")
+    ErrInvalidInput     = errors.New("invalid input")
+    ErrUnauthorized     = errors.New("unauthorized")
+    ErrForbidden        = errors.New("forbidden")
+    ErrGameNotActive    = errors.New("game not active")
+    ErrNotYourTurn      = errors.New("not your turn")
+    ErrPositionTaken    = errors.New("position taken")
+    ErrInvalidPosition  = errors.New("invalid position")
+    ErrAlreadyInQueue   = errors.New("already in queue")
+    ErrDrawNotOffered   = errors.New("draw not offered")
+    ErrNotFriends       = errors.New("not friends")
+    ErrIdentityLinked   = errors.New("identity already linked")
+    ErrTooManyAttempts  = errors.New("too many attempts")
+    ErrTwoFactorActive  = errors.New("two-factor already enabled")
+    ErrGuestNotAllowed  = errors.New("not available for guest accounts")
+    ErrAccountSuspended = errors.New("account suspended")
+    ErrActiveGames      = errors.New("account has active games")
+    ErrSeasonOpen       = errors.New("a season is already open")
+    ErrReauthRequired   = errors.New("sign in again to continue")
 )
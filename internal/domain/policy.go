@@ -0,0 +1,60 @@
+﻿package domain
+
+type Role string
+
+const (
+    RolePlayer    Role = "player"
+    RoleModerator Role = "moderator"
+    RoleAdmin     Role = "admin"
+    RoleBot       Role = "bot"
+)
+
+func ParseRole(s string) (Role, bool) {
+    switch r := Role(s); r {
+    case RolePlayer, RoleModerator, RoleAdmin, RoleBot:
+        return r, true
+    default:
+        return "", false
+    }
+}
+
+type Permission string
+
+const (
+    PermPlay        Permission = "play"
+    PermChat        Permission = "chat"
+    PermSocial      Permission = "social"
+    PermModerate    Permission = "moderate"
+    PermManageGames Permission = "manage_games"
+    PermManageUsers Permission = "manage_users"
+    PermAudit       Permission = "audit"
+)
+
+var rolePermissions = map[Role][]Permission{
+    RolePlayer:    {PermPlay, PermChat, PermSocial},
+    RoleBot:       {PermPlay},
+    RoleModerator: {PermPlay, PermChat, PermSocial, PermModerate},
+    RoleAdmin:     {PermPlay, PermChat, PermSocial, PermModerate, PermManageGames, PermManageUsers, PermAudit},
+}
+
+func (r Role) Can(perm Permission) bool {
+    if r == "" {
+        r = RolePlayer
+    }
+    for _, p := range rolePermissions[r] {
+        if p == perm {
+            return true
+        }
+    }
+    return false
+}
+
+func Authorize(user *User, perm Permission) error {
+    if user == nil {
+        return ErrUnauthorized
+    }
+    if !user.Role.Can(perm) {
+        return ErrForbidden
+    }
+    return nil
+}
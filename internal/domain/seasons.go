@@ -0,0 +1,89 @@
+﻿package domain
+
+import (
+    "fmt"
+    "math"
+    "time"
+
+    "github.com/google/uuid"
+)
+
+type SeasonStatus string
+
+const (
+    SeasonOpen   SeasonStatus = "open"
+    SeasonClosed SeasonStatus = "closed"
+)
+
+type SeasonSchedule string
+
+const (
+    ScheduleManual    SeasonSchedule = "manual"
+    ScheduleMonthly   SeasonSchedule = "monthly"
+    ScheduleQuarterly SeasonSchedule = "quarterly"
+)
+
+func (s SeasonSchedule) End(start time.Time) *time.Time {
+    start = start.UTC()
+    var end time.Time
+    switch s {
+    case ScheduleMonthly:
+        end = time.Date(start.Year(), start.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+    case ScheduleQuarterly:
+        quarter := (int(start.Month()) - 1) / 3
+        end = time.Date(start.Year(), time.Month(quarter*3+4), 1, 0, 0, 0, 0, time.UTC)
+    default:
+        return nil
+    }
+    return &end
+}
+
+func (s SeasonSchedule) Name(start time.Time) string {
+    start = start.UTC()
+    switch s {
+    case ScheduleMonthly:
+        return start.Format("2006-01")
+    case ScheduleQuarterly:
+        return fmt.Sprintf("%d-Q%d", start.Year(), (int(start.Month())-1)/3+1)
+    default:
+        return start.Format("2006-01-02")
+    }
+}
+
+type Season struct {
+    ID        uuid.UUID
+    Name      string
+    Status    SeasonStatus
+    StartsAt  time.Time
+    EndsAt    *time.Time
+    ClosedAt  *time.Time
+    CarryOver float64
+}
+
+type SeasonReward struct {
+    Top    int
+    Reward string
+}
+
+type SeasonStanding struct {
+    SeasonID uuid.UUID
+    Rank     int
+    UserID   uuid.UUID
+    Username string
+    Rating   int
+    Games    int
+    Wins     int
+    Draws    int
+    Losses   int
+    Reward   string
+}
+
+type SeasonStandings struct {
+    Season  *Season
+    Total   int
+    Entries []*SeasonStanding
+}
+
+func SoftReset(rating int, carryOver float64) int {
+    return DefaultRating + int(math.Round(float64(rating-DefaultRating)*carryOver))
+}
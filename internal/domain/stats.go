@@ -0,0 +1,221 @@
+﻿package domain
+
+import (
+    "math"
+    "time"
+
+    "github.com/google/uuid"
+)
+
+const (
+    VariantStandard    = "standard"
+    TimeControlUntimed = "untimed"
+    DefaultRating      = 1500
+    maxGameLabel       = 32
+    eloK               = 32
+)
+
+type LeaderboardPeriod string
+
+const (
+    PeriodAllTime LeaderboardPeriod = "all"
+    PeriodMonthly LeaderboardPeriod = "monthly"
+    PeriodWeekly  LeaderboardPeriod = "weekly"
+    PeriodSeason  LeaderboardPeriod = "season"
+)
+
+type PlayerStats struct {
+    UserID        uuid.UUID
+    Wins          int
+    Losses        int
+    Draws         int
+    GamesAsX      int
+    WinsAsX       int
+    GamesAsO      int
+    WinsAsO       int
+    CurrentStreak int
+    BestStreak    int
+    UpdatedAt     time.Time
+}
+
+func (s *PlayerStats) Games() int {
+    return s.Wins + s.Losses + s.Draws
+}
+
+func (s *PlayerStats) Record(game *Game, userID uuid.UUID) {
+    asX := game.PlayerX == userID
+    if asX {
+        s.GamesAsX++
+    } else {
+        s.GamesAsO++
+    }
+
+    switch {
+    case game.WinnerUserID == nil:
+        s.Draws++
+        s.CurrentStreak = 0
+    case *game.WinnerUserID == userID:
+        s.Wins++
+        if asX {
+            s.WinsAsX++
+        } else {
+            s.WinsAsO++
+        }
+        s.CurrentStreak++
+        if s.CurrentStreak > s.BestStreak {
+            s.BestStreak = s.CurrentStreak
+        }
+    default:
+        s.Losses++
+        s.CurrentStreak = 0
+    }
+    s.UpdatedAt = game.UpdatedAt
+}
+
+type ColourRecord struct {
+    Wins   int
+    Losses int
+    Draws  int
+}
+
+func (r *ColourRecord) Games() int {
+    return r.Wins + r.Losses + r.Draws
+}
+
+func (r *ColourRecord) Add(game *Game, userID uuid.UUID) {
+    switch {
+    case game.WinnerUserID == nil:
+        r.Draws++
+    case *game.WinnerUserID == userID:
+        r.Wins++
+    default:
+        r.Losses++
+    }
+}
+
+func (r ColourRecord) Plus(other ColourRecord) ColourRecord {
+    return ColourRecord{Wins: r.Wins + other.Wins, Losses: r.Losses + other.Losses, Draws: r.Draws + other.Draws}
+}
+
+type HeadToHead struct {
+    User     *User
+    Opponent *User
+    Total    ColourRecord
+    AsX      ColourRecord
+    AsO      ColourRecord
+    Games    []*Game
+}
+
+type Rating struct {
+    UserID      uuid.UUID
+    Variant     string
+    TimeControl string
+    Rating      int
+    Games       int
+    UpdatedAt   time.Time
+}
+
+type RatingChange struct {
+    GameID      uuid.UUID
+    UserID      uuid.UUID
+    Variant     string
+    TimeControl string
+    Before      int
+    After       int
+    CreatedAt   time.Time
+}
+
+type StatsUpdate struct {
+    Stats   []*PlayerStats
+    Ratings []*Rating
+    Changes []*RatingChange
+    Cleared []uuid.UUID
+}
+
+func (g *Game) RatingPool() (string, string) {
+    variant, timeControl := g.Variant, g.TimeControl
+    if variant == "" {
+        variant = VariantStandard
+    }
+    if timeControl == "" {
+        timeControl = TimeControlUntimed
+    }
+    return variant, timeControl
+}
+
+func ValidGameLabel(label string) bool {
+    if label == "" || len(label) > maxGameLabel {
+        return false
+    }
+    for i, c := range label {
+        switch {
+        case c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+        case (c == '-' || c == '_') && i > 0:
+        default:
+            return false
+        }
+    }
+    return true
+}
+
+func EloRatings(a, b int, scoreA float64) (int, int) {
+    expectedA := 1 / (1 + math.Pow(10, float64(b-a)/400))
+    delta := int(math.Round(eloK * (scoreA - expectedA)))
+    return a + delta, b - delta
+}
+
+type Profile struct {
+    User        *User
+    Stats       *PlayerStats
+    Ratings     []*Rating
+    RecentGames []*Game
+}
+
+func (p LeaderboardPeriod) Start(now time.Time) *time.Time {
+    now = now.UTC()
+    var start time.Time
+    switch p {
+    case PeriodMonthly:
+        start = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+    case PeriodWeekly:
+        days := (int(now.Weekday()) + 6) % 7
+        start = time.Date(now.Year(), now.Month(), now.Day()-days, 0, 0, 0, 0, time.UTC)
+    default:
+        return nil
+    }
+    return &start
+}
+
+type LeaderboardQuery struct {
+    Variant     string
+    TimeControl string
+    Period      LeaderboardPeriod
+    Limit       int
+    Offset      int
+}
+
+type LeaderboardEntry struct {
+    Rank     int
+    UserID   uuid.UUID
+    Username string
+    Rating   int
+    Games    int
+    Wins     int
+    Draws    int
+    Losses   int
+}
+
+func (e *LeaderboardEntry) Points() float64 {
+    return float64(e.Wins) + float64(e.Draws)/2
+}
+
+type Leaderboard struct {
+    Variant     string
+    TimeControl string
+    Period      LeaderboardPeriod
+    Since       *time.Time
+    MinGames    int
+    Total       int
+    Entries     []*LeaderboardEntry
+    UpdatedAt   time.Time
+}
\ No newline at end of file
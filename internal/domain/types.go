@@ -15,13 +15,97 @@ const (
     GameInProgress GameStatus = "in_progress"
     GameFinished   GameStatus = "finished"
     GameDrawOffer  GameStatus = "draw_offered"
+    GameAborted    GameStatus = "aborted"
+)
+
+type QueuePool string
+
+const (
+    PoolRated  QueuePool = "rated"
+    PoolCasual QueuePool = "casual"
+)
+
+const (
+    GuestUsernamePrefix   = "guest-"
+    DeletedUsernamePrefix = "deleted-"
 )
 
 type User struct {
-    ID           uuid.UUID
-    Username     string
-    PasswordHash string
+    ID               uuid.UUID
+    Username         string
+    PasswordHash     string
+    Role             Role
+    IsGuest          bool
+    Banned           bool
+    SuspendedUntil   *time.Time
+    SuspensionReason string
+    CreatedAt        time.Time
+    DeletedAt        *time.Time
+}
+
+func (u *User) Suspended(now time.Time) bool {
+    return u.Banned || (u.SuspendedUntil != nil && now.Before(*u.SuspendedUntil))
+}
+
+type RefreshToken struct {
+    ID        uuid.UUID
+    FamilyID  uuid.UUID
+    UserID    uuid.UUID
+    TokenHash string
+    ExpiresAt time.Time
+    CreatedAt time.Time
+    RotatedAt *time.Time
+    RevokedAt *time.Time
+}
+
+type PasswordResetToken struct {
+    ID        uuid.UUID
+    UserID    uuid.UUID
+    TokenHash string
+    ExpiresAt time.Time
+    CreatedAt time.Time
+    UsedAt    *time.Time
+}
+
+type TOTPSecret struct {
+    UserID       uuid.UUID
+    Secret       string
     CreatedAt    time.Time
+    EnabledAt    *time.Time
+    LastUsedStep int64
+}
+
+type TOTPEnrollment struct {
+    Secret string
+    URI    string
+}
+
+type LoginResult struct {
+    User               *User
+    Tokens             *TokenPair
+    ChallengeToken     string
+    ChallengeExpiresAt time.Time
+}
+
+type ExternalIdentity struct {
+    Provider  string
+    Subject   string
+    UserID    uuid.UUID
+    Email     string
+    CreatedAt time.Time
+}
+
+type ExternalClaims struct {
+    Subject           string
+    Email             string
+    PreferredUsername string
+    Name              string
+}
+
+type TokenPair struct {
+    AccessToken  string
+    RefreshToken string
+    SessionID    uuid.UUID
 }
 
 type Game struct {
@@ -33,6 +117,10 @@ type Game struct {
     Status        GameStatus
     WinnerUserID  *uuid.UUID
     DrawOfferedBy *uuid.UUID
+    Rated         bool
+    EndReason     string
+    Variant       string
+    TimeControl   string
     CreatedAt     time.Time
     UpdatedAt     time.Time
 }
@@ -85,3 +173,128 @@ type GameMessage struct {
     Message   string
     CreatedAt time.Time
 }
+
+const (
+    GameEventFound  = "game_found"
+    GameEventUpdate = "game_update"
+    GameEventChat   = "chat"
+)
+
+type GameEvent struct {
+    GameID    uuid.UUID
+    Seq       int64
+    Type      string
+    Payload   []byte
+    CreatedAt time.Time
+}
+
+type FriendshipStatus string
+
+const (
+    FriendshipPending  FriendshipStatus = "pending"
+    FriendshipAccepted FriendshipStatus = "accepted"
+)
+
+type Friendship struct {
+    RequesterID uuid.UUID
+    AddresseeID uuid.UUID
+    Status      FriendshipStatus
+    CreatedAt   time.Time
+    UpdatedAt   time.Time
+}
+
+func (f *Friendship) Other(userID uuid.UUID) uuid.UUID {
+    if f.RequesterID == userID {
+        return f.AddresseeID
+    }
+    return f.RequesterID
+}
+
+type Friend struct {
+    UserID   uuid.UUID
+    Username string
+    Since    time.Time
+    Online   bool
+}
+
+type FriendRequest struct {
+    UserID    uuid.UUID
+    Username  string
+    Incoming  bool
+    CreatedAt time.Time
+}
+
+type ReportStatus string
+
+const (
+    ReportOpen     ReportStatus = "open"
+    ReportResolved ReportStatus = "resolved"
+)
+
+type Report struct {
+    ID         uuid.UUID
+    ReporterID uuid.UUID
+    UserID     uuid.UUID
+    GameID     *uuid.UUID
+    Reason     string
+    Status     ReportStatus
+    Resolution string
+    ResolvedBy *uuid.UUID
+    CreatedAt  time.Time
+    ResolvedAt *time.Time
+}
+
+type AuditAction string
+
+const (
+    AuditRegister          AuditAction = "register"
+    AuditLogin             AuditAction = "login"
+    AuditLoginFailed       AuditAction = "login_failed"
+    AuditLogout            AuditAction = "logout"
+    AuditTokenReuse        AuditAction = "token_reuse"
+    AuditPasswordChanged   AuditAction = "password_changed"
+    AuditPasswordReset     AuditAction = "password_reset"
+    AuditTwoFactorEnabled  AuditAction = "two_factor_enabled"
+    AuditTwoFactorDisabled AuditAction = "two_factor_disabled"
+    AuditRoleChanged       AuditAction = "role_changed"
+    AuditUserSuspended     AuditAction = "user_suspended"
+    AuditUserBanned        AuditAction = "user_banned"
+    AuditSuspensionLifted  AuditAction = "suspension_lifted"
+    AuditGameFinished      AuditAction = "game_force_finished"
+    AuditGameAborted       AuditAction = "game_aborted"
+    AuditResultAdjusted    AuditAction = "game_result_adjusted"
+    AuditReportResolved    AuditAction = "report_resolved"
+    AuditDataExported      AuditAction = "data_exported"
+    AuditAccountDeleted    AuditAction = "account_deleted"
+    AuditSeasonOpened      AuditAction = "season_opened"
+    AuditSeasonClosed      AuditAction = "season_closed"
+)
+
+type AuditEvent struct {
+    ID        uuid.UUID
+    Action    AuditAction
+    ActorID   *uuid.UUID
+    TargetID  *uuid.UUID
+    IP        string
+    Detail    string
+    CreatedAt time.Time
+}
+
+type AuditFilter struct {
+    Action   AuditAction
+    ActorID  *uuid.UUID
+    TargetID *uuid.UUID
+    Since    *time.Time
+    Until    *time.Time
+    Limit    int
+}
+
+type AccountExport struct {
+    User        *User
+    Games       []*Game
+    Moves       []*GameMove
+    Messages    []*GameMessage
+    Friendships []*Friendship
+    Identities  []*ExternalIdentity
+    ExportedAt  time.Time
+}
@@ -0,0 +1,126 @@
+﻿package usecase
+
+import (
+    "context"
+    "strings"
+    "time"
+
+    "github.com/google/uuid"
+    "golang.org/x/crypto/bcrypt"
+    "xo-server/internal/domain"
+)
+
+const (
+    redactedMessage = "[deleted]"
+    reauthWindow    = 5 * time.Minute
+)
+
+type accountService struct {
+    users      UserRepository
+    accounts   AccountRepository
+    games      GameRepository
+    friends    FriendRepository
+    identities IdentityRepository
+    refresh    RefreshTokenRepository
+    sessions   SessionCloser
+    audit      AuditRepository
+}
+
+func NewAccountService(users UserRepository, accounts AccountRepository, games GameRepository, friends FriendRepository, identities IdentityRepository, refresh RefreshTokenRepository, sessions SessionCloser, audit AuditRepository) AccountService {
+    return &accountService{
+        users:      users,
+        accounts:   accounts,
+        games:      games,
+        friends:    friends,
+        identities: identities,
+        refresh:    refresh,
+        sessions:   sessions,
+        audit:      audit,
+    }
+}
+
+func (s *accountService) ExportData(ctx context.Context, userID uuid.UUID) (*domain.AccountExport, error) {
+    user, err := s.users.GetUserByID(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+
+    out := &domain.AccountExport{User: user, ExportedAt: time.Now().UTC()}
+    if out.Games, err = s.games.ListGamesByUser(ctx, userID); err != nil {
+        return nil, err
+    }
+    if out.Moves, err = s.games.ListMovesByUser(ctx, userID); err != nil {
+        return nil, err
+    }
+    if out.Messages, err = s.games.ListMessagesByUser(ctx, userID); err != nil {
+        return nil, err
+    }
+    if out.Friendships, err = s.friends.ListFriendshipsByUser(ctx, userID); err != nil {
+        return nil, err
+    }
+    if out.Identities, err = s.identities.ListIdentitiesByUser(ctx, userID); err != nil {
+        return nil, err
+    }
+
+    recordAudit(ctx, s.audit, domain.AuditDataExported, userID, userID, "")
+    return out, nil
+}
+
+func (s *accountService) DeleteAccount(ctx context.Context, userID, sessionID uuid.UUID, password string) error {
+    user, err := s.users.GetUserByID(ctx, userID)
+    if err != nil {
+        return err
+    }
+    switch {
+    case user.PasswordHash != "":
+        if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+            return domain.ErrUnauthorized
+        }
+    case user.IsGuest:
+        if _, err := s.sessionStart(ctx, sessionID); err != nil {
+            return err
+        }
+    default:
+        if err := s.requireRecentSignIn(ctx, sessionID); err != nil {
+            return err
+        }
+    }
+
+    active, err := s.games.ListActiveGamesByUser(ctx, userID)
+    if err != nil {
+        return err
+    }
+    if len(active) > 0 {
+        return domain.ErrActiveGames
+    }
+
+    username := domain.DeletedUsernamePrefix + strings.ReplaceAll(userID.String(), "-", "")
+    if err := s.accounts.AnonymizeAccount(ctx, userID, username, redactedMessage, time.Now().UTC()); err != nil {
+        return err
+    }
+
+    recordAudit(ctx, s.audit, domain.AuditAccountDeleted, userID, userID, "")
+    return revokeUserSessions(ctx, s.refresh, s.sessions, userID, uuid.Nil)
+}
+
+func (s *accountService) requireRecentSignIn(ctx context.Context, sessionID uuid.UUID) error {
+    started, err := s.sessionStart(ctx, sessionID)
+    if err != nil {
+        return err
+    }
+    if time.Since(started) > reauthWindow {
+        return domain.ErrReauthRequired
+    }
+    return nil
+}
+
+func (s *accountService) sessionStart(ctx context.Context, sessionID uuid.UUID) (time.Time, error) {
+    if sessionID == uuid.Nil {
+        return time.Time{}, domain.ErrReauthRequired
+    }
+    started, err := s.refresh.GetTokenFamilyStart(ctx, sessionID)
+    if err == domain.ErrNotFound {
+        return time.Time{}, domain.ErrReauthRequired
+    }
+    return started, err
+}
@@ -0,0 +1,112 @@
+﻿package usecase
+
+import (
+    "context"
+    "log"
+    "time"
+
+    "github.com/google/uuid"
+    "xo-server/internal/domain"
+)
+
+type achievementEvent struct {
+    game     *domain.Game
+    userID   uuid.UUID
+    symbol   string
+    opponent *domain.User
+    stats    *domain.PlayerStats
+    moves    []*domain.GameMove
+}
+
+func (e *achievementEvent) won() bool {
+    return e.game.WinnerUserID != nil && *e.game.WinnerUserID == e.userID
+}
+
+type achievementRule struct {
+    code     domain.AchievementCode
+    unlocked func(e *achievementEvent) bool
+}
+
+var achievementRules = []achievementRule{
+    {domain.AchievementFirstGame, func(e *achievementEvent) bool { return true }},
+    {domain.AchievementFirstWin, func(e *achievementEvent) bool { return e.won() }},
+    {domain.AchievementStreak3, func(e *achievementEvent) bool { return e.stats.CurrentStreak >= 3 }},
+    {domain.AchievementStreak10, func(e *achievementEvent) bool { return e.stats.CurrentStreak >= 10 }},
+    {domain.AchievementForkWin, func(e *achievementEvent) bool { return e.won() && domain.CreatedFork(e.moves, e.symbol) }},
+    {domain.AchievementBotDraw, func(e *achievementEvent) bool {
+        return e.game.WinnerUserID == nil && e.opponent != nil && e.opponent.Role == domain.RoleBot
+    }},
+    {domain.AchievementHundredGame, func(e *achievementEvent) bool { return e.stats.Games() >= 100 }},
+}
+
+type achievementService struct {
+    achievements AchievementRepository
+    users        UserRepository
+    games        GameRepository
+    notifier     AchievementNotifier
+}
+
+func NewAchievementService(achievements AchievementRepository, users UserRepository, games GameRepository, notifier AchievementNotifier) AchievementService {
+    return &achievementService{achievements: achievements, users: users, games: games, notifier: notifier}
+}
+
+func (s *achievementService) ListAchievements(ctx context.Context, username string) ([]*domain.UserAchievement, error) {
+    user, err := s.users.GetUserByUsername(ctx, username)
+    if err != nil {
+        return nil, err
+    }
+    if user.DeletedAt != nil {
+        return nil, domain.ErrNotFound
+    }
+    return s.achievements.ListAchievements(ctx, user.ID)
+}
+
+func (s *achievementService) StatsRecorded(ctx context.Context, game *domain.Game, stats []*domain.PlayerStats) {
+    if err := s.evaluate(ctx, game, stats); err != nil {
+        log.Printf("achievements for game %s error: %v", game.ID, err)
+    }
+}
+
+func (s *achievementService) evaluate(ctx context.Context, game *domain.Game, recorded []*domain.PlayerStats) error {
+    moves, err := s.games.ListMovesByGame(ctx, game.ID)
+    if err != nil {
+        return err
+    }
+
+    players := []struct {
+        userID, opponentID uuid.UUID
+        symbol             string
+    }{{game.PlayerX, game.PlayerO, "X"}, {game.PlayerO, game.PlayerX, "O"}}
+    for _, p := range players {
+        var stats *domain.PlayerStats
+        for _, r := range recorded {
+            if r.UserID == p.userID {
+                stats = r
+            }
+        }
+        if stats == nil {
+            continue
+        }
+        opponent, err := s.users.GetUserByID(ctx, p.opponentID)
+        if err != nil && err != domain.ErrNotFound {
+            return err
+        }
+
+        event := &achievementEvent{game: game, userID: p.userID, symbol: p.symbol, opponent: opponent, stats: stats, moves: moves}
+        for _, rule := range achievementRules {
+            if !rule.unlocked(event) {
+                continue
+            }
+            gameID := game.ID
+            award := &domain.UserAchievement{UserID: p.userID, Code: rule.code, GameID: &gameID, UnlockedAt: time.Now().UTC()}
+            added, err := s.achievements.AwardAchievement(ctx, award)
+            if err != nil {
+                return err
+            }
+            if added && s.notifier != nil {
+                s.notifier.NotifyAchievement(p.userID, award)
+            }
+        }
+    }
+    return nil
+}
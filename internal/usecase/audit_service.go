@@ -0,0 +1,56 @@
+﻿package usecase
+
+import (
+    "context"
+    "log"
+    "time"
+
+    "github.com/google/uuid"
+    "xo-server/internal/domain"
+)
+
+const maxAuditList = 500
+
+type auditService struct {
+    events AuditRepository
+}
+
+func NewAuditService(events AuditRepository) AuditService {
+    return &auditService{events: events}
+}
+
+func (s *auditService) ListEvents(ctx context.Context, actor *domain.User, filter domain.AuditFilter) ([]*domain.AuditEvent, error) {
+    if err := domain.Authorize(actor, domain.PermAudit); err != nil {
+        return nil, err
+    }
+    if filter.Since != nil && filter.Until != nil && !filter.Until.After(*filter.Since) {
+        return nil, domain.ErrInvalidInput
+    }
+    if filter.Limit <= 0 || filter.Limit > maxAuditList {
+        filter.Limit = maxAuditList
+    }
+    return s.events.ListAuditEvents(ctx, filter)
+}
+
+func recordAudit(ctx context.Context, events AuditRepository, action domain.AuditAction, actorID, targetID uuid.UUID, detail string) {
+    if events == nil {
+        return
+    }
+
+    event := &domain.AuditEvent{
+        ID:        uuid.New(),
+        Action:    action,
+        IP:        ClientIPFromContext(ctx),
+        Detail:    detail,
+        CreatedAt: time.Now().UTC(),
+    }
+    if actorID != uuid.Nil {
+        event.ActorID = &actorID
+    }
+    if targetID != uuid.Nil {
+        event.TargetID = &targetID
+    }
+    if err := events.AppendAuditEvent(ctx, event); err != nil {
+        log.Printf("audit %s error: %v", action, err)
+    }
+}
@@ -2,6 +2,10 @@
 
 import (
     "context"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/hex"
     "strings"
     "time"
 
@@ -10,18 +14,66 @@ import (
     "xo-server/internal/domain"
 )
 
+type AuthOptions struct {
+    RefreshTTL       time.Duration
+    ResetTokenTTL    time.Duration
+    LockoutThreshold int
+    LockoutBase      time.Duration
+    LockoutMax       time.Duration
+    TOTPIssuer       string
+}
+
 type authService struct {
-    users  UserRepository
-    tokens TokenProvider
+    users      UserRepository
+    tokens     TokenProvider
+    refresh    RefreshTokenRepository
+    resets     PasswordResetRepository
+    twoFactor  TwoFactorRepository
+    audit      AuditRepository
+    notifier   PasswordResetNotifier
+    sessions   SessionCloser
+    opts       AuthOptions
+    throttle   *loginThrottle
+    challenges *challengeStore
 }
 
-func NewAuthService(users UserRepository, tokens TokenProvider) AuthService {
-    return &authService{users: users, tokens: tokens}
+func NewAuthService(users UserRepository, tokens TokenProvider, refresh RefreshTokenRepository, resets PasswordResetRepository, twoFactor TwoFactorRepository, audit AuditRepository, notifier PasswordResetNotifier, sessions SessionCloser, opts AuthOptions) AuthService {
+    if opts.RefreshTTL == 0 {
+        opts.RefreshTTL = 30 * 24 * time.Hour
+    }
+    if opts.ResetTokenTTL == 0 {
+        opts.ResetTokenTTL = 30 * time.Minute
+    }
+    if opts.LockoutThreshold == 0 {
+        opts.LockoutThreshold = 5
+    }
+    if opts.LockoutBase == 0 {
+        opts.LockoutBase = 30 * time.Second
+    }
+    if opts.LockoutMax == 0 {
+        opts.LockoutMax = 15 * time.Minute
+    }
+    if opts.TOTPIssuer == "" {
+        opts.TOTPIssuer = "XO Server"
+    }
+    return &authService{
+        users:      users,
+        tokens:     tokens,
+        refresh:    refresh,
+        resets:     resets,
+        twoFactor:  twoFactor,
+        audit:      audit,
+        notifier:   notifier,
+        sessions:   sessions,
+        opts:       opts,
+        throttle:   newLoginThrottle(opts.LockoutThreshold, opts.LockoutBase, opts.LockoutMax),
+        challenges: newChallengeStore(),
+    }
 }
 
 func (s *authService) Register(ctx context.Context, username, password string) (*domain.User, error) {
     username = strings.TrimSpace(username)
-    if username == "" || len(password) < 6 {
+    if !validCredentials(username, password) {
         return nil, domain.ErrInvalidInput
     }
 
@@ -39,6 +91,7 @@ func (s *authService) Register(ctx context.Context, username, password string) (
         ID:           uuid.New(),
         Username:     username,
         PasswordHash: string(hash),
+        Role:         domain.RolePlayer,
         CreatedAt:    time.Now().UTC(),
     }
 
@@ -46,28 +99,473 @@ func (s *authService) Register(ctx context.Context, username, password string) (
         return nil, err
     }
 
+    recordAudit(ctx, s.audit, domain.AuditRegister, user.ID, user.ID, "")
     return user, nil
 }
 
-func (s *authService) Login(ctx context.Context, username, password string) (string, *domain.User, error) {
+func (s *authService) Login(ctx context.Context, username, password string) (*domain.LoginResult, error) {
     username = strings.TrimSpace(username)
     if username == "" || password == "" {
-        return "", nil, domain.ErrInvalidInput
+        return nil, domain.ErrInvalidInput
+    }
+
+    now := time.Now().UTC()
+    keys := throttleKeys(ctx, username)
+    if err := s.throttle.check(now, keys...); err != nil {
+        recordAudit(ctx, s.audit, domain.AuditLoginFailed, uuid.Nil, uuid.Nil, "locked out: "+username)
+        return nil, err
     }
 
     user, err := s.users.GetUserByUsername(ctx, username)
     if err != nil {
-        return "", nil, domain.ErrUnauthorized
+        s.throttle.fail(now, keys...)
+        recordAudit(ctx, s.audit, domain.AuditLoginFailed, uuid.Nil, uuid.Nil, "unknown user: "+username)
+        return nil, domain.ErrUnauthorized
     }
 
     if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
-        return "", nil, domain.ErrUnauthorized
+        s.throttle.fail(now, keys...)
+        recordAudit(ctx, s.audit, domain.AuditLoginFailed, uuid.Nil, user.ID, "wrong password")
+        return nil, domain.ErrUnauthorized
+    }
+    if user.Suspended(now) {
+        recordAudit(ctx, s.audit, domain.AuditLoginFailed, uuid.Nil, user.ID, "account suspended")
+        return nil, domain.ErrAccountSuspended
+    }
+
+    enabled, err := s.twoFactorEnabled(ctx, user.ID)
+    if err != nil {
+        return nil, err
+    }
+    if enabled {
+        challenge, expiresAt, err := s.challenges.create(user.ID, user.Username, now)
+        if err != nil {
+            return nil, err
+        }
+        return &domain.LoginResult{User: user, ChallengeToken: challenge, ChallengeExpiresAt: expiresAt}, nil
+    }
+
+    s.throttle.reset(keys[0])
+    pair, err := s.issue(ctx, user, uuid.New())
+    if err != nil {
+        return nil, err
+    }
+
+    recordAudit(ctx, s.audit, domain.AuditLogin, user.ID, user.ID, "password")
+    return &domain.LoginResult{User: user, Tokens: pair}, nil
+}
+
+func (s *authService) CreateGuest(ctx context.Context) (*domain.TokenPair, *domain.User, error) {
+    var user *domain.User
+    for attempt := 0; attempt < 3; attempt++ {
+        suffix := make([]byte, 4)
+        if _, err := rand.Read(suffix); err != nil {
+            return nil, nil, err
+        }
+
+        candidate := &domain.User{
+            ID:        uuid.New(),
+            Username:  domain.GuestUsernamePrefix + hex.EncodeToString(suffix),
+            Role:      domain.RolePlayer,
+            IsGuest:   true,
+            CreatedAt: time.Now().UTC(),
+        }
+        if err := s.users.CreateUser(ctx, candidate); err == nil {
+            user = candidate
+            break
+        }
+    }
+    if user == nil {
+        return nil, nil, domain.ErrInvalidInput
+    }
+
+    pair, err := s.issue(ctx, user, uuid.New())
+    if err != nil {
+        return nil, nil, err
+    }
+    recordAudit(ctx, s.audit, domain.AuditRegister, user.ID, user.ID, "guest")
+    return pair, user, nil
+}
+
+func (s *authService) UpgradeGuest(ctx context.Context, userID, sessionID uuid.UUID, username, password string) (*domain.TokenPair, *domain.User, error) {
+    username = strings.TrimSpace(username)
+    if !validCredentials(username, password) {
+        return nil, nil, domain.ErrInvalidInput
+    }
+
+    user, err := s.users.GetUserByID(ctx, userID)
+    if err != nil {
+        return nil, nil, err
+    }
+    if !user.IsGuest {
+        return nil, nil, domain.ErrForbidden
+    }
+    if _, err := s.users.GetUserByUsername(ctx, username); err == nil {
+        return nil, nil, domain.ErrInvalidInput
+    }
+
+    hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+    if err != nil {
+        return nil, nil, err
+    }
+    if err := s.users.UpgradeGuest(ctx, userID, username, string(hash)); err != nil {
+        return nil, nil, err
+    }
+
+    user.Username = username
+    user.PasswordHash = string(hash)
+    user.IsGuest = false
+
+    if err := s.revokeUserSessions(ctx, userID, sessionID); err != nil {
+        return nil, nil, err
+    }
+    pair, err := s.issue(ctx, user, sessionID)
+    if err != nil {
+        return nil, nil, err
+    }
+    recordAudit(ctx, s.audit, domain.AuditRegister, user.ID, user.ID, "guest upgrade")
+    return pair, user, nil
+}
+
+func (s *authService) SetRole(ctx context.Context, actor *domain.User, userID uuid.UUID, role domain.Role) error {
+    if err := domain.Authorize(actor, domain.PermManageUsers); err != nil {
+        return err
+    }
+    if actor.ID == userID {
+        return domain.ErrForbidden
+    }
+    return s.applyRole(ctx, actor.ID, userID, role)
+}
+
+func (s *authService) GrantRole(ctx context.Context, username string, role domain.Role) error {
+    user, err := s.users.GetUserByUsername(ctx, username)
+    if err != nil {
+        return err
+    }
+    if user.Role == role {
+        return nil
+    }
+    return s.applyRole(ctx, uuid.Nil, user.ID, role)
+}
+
+func (s *authService) applyRole(ctx context.Context, actorID, userID uuid.UUID, role domain.Role) error {
+    if _, ok := domain.ParseRole(string(role)); !ok {
+        return domain.ErrInvalidInput
+    }
+
+    user, err := s.users.GetUserByID(ctx, userID)
+    if err != nil {
+        return err
+    }
+    if user.IsGuest && role != domain.RolePlayer {
+        return domain.ErrGuestNotAllowed
+    }
+
+    if err := s.users.UpdateRole(ctx, userID, role); err != nil {
+        return err
+    }
+    recordAudit(ctx, s.audit, domain.AuditRoleChanged, actorID, userID, string(user.Role)+" -> "+string(role))
+    return s.revokeUserSessions(ctx, userID, uuid.Nil)
+}
+
+func (s *authService) SuspendUser(ctx context.Context, actor *domain.User, userID uuid.UUID, until *time.Time, reason string) error {
+    if err := s.checkModeration(ctx, actor, userID); err != nil {
+        return err
+    }
+    reason = strings.TrimSpace(reason)
+    if reason == "" || (until != nil && !until.After(time.Now())) {
+        return domain.ErrInvalidInput
+    }
+
+    if err := s.users.UpdateSuspension(ctx, userID, until == nil, until, reason); err != nil {
+        return err
+    }
+    if until == nil {
+        recordAudit(ctx, s.audit, domain.AuditUserBanned, actor.ID, userID, reason)
+    } else {
+        recordAudit(ctx, s.audit, domain.AuditUserSuspended, actor.ID, userID, reason+" (until "+until.UTC().Format(time.RFC3339)+")")
+    }
+    if s.sessions != nil {
+        s.sessions.CloseUser(userID, reason)
+    }
+    return s.revokeUserSessions(ctx, userID, uuid.Nil)
+}
+
+func (s *authService) LiftSuspension(ctx context.Context, actor *domain.User, userID uuid.UUID) error {
+    if err := s.checkModeration(ctx, actor, userID); err != nil {
+        return err
+    }
+    if err := s.users.UpdateSuspension(ctx, userID, false, nil, ""); err != nil {
+        return err
+    }
+    recordAudit(ctx, s.audit, domain.AuditSuspensionLifted, actor.ID, userID, "")
+    return nil
+}
+
+func (s *authService) checkModeration(ctx context.Context, actor *domain.User, userID uuid.UUID) error {
+    if err := domain.Authorize(actor, domain.PermManageUsers); err != nil {
+        return err
+    }
+    if actor.ID == userID {
+        return domain.ErrForbidden
+    }
+
+    target, err := s.users.GetUserByID(ctx, userID)
+    if err != nil {
+        return err
+    }
+    if target.Role.Can(domain.PermManageUsers) {
+        return domain.ErrForbidden
+    }
+    return nil
+}
+
+func (s *authService) Refresh(ctx context.Context, refreshToken string) (*domain.TokenPair, *domain.User, error) {
+    if refreshToken == "" {
+        return nil, nil, domain.ErrUnauthorized
+    }
+
+    current, err := s.refresh.GetRefreshTokenByHash(ctx, hashToken(refreshToken))
+    if err != nil {
+        return nil, nil, domain.ErrUnauthorized
+    }
+
+    now := time.Now().UTC()
+    if current.RevokedAt != nil || now.After(current.ExpiresAt) {
+        return nil, nil, domain.ErrUnauthorized
     }
 
-    token, err := s.tokens.IssueToken(user)
+    rotated := false
+    if current.RotatedAt == nil {
+        rotated, err = s.refresh.MarkRefreshTokenRotated(ctx, current.ID, now)
+        if err != nil {
+            return nil, nil, err
+        }
+    }
+    if !rotated {
+        if err := s.revokeSession(ctx, current.FamilyID); err != nil {
+            return nil, nil, err
+        }
+        recordAudit(ctx, s.audit, domain.AuditTokenReuse, uuid.Nil, current.UserID, "session "+current.FamilyID.String())
+        return nil, nil, domain.ErrUnauthorized
+    }
+
+    user, err := s.users.GetUserByID(ctx, current.UserID)
+    if err != nil {
+        return nil, nil, domain.ErrUnauthorized
+    }
+
+    pair, err := s.issue(ctx, user, current.FamilyID)
+    if err != nil {
+        return nil, nil, err
+    }
+
+    return pair, user, nil
+}
+
+func (s *authService) StartSession(ctx context.Context, user *domain.User) (*domain.TokenPair, error) {
+    pair, err := s.issue(ctx, user, uuid.New())
+    if err != nil {
+        return nil, err
+    }
+    recordAudit(ctx, s.audit, domain.AuditLogin, user.ID, user.ID, "sso")
+    return pair, nil
+}
+
+func (s *authService) Logout(ctx context.Context, userID, sessionID uuid.UUID) error {
+    if sessionID == uuid.Nil {
+        return domain.ErrUnauthorized
+    }
+    if err := s.revokeSession(ctx, sessionID); err != nil {
+        return err
+    }
+    recordAudit(ctx, s.audit, domain.AuditLogout, userID, userID, "session "+sessionID.String())
+    return nil
+}
+
+func (s *authService) ChangePassword(ctx context.Context, userID, sessionID uuid.UUID, currentPassword, newPassword string) error {
+    if len(newPassword) < 6 {
+        return domain.ErrInvalidInput
+    }
+
+    user, err := s.users.GetUserByID(ctx, userID)
+    if err != nil {
+        return err
+    }
+    if user.PasswordHash == "" {
+        return domain.ErrForbidden
+    }
+    if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(currentPassword)); err != nil {
+        return domain.ErrUnauthorized
+    }
+
+    if err := s.setPassword(ctx, user.ID, newPassword); err != nil {
+        return err
+    }
+    recordAudit(ctx, s.audit, domain.AuditPasswordChanged, user.ID, user.ID, "")
+    return s.revokeUserSessions(ctx, user.ID, sessionID)
+}
+
+func (s *authService) RequestPasswordReset(ctx context.Context, username string) error {
+    username = strings.TrimSpace(username)
+    if username == "" {
+        return domain.ErrInvalidInput
+    }
+
+    user, err := s.users.GetUserByUsername(ctx, username)
+    if err != nil || user.IsGuest {
+        return nil
+    }
+
+    token, err := randomToken()
     if err != nil {
-        return "", nil, err
+        return err
     }
 
-    return token, user, nil
+    now := time.Now().UTC()
+    if err := s.resets.InvalidateResetTokens(ctx, user.ID, now); err != nil {
+        return err
+    }
+    record := &domain.PasswordResetToken{
+        ID:        uuid.New(),
+        UserID:    user.ID,
+        TokenHash: hashToken(token),
+        ExpiresAt: now.Add(s.opts.ResetTokenTTL),
+        CreatedAt: now,
+    }
+    if err := s.resets.CreateResetToken(ctx, record); err != nil {
+        return err
+    }
+
+    return s.notifier.SendPasswordReset(ctx, user, token, record.ExpiresAt)
+}
+
+func (s *authService) ResetPassword(ctx context.Context, token, newPassword string) error {
+    if token == "" || len(newPassword) < 6 {
+        return domain.ErrInvalidInput
+    }
+
+    record, err := s.resets.GetResetTokenByHash(ctx, hashToken(token))
+    if err != nil {
+        return domain.ErrUnauthorized
+    }
+
+    now := time.Now().UTC()
+    if record.UsedAt != nil || now.After(record.ExpiresAt) {
+        return domain.ErrUnauthorized
+    }
+    used, err := s.resets.MarkResetTokenUsed(ctx, record.ID, now)
+    if err != nil {
+        return err
+    }
+    if !used {
+        return domain.ErrUnauthorized
+    }
+
+    if err := s.setPassword(ctx, record.UserID, newPassword); err != nil {
+        return err
+    }
+    recordAudit(ctx, s.audit, domain.AuditPasswordReset, record.UserID, record.UserID, "")
+    if user, err := s.users.GetUserByID(ctx, record.UserID); err == nil {
+        s.throttle.reset("user:" + strings.ToLower(user.Username))
+    }
+    return s.revokeUserSessions(ctx, record.UserID, uuid.Nil)
+}
+
+func throttleKeys(ctx context.Context, username string) []string {
+    keys := []string{"user:" + strings.ToLower(username)}
+    if ip := ClientIPFromContext(ctx); ip != "" {
+        keys = append(keys, "ip:"+ip)
+    }
+    return keys
+}
+
+func validCredentials(username, password string) bool {
+    if username == "" || len(password) < 6 {
+        return false
+    }
+    lower := strings.ToLower(username)
+    return !strings.HasPrefix(lower, domain.GuestUsernamePrefix) && !strings.HasPrefix(lower, domain.DeletedUsernamePrefix)
+}
+
+func (s *authService) setPassword(ctx context.Context, userID uuid.UUID, password string) error {
+    hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+    if err != nil {
+        return err
+    }
+    return s.users.UpdatePassword(ctx, userID, string(hash))
+}
+
+func (s *authService) revokeUserSessions(ctx context.Context, userID, except uuid.UUID) error {
+    return revokeUserSessions(ctx, s.refresh, s.sessions, userID, except)
+}
+
+func revokeUserSessions(ctx context.Context, refresh RefreshTokenRepository, sessions SessionCloser, userID, except uuid.UUID) error {
+    revoked, err := refresh.RevokeUserTokenFamilies(ctx, userID, except, time.Now().UTC())
+    if err != nil {
+        return err
+    }
+    if sessions != nil {
+        for _, sessionID := range revoked {
+            sessions.CloseSession(sessionID)
+        }
+    }
+    return nil
+}
+
+func (s *authService) revokeSession(ctx context.Context, sessionID uuid.UUID) error {
+    if err := s.refresh.RevokeTokenFamily(ctx, sessionID, time.Now().UTC()); err != nil {
+        return err
+    }
+    if s.sessions != nil {
+        s.sessions.CloseSession(sessionID)
+    }
+    return nil
+}
+
+func (s *authService) issue(ctx context.Context, user *domain.User, sessionID uuid.UUID) (*domain.TokenPair, error) {
+    if user.DeletedAt != nil {
+        return nil, domain.ErrUnauthorized
+    }
+    if user.Suspended(time.Now()) {
+        return nil, domain.ErrAccountSuspended
+    }
+
+    refreshToken, err := randomToken()
+    if err != nil {
+        return nil, err
+    }
+
+    now := time.Now().UTC()
+    record := &domain.RefreshToken{
+        ID:        uuid.New(),
+        FamilyID:  sessionID,
+        UserID:    user.ID,
+        TokenHash: hashToken(refreshToken),
+        ExpiresAt: now.Add(s.opts.RefreshTTL),
+        CreatedAt: now,
+    }
+    if err := s.refresh.CreateRefreshToken(ctx, record); err != nil {
+        return nil, err
+    }
+
+    accessToken, err := s.tokens.IssueToken(user, sessionID)
+    if err != nil {
+        return nil, err
+    }
+
+    return &domain.TokenPair{AccessToken: accessToken, RefreshToken: refreshToken, SessionID: sessionID}, nil
+}
+
+func hashToken(token string) string {
+    sum := sha256.Sum256([]byte(token))
+    return hex.EncodeToString(sum[:])
+}
+
+func randomToken() (string, error) {
+    raw := make([]byte, 32)
+    if _, err := rand.Read(raw); err != nil {
+        return "", err
+    }
+    return base64.RawURLEncoding.EncodeToString(raw), nil
 }
@@ -0,0 +1,231 @@
+﻿package usecase
+
+import (
+    "context"
+    "crypto/sha256"
+    "encoding/base64"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/google/uuid"
+    "xo-server/internal/domain"
+)
+
+const (
+    loginStateTTL       = 10 * time.Minute
+    maxUsernameAttempts = 100
+)
+
+type loginState struct {
+    provider     string
+    nonce        string
+    codeVerifier string
+    linkUserID   uuid.UUID
+    expiresAt    time.Time
+}
+
+type externalLoginService struct {
+    providers  map[string]ExternalIdentityProvider
+    identities IdentityRepository
+    users      UserRepository
+    auth       AuthService
+
+    mu     sync.Mutex
+    states map[string]loginState
+}
+
+func NewExternalLoginService(providers []ExternalIdentityProvider, identities IdentityRepository, users UserRepository, auth AuthService) ExternalLoginService {
+    byName := make(map[string]ExternalIdentityProvider, len(providers))
+    for _, p := range providers {
+        byName[p.Name()] = p
+    }
+    return &externalLoginService{
+        providers:  byName,
+        identities: identities,
+        users:      users,
+        auth:       auth,
+        states:     make(map[string]loginState),
+    }
+}
+
+func (s *externalLoginService) Providers() []string {
+    names := make([]string, 0, len(s.providers))
+    for name := range s.providers {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+func (s *externalLoginService) Begin(ctx context.Context, provider string, linkUserID uuid.UUID) (string, error) {
+    p, ok := s.providers[provider]
+    if !ok {
+        return "", domain.ErrNotFound
+    }
+
+    state, err := randomToken()
+    if err != nil {
+        return "", err
+    }
+    nonce, err := randomToken()
+    if err != nil {
+        return "", err
+    }
+    verifier, err := randomToken()
+    if err != nil {
+        return "", err
+    }
+
+    sum := sha256.Sum256([]byte(verifier))
+    challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+
+    url, err := p.AuthCodeURL(ctx, state, nonce, challenge)
+    if err != nil {
+        return "", err
+    }
+
+    now := time.Now().UTC()
+    s.mu.Lock()
+    for key, st := range s.states {
+        if now.After(st.expiresAt) {
+            delete(s.states, key)
+        }
+    }
+    s.states[state] = loginState{
+        provider:     provider,
+        nonce:        nonce,
+        codeVerifier: verifier,
+        linkUserID:   linkUserID,
+        expiresAt:    now.Add(loginStateTTL),
+    }
+    s.mu.Unlock()
+
+    return url, nil
+}
+
+func (s *externalLoginService) Complete(ctx context.Context, provider, state, code string) (*domain.TokenPair, *domain.User, error) {
+    if state == "" || code == "" {
+        return nil, nil, domain.ErrInvalidInput
+    }
+
+    s.mu.Lock()
+    st, ok := s.states[state]
+    delete(s.states, state)
+    s.mu.Unlock()
+    if !ok || st.provider != provider || time.Now().UTC().After(st.expiresAt) {
+        return nil, nil, domain.ErrUnauthorized
+    }
+
+    p, ok := s.providers[provider]
+    if !ok {
+        return nil, nil, domain.ErrNotFound
+    }
+
+    claims, err := p.Exchange(ctx, code, st.codeVerifier, st.nonce)
+    if err != nil {
+        return nil, nil, domain.ErrUnauthorized
+    }
+    if claims.Subject == "" {
+        return nil, nil, domain.ErrUnauthorized
+    }
+
+    user, err := s.resolveUser(ctx, provider, claims, st.linkUserID)
+    if err != nil {
+        return nil, nil, err
+    }
+
+    pair, err := s.auth.StartSession(ctx, user)
+    if err != nil {
+        return nil, nil, err
+    }
+    return pair, user, nil
+}
+
+func (s *externalLoginService) ListIdentities(ctx context.Context, userID uuid.UUID) ([]*domain.ExternalIdentity, error) {
+    return s.identities.ListIdentitiesByUser(ctx, userID)
+}
+
+func (s *externalLoginService) resolveUser(ctx context.Context, provider string, claims *domain.ExternalClaims, linkUserID uuid.UUID) (*domain.User, error) {
+    identity, err := s.identities.GetIdentity(ctx, provider, claims.Subject)
+    if err == nil {
+        if linkUserID != uuid.Nil && identity.UserID != linkUserID {
+            return nil, domain.ErrIdentityLinked
+        }
+        return s.users.GetUserByID(ctx, identity.UserID)
+    }
+    if err != domain.ErrNotFound {
+        return nil, err
+    }
+
+    var user *domain.User
+    if linkUserID != uuid.Nil {
+        user, err = s.users.GetUserByID(ctx, linkUserID)
+        if err != nil {
+            return nil, err
+        }
+    } else {
+        user, err = s.provisionUser(ctx, claims)
+        if err != nil {
+            return nil, err
+        }
+    }
+
+    identity = &domain.ExternalIdentity{
+        Provider:  provider,
+        Subject:   claims.Subject,
+        UserID:    user.ID,
+        Email:     claims.Email,
+        CreatedAt: time.Now().UTC(),
+    }
+    if err := s.identities.CreateIdentity(ctx, identity); err != nil {
+        return nil, err
+    }
+    return user, nil
+}
+
+func (s *externalLoginService) provisionUser(ctx context.Context, claims *domain.ExternalClaims) (*domain.User, error) {
+    base := usernameFromClaims(claims)
+    for i := 1; i <= maxUsernameAttempts; i++ {
+        username := base
+        if i > 1 {
+            username = base + strconv.Itoa(i)
+        }
+        if _, err := s.users.GetUserByUsername(ctx, username); err == nil {
+            continue
+        }
+
+        user := &domain.User{
+            ID:        uuid.New(),
+            Username:  username,
+            Role:      domain.RolePlayer,
+            CreatedAt: time.Now().UTC(),
+        }
+        if err := s.users.CreateUser(ctx, user); err != nil {
+            return nil, err
+        }
+        return user, nil
+    }
+    return nil, domain.ErrInvalidInput
+}
+
+func usernameFromClaims(claims *domain.ExternalClaims) string {
+    candidates := []string{claims.PreferredUsername, claims.Email, claims.Name}
+    for _, c := range candidates {
+        if at := strings.IndexByte(c, '@'); at > 0 {
+            c = c[:at]
+        }
+        var b strings.Builder
+        for _, r := range strings.ToLower(c) {
+            if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' || r == '-' || r == '.' {
+                b.WriteRune(r)
+            }
+        }
+        if b.Len() > 0 {
+            return b.String()
+        }
+    }
+    return "player"
+}
@@ -0,0 +1,202 @@
+﻿package usecase
+
+import (
+    "context"
+    "strings"
+    "time"
+
+    "github.com/google/uuid"
+    "xo-server/internal/domain"
+)
+
+type friendService struct {
+    friends  FriendRepository
+    users    UserRepository
+    games    GameRepository
+    presence PresenceTracker
+}
+
+func NewFriendService(friends FriendRepository, users UserRepository, games GameRepository, presence PresenceTracker) FriendService {
+    return &friendService{friends: friends, users: users, games: games, presence: presence}
+}
+
+func (s *friendService) SendRequest(ctx context.Context, userID uuid.UUID, username string) (*domain.Friendship, error) {
+    username = strings.TrimSpace(username)
+    if username == "" {
+        return nil, domain.ErrInvalidInput
+    }
+
+    target, err := s.users.GetUserByUsername(ctx, username)
+    if err != nil {
+        return nil, err
+    }
+    if target.ID == userID {
+        return nil, domain.ErrInvalidInput
+    }
+
+    existing, err := s.friends.GetFriendship(ctx, userID, target.ID)
+    if err == nil {
+        if existing.Status == domain.FriendshipPending && existing.AddresseeID == userID {
+            return s.accept(ctx, existing)
+        }
+        return nil, domain.ErrInvalidInput
+    }
+    if err != domain.ErrNotFound {
+        return nil, err
+    }
+
+    now := time.Now().UTC()
+    f := &domain.Friendship{
+        RequesterID: userID,
+        AddresseeID: target.ID,
+        Status:      domain.FriendshipPending,
+        CreatedAt:   now,
+        UpdatedAt:   now,
+    }
+    if err := s.friends.CreateFriendship(ctx, f); err != nil {
+        return nil, err
+    }
+
+    return f, nil
+}
+
+func (s *friendService) AcceptRequest(ctx context.Context, userID, requesterID uuid.UUID) (*domain.Friendship, error) {
+    f, err := s.friends.GetFriendship(ctx, userID, requesterID)
+    if err != nil {
+        return nil, err
+    }
+    if f.Status != domain.FriendshipPending || f.AddresseeID != userID {
+        return nil, domain.ErrNotFound
+    }
+    return s.accept(ctx, f)
+}
+
+func (s *friendService) accept(ctx context.Context, f *domain.Friendship) (*domain.Friendship, error) {
+    f.Status = domain.FriendshipAccepted
+    f.UpdatedAt = time.Now().UTC()
+    if err := s.friends.UpdateFriendship(ctx, f); err != nil {
+        return nil, err
+    }
+    return f, nil
+}
+
+func (s *friendService) DeclineRequest(ctx context.Context, userID, requesterID uuid.UUID) error {
+    f, err := s.friends.GetFriendship(ctx, userID, requesterID)
+    if err != nil {
+        return err
+    }
+    if f.Status != domain.FriendshipPending {
+        return domain.ErrNotFound
+    }
+    return s.friends.DeleteFriendship(ctx, userID, requesterID)
+}
+
+func (s *friendService) RemoveFriend(ctx context.Context, userID, friendID uuid.UUID) error {
+    f, err := s.friends.GetFriendship(ctx, userID, friendID)
+    if err != nil {
+        return err
+    }
+    if f.Status != domain.FriendshipAccepted {
+        return domain.ErrNotFriends
+    }
+    return s.friends.DeleteFriendship(ctx, userID, friendID)
+}
+
+func (s *friendService) ListFriends(ctx context.Context, userID uuid.UUID) ([]*domain.Friend, error) {
+    list, err := s.friends.ListFriendshipsByUser(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+
+    out := make([]*domain.Friend, 0, len(list))
+    for _, f := range list {
+        if f.Status != domain.FriendshipAccepted {
+            continue
+        }
+        otherID := f.Other(userID)
+        other, err := s.users.GetUserByID(ctx, otherID)
+        if err != nil {
+            return nil, err
+        }
+        out = append(out, &domain.Friend{
+            UserID:   otherID,
+            Username: other.Username,
+            Since:    f.UpdatedAt,
+            Online:   s.presence != nil && s.presence.IsOnline(otherID),
+        })
+    }
+    return out, nil
+}
+
+func (s *friendService) ListRequests(ctx context.Context, userID uuid.UUID) ([]*domain.FriendRequest, error) {
+    list, err := s.friends.ListFriendshipsByUser(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+
+    out := make([]*domain.FriendRequest, 0)
+    for _, f := range list {
+        if f.Status != domain.FriendshipPending {
+            continue
+        }
+        otherID := f.Other(userID)
+        other, err := s.users.GetUserByID(ctx, otherID)
+        if err != nil {
+            return nil, err
+        }
+        out = append(out, &domain.FriendRequest{
+            UserID:    otherID,
+            Username:  other.Username,
+            Incoming:  f.AddresseeID == userID,
+            CreatedAt: f.CreatedAt,
+        })
+    }
+    return out, nil
+}
+
+func (s *friendService) FriendIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+    list, err := s.friends.ListFriendshipsByUser(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+
+    out := make([]uuid.UUID, 0, len(list))
+    for _, f := range list {
+        if f.Status == domain.FriendshipAccepted {
+            out = append(out, f.Other(userID))
+        }
+    }
+    return out, nil
+}
+
+func (s *friendService) Challenge(ctx context.Context, userID, friendID uuid.UUID) (*domain.Game, error) {
+    f, err := s.friends.GetFriendship(ctx, userID, friendID)
+    if err != nil {
+        if err == domain.ErrNotFound {
+            return nil, domain.ErrNotFriends
+        }
+        return nil, err
+    }
+    if f.Status != domain.FriendshipAccepted {
+        return nil, domain.ErrNotFriends
+    }
+
+    game := &domain.Game{
+        ID:          uuid.New(),
+        PlayerX:     userID,
+        PlayerO:     friendID,
+        Board:       domain.NewEmptyBoard(),
+        NextTurn:    "X",
+        Status:      domain.GameInProgress,
+        Variant:     domain.VariantStandard,
+        TimeControl: domain.TimeControlUntimed,
+        CreatedAt:   time.Now().UTC(),
+        UpdatedAt:   time.Now().UTC(),
+    }
+
+    if err := s.games.CreateGame(ctx, game); err != nil {
+        return nil, err
+    }
+
+    return game, nil
+}
@@ -0,0 +1,110 @@
+﻿package usecase
+
+import (
+    "context"
+    "strings"
+    "time"
+
+    "github.com/google/uuid"
+    "xo-server/internal/domain"
+)
+
+const maxLiveGames = 500
+
+func (s *gameService) ListLiveGames(ctx context.Context, actor *domain.User, limit int) ([]*domain.Game, error) {
+    if err := domain.Authorize(actor, domain.PermModerate); err != nil {
+        return nil, err
+    }
+    if limit <= 0 || limit > maxLiveGames {
+        limit = maxLiveGames
+    }
+    return s.games.ListActiveGames(ctx, limit)
+}
+
+func (s *gameService) ForceFinish(ctx context.Context, actor *domain.User, gameID uuid.UUID, winnerID *uuid.UUID, reason string) (*domain.Game, error) {
+    return s.adminUpdate(ctx, actor, gameID, domain.AuditGameFinished, reason, func(game *domain.Game) error {
+        if !isActive(game) {
+            return domain.ErrGameNotActive
+        }
+        if err := checkWinnerID(game, winnerID); err != nil {
+            return err
+        }
+        game.Status = domain.GameFinished
+        game.WinnerUserID = winnerID
+        return nil
+    })
+}
+
+func (s *gameService) AbortGame(ctx context.Context, actor *domain.User, gameID uuid.UUID, reason string) (*domain.Game, error) {
+    return s.adminUpdate(ctx, actor, gameID, domain.AuditGameAborted, reason, func(game *domain.Game) error {
+        if !isActive(game) {
+            return domain.ErrGameNotActive
+        }
+        game.Status = domain.GameAborted
+        game.WinnerUserID = nil
+        return nil
+    })
+}
+
+func (s *gameService) AdjustResult(ctx context.Context, actor *domain.User, gameID uuid.UUID, winnerID *uuid.UUID, reason string) (*domain.Game, error) {
+    return s.adminUpdate(ctx, actor, gameID, domain.AuditResultAdjusted, reason, func(game *domain.Game) error {
+        if game.Status != domain.GameFinished && game.Status != domain.GameAborted {
+            return domain.ErrGameNotActive
+        }
+        if err := checkWinnerID(game, winnerID); err != nil {
+            return err
+        }
+        game.Status = domain.GameFinished
+        game.WinnerUserID = winnerID
+        return nil
+    })
+}
+
+func (s *gameService) adminUpdate(ctx context.Context, actor *domain.User, gameID uuid.UUID, action domain.AuditAction, reason string, apply func(game *domain.Game) error) (*domain.Game, error) {
+    if err := domain.Authorize(actor, domain.PermManageGames); err != nil {
+        return nil, err
+    }
+    reason = strings.TrimSpace(reason)
+    if reason == "" {
+        return nil, domain.ErrInvalidInput
+    }
+
+    lock := s.getLock(gameID)
+    lock.Lock()
+    defer lock.Unlock()
+
+    game, err := s.games.GetGameByID(ctx, gameID)
+    if err != nil {
+        return nil, err
+    }
+    var previous *domain.Game
+    if !isActive(game) {
+        before := *game
+        previous = &before
+    }
+    if err := apply(game); err != nil {
+        return nil, err
+    }
+
+    game.DrawOfferedBy = nil
+    game.EndReason = reason
+    game.UpdatedAt = time.Now().UTC()
+    if err := s.games.UpdateGame(ctx, game); err != nil {
+        return nil, err
+    }
+    recordAudit(ctx, s.audit, action, actor.ID, gameID, reason)
+    s.publish(ctx, game, domain.GameEventUpdate, nil)
+    s.ended(ctx, game, previous)
+    return game, nil
+}
+
+func isActive(game *domain.Game) bool {
+    return game.Status == domain.GameInProgress || game.Status == domain.GameDrawOffer || game.Status == domain.GameWaiting
+}
+
+func checkWinnerID(game *domain.Game, winnerID *uuid.UUID) error {
+    if winnerID != nil && *winnerID != game.PlayerX && *winnerID != game.PlayerO {
+        return domain.ErrInvalidInput
+    }
+    return nil
+}
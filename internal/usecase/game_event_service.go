@@ -0,0 +1,37 @@
+﻿package usecase
+
+import (
+    "context"
+
+    "github.com/google/uuid"
+    "xo-server/internal/domain"
+)
+
+const maxReplayEvents = 500
+
+type gameEventService struct {
+    events GameEventRepository
+    games  GameRepository
+}
+
+func NewGameEventService(events GameEventRepository, games GameRepository) GameEventService {
+    return &gameEventService{events: events, games: games}
+}
+
+func (s *gameEventService) Replay(ctx context.Context, userID, gameID uuid.UUID, afterSeq int64) ([]*domain.GameEvent, error) {
+    game, err := s.games.GetGameByID(ctx, gameID)
+    if err != nil {
+        return nil, err
+    }
+    if userID != game.PlayerX && userID != game.PlayerO {
+        return nil, domain.ErrForbidden
+    }
+    if afterSeq < 0 {
+        return nil, domain.ErrInvalidInput
+    }
+    return s.events.ListGameEventsAfter(ctx, gameID, afterSeq, maxReplayEvents)
+}
+
+func (s *gameEventService) LastSeq(ctx context.Context, gameID uuid.UUID) (int64, error) {
+    return s.events.LastGameEventSeq(ctx, gameID)
+}
@@ -2,6 +2,8 @@
 
 import (
     "context"
+    "log"
+    "strings"
     "sync"
     "time"
 
@@ -10,13 +12,17 @@ import (
 )
 
 type gameService struct {
-    games GameRepository
-    mu    sync.Mutex
-    locks map[uuid.UUID]*sync.Mutex
+    games     GameRepository
+    events    GameEventRepository
+    publisher GameEventPublisher
+    audit     AuditRepository
+    listeners []GameEndListener
+    mu        sync.Mutex
+    locks     map[uuid.UUID]*sync.Mutex
 }
 
-func NewGameService(games GameRepository) GameService {
-    return &gameService{games: games, locks: make(map[uuid.UUID]*sync.Mutex)}
+func NewGameService(games GameRepository, events GameEventRepository, publisher GameEventPublisher, audit AuditRepository, listeners ...GameEndListener) GameService {
+    return &gameService{games: games, events: events, publisher: publisher, audit: audit, listeners: listeners, locks: make(map[uuid.UUID]*sync.Mutex)}
 }
 
 func (s *gameService) MakeMove(ctx context.Context, userID, gameID uuid.UUID, position int) (*domain.Game, error) {
@@ -92,6 +98,10 @@ func (s *gameService) MakeMove(ctx context.Context, userID, gameID uuid.UUID, po
         return nil, err
     }
 
+    s.publish(ctx, game, domain.GameEventUpdate, nil)
+    if game.Status == domain.GameFinished {
+        s.ended(ctx, game, nil)
+    }
     return game, nil
 }
 
@@ -126,6 +136,8 @@ func (s *gameService) Resign(ctx context.Context, userID, gameID uuid.UUID) (*do
         return nil, err
     }
 
+    s.publish(ctx, game, domain.GameEventUpdate, nil)
+    s.ended(ctx, game, nil)
     return game, nil
 }
 
@@ -155,6 +167,7 @@ func (s *gameService) OfferDraw(ctx context.Context, userID, gameID uuid.UUID) (
         return nil, err
     }
 
+    s.publish(ctx, game, domain.GameEventUpdate, nil)
     return game, nil
 }
 
@@ -185,6 +198,8 @@ func (s *gameService) AcceptDraw(ctx context.Context, userID, gameID uuid.UUID)
         return nil, err
     }
 
+    s.publish(ctx, game, domain.GameEventUpdate, nil)
+    s.ended(ctx, game, nil)
     return game, nil
 }
 
@@ -214,17 +229,39 @@ func (s *gameService) DeclineDraw(ctx context.Context, userID, gameID uuid.UUID)
         return nil, err
     }
 
+    s.publish(ctx, game, domain.GameEventUpdate, nil)
     return game, nil
 }
 
-func (s *gameService) AddChat(ctx context.Context, userID, gameID uuid.UUID, message string) error {
+func (s *gameService) AddChat(ctx context.Context, userID, gameID uuid.UUID, message string) (*domain.GameMessage, error) {
+    message = strings.TrimSpace(message)
+    if message == "" {
+        return nil, domain.ErrInvalidInput
+    }
+
+    lock := s.getLock(gameID)
+    lock.Lock()
+    defer lock.Unlock()
+
+    game, err := s.games.GetGameByID(ctx, gameID)
+    if err != nil {
+        return nil, err
+    }
+    if userID != game.PlayerX && userID != game.PlayerO {
+        return nil, domain.ErrForbidden
+    }
+
     msg := &domain.GameMessage{
         GameID:    gameID,
         UserID:    userID,
         Message:   message,
         CreatedAt: time.Now().UTC(),
     }
-    return s.games.AddMessage(ctx, msg)
+    if err := s.games.AddMessage(ctx, msg); err != nil {
+        return nil, err
+    }
+    s.publish(ctx, game, domain.GameEventChat, msg)
+    return msg, nil
 }
 
 func (s *gameService) GetGame(ctx context.Context, gameID uuid.UUID) (*domain.Game, error) {
@@ -235,6 +272,44 @@ func (s *gameService) GetActiveGames(ctx context.Context, userID uuid.UUID) ([]*
     return s.games.ListActiveGamesByUser(ctx, userID)
 }
 
+func (s *gameService) AnnounceGame(ctx context.Context, gameID uuid.UUID) (*domain.Game, error) {
+    lock := s.getLock(gameID)
+    lock.Lock()
+    defer lock.Unlock()
+
+    game, err := s.games.GetGameByID(ctx, gameID)
+    if err != nil {
+        return nil, err
+    }
+    s.publish(ctx, game, domain.GameEventFound, nil)
+    return game, nil
+}
+
+func (s *gameService) publish(ctx context.Context, game *domain.Game, eventType string, msg *domain.GameMessage) {
+    if s.publisher == nil {
+        return
+    }
+    event := &domain.GameEvent{
+        GameID:    game.ID,
+        Type:      eventType,
+        Payload:   s.publisher.EncodeGameEvent(eventType, game, msg),
+        CreatedAt: time.Now().UTC(),
+    }
+    if s.events != nil {
+        if err := s.events.AppendGameEvent(ctx, event); err != nil {
+            log.Printf("game event append error: %v", err)
+            return
+        }
+    }
+    s.publisher.PublishGameEvent(game, event)
+}
+
+func (s *gameService) ended(ctx context.Context, game, previous *domain.Game) {
+    for _, l := range s.listeners {
+        l.GameEnded(ctx, game, previous)
+    }
+}
+
 func (s *gameService) getLock(gameID uuid.UUID) *sync.Mutex {
     s.mu.Lock()
     defer s.mu.Unlock()
@@ -0,0 +1,354 @@
+﻿package usecase
+
+import (
+    "context"
+    "sort"
+    "sync"
+    "time"
+
+    "github.com/google/uuid"
+    "xo-server/internal/domain"
+)
+
+const (
+    defaultLeaderboardLimit = 50
+    maxLeaderboardLimit     = 100
+)
+
+type LeaderboardOptions struct {
+    MinGamesAllTime int
+    MinGamesMonthly int
+    MinGamesWeekly  int
+    MinGamesSeason  int
+}
+
+type leaderboardKey struct {
+    variant     string
+    timeControl string
+    period      domain.LeaderboardPeriod
+    since       time.Time
+}
+
+func (k leaderboardKey) counts(game *domain.Game) bool {
+    if game == nil || !game.Rated {
+        return false
+    }
+    variant, timeControl := game.RatingPool()
+    return k.variant == variant && k.timeControl == timeControl && !game.UpdatedAt.Before(k.since)
+}
+
+type cachedLeaderboard struct {
+    board    *domain.Leaderboard
+    stale    bool
+    building chan struct{}
+    err      error
+}
+
+type leaderboardService struct {
+    stats   StatsRepository
+    users   UserRepository
+    games   GameRepository
+    seasons SeasonRepository
+    opts    LeaderboardOptions
+    mu      sync.Mutex
+    cache   map[leaderboardKey]*cachedLeaderboard
+}
+
+func NewLeaderboardService(stats StatsRepository, users UserRepository, games GameRepository, seasons SeasonRepository, opts LeaderboardOptions) LeaderboardService {
+    return &leaderboardService{stats: stats, users: users, games: games, seasons: seasons, opts: opts, cache: make(map[leaderboardKey]*cachedLeaderboard)}
+}
+
+func (s *leaderboardService) GameEnded(ctx context.Context, game, previous *domain.Game) {
+    if !game.Rated && (previous == nil || !previous.Rated) {
+        return
+    }
+    now := time.Now()
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    for key, c := range s.cache {
+        if start := key.period.Start(now); start != nil && !start.Equal(key.since) {
+            delete(s.cache, key)
+            continue
+        }
+        if key.counts(game) || key.counts(previous) {
+            c.stale = true
+        }
+    }
+}
+
+func (s *leaderboardService) Refresh() {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.cache = make(map[leaderboardKey]*cachedLeaderboard)
+}
+
+func (s *leaderboardService) Leaderboard(ctx context.Context, query domain.LeaderboardQuery) (*domain.Leaderboard, error) {
+    if query.Offset < 0 {
+        return nil, domain.ErrInvalidInput
+    }
+    if query.Limit <= 0 {
+        query.Limit = defaultLeaderboardLimit
+    }
+    if query.Limit > maxLeaderboardLimit {
+        query.Limit = maxLeaderboardLimit
+    }
+
+    key, since, minGames, err := s.resolve(ctx, query)
+    if err != nil {
+        return nil, err
+    }
+    board, err := s.cached(ctx, key, since, minGames)
+    if err != nil {
+        return nil, err
+    }
+
+    page := *board
+    page.Entries = nil
+    if query.Offset < len(board.Entries) {
+        end := query.Offset + query.Limit
+        if end > len(board.Entries) {
+            end = len(board.Entries)
+        }
+        page.Entries = board.Entries[query.Offset:end]
+    }
+    return &page, nil
+}
+
+func (s *leaderboardService) Snapshot(ctx context.Context, query domain.LeaderboardQuery) (*domain.Leaderboard, error) {
+    key, since, minGames, err := s.resolve(ctx, query)
+    if err != nil {
+        return nil, err
+    }
+    return s.build(ctx, key, since, minGames)
+}
+
+func (s *leaderboardService) resolve(ctx context.Context, query domain.LeaderboardQuery) (leaderboardKey, *time.Time, int, error) {
+    if query.Variant == "" {
+        query.Variant = domain.VariantStandard
+    }
+    if query.TimeControl == "" {
+        query.TimeControl = domain.TimeControlUntimed
+    }
+    if query.Period == "" {
+        query.Period = domain.PeriodAllTime
+    }
+    if !domain.ValidGameLabel(query.Variant) || !domain.ValidGameLabel(query.TimeControl) {
+        return leaderboardKey{}, nil, 0, domain.ErrInvalidInput
+    }
+
+    var minGames int
+    switch query.Period {
+    case domain.PeriodAllTime:
+        minGames = s.opts.MinGamesAllTime
+    case domain.PeriodMonthly:
+        minGames = s.opts.MinGamesMonthly
+    case domain.PeriodWeekly:
+        minGames = s.opts.MinGamesWeekly
+    case domain.PeriodSeason:
+        minGames = s.opts.MinGamesSeason
+    default:
+        return leaderboardKey{}, nil, 0, domain.ErrInvalidInput
+    }
+
+    key := leaderboardKey{variant: query.Variant, timeControl: query.TimeControl, period: query.Period}
+    since := query.Period.Start(time.Now())
+    if query.Period == domain.PeriodSeason {
+        season, err := s.seasons.GetOpenSeason(ctx)
+        if err != nil {
+            return leaderboardKey{}, nil, 0, err
+        }
+        since = &season.StartsAt
+    }
+    if since != nil {
+        key.since = *since
+    }
+    return key, since, minGames, nil
+}
+
+func (s *leaderboardService) cached(ctx context.Context, key leaderboardKey, since *time.Time, minGames int) (*domain.Leaderboard, error) {
+    s.mu.Lock()
+    c, ok := s.cache[key]
+    if !ok {
+        c = &cachedLeaderboard{stale: true}
+        s.cache[key] = c
+    }
+    if c.board != nil && (!c.stale || c.building != nil) {
+        board := c.board
+        s.mu.Unlock()
+        return board, nil
+    }
+    if wait := c.building; wait != nil {
+        s.mu.Unlock()
+        select {
+        case <-wait:
+        case <-ctx.Done():
+            return nil, ctx.Err()
+        }
+        s.mu.Lock()
+        defer s.mu.Unlock()
+        if c.board == nil {
+            return nil, c.err
+        }
+        return c.board, nil
+    }
+
+    done := make(chan struct{})
+    c.building = done
+    c.stale = false
+    s.mu.Unlock()
+
+    board, err := s.build(ctx, key, since, minGames)
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    c.building = nil
+    c.err = err
+    if err != nil {
+        c.stale = true
+    } else {
+        c.board = board
+    }
+    close(done)
+    return board, err
+}
+
+func (s *leaderboardService) build(ctx context.Context, key leaderboardKey, since *time.Time, minGames int) (*domain.Leaderboard, error) {
+    byRating := since == nil || key.period == domain.PeriodSeason
+    floor := 0
+    if since == nil {
+        floor = max(minGames, 1)
+    }
+    ratings, err := s.stats.ListRatingsByVariant(ctx, key.variant, key.timeControl, floor)
+    if err != nil {
+        return nil, err
+    }
+
+    var entries []*domain.LeaderboardEntry
+    if since == nil {
+        for _, r := range ratings {
+            entries = append(entries, &domain.LeaderboardEntry{UserID: r.UserID, Rating: r.Rating, Games: r.Games})
+        }
+    } else {
+        current := make(map[uuid.UUID]int, len(ratings))
+        for _, r := range ratings {
+            current[r.UserID] = r.Rating
+        }
+        games, err := s.games.ListRatedGamesSince(ctx, key.variant, key.timeControl, *since)
+        if err != nil {
+            return nil, err
+        }
+        period := make(map[uuid.UUID]*domain.LeaderboardEntry)
+        for _, g := range games {
+            for _, userID := range []uuid.UUID{g.PlayerX, g.PlayerO} {
+                e, ok := period[userID]
+                if !ok {
+                    e = &domain.LeaderboardEntry{UserID: userID, Rating: domain.DefaultRating}
+                    if rating, ok := current[userID]; ok {
+                        e.Rating = rating
+                    }
+                    period[userID] = e
+                }
+                e.Games++
+                switch {
+                case g.WinnerUserID == nil:
+                    e.Draws++
+                case *g.WinnerUserID == userID:
+                    e.Wins++
+                default:
+                    e.Losses++
+                }
+            }
+        }
+        for _, e := range period {
+            if e.Games >= minGames {
+                entries = append(entries, e)
+            }
+        }
+    }
+
+    entries, err = s.withUsernames(ctx, entries)
+    if err != nil {
+        return nil, err
+    }
+
+    if byRating {
+        sort.Slice(entries, func(i, j int) bool {
+            a, b := entries[i], entries[j]
+            if a.Rating != b.Rating {
+                return a.Rating > b.Rating
+            }
+            if a.Games != b.Games {
+                return a.Games > b.Games
+            }
+            return a.Username < b.Username
+        })
+    } else {
+        sort.Slice(entries, func(i, j int) bool {
+            a, b := entries[i], entries[j]
+            if a.Points() != b.Points() {
+                return a.Points() > b.Points()
+            }
+            if a.Wins != b.Wins {
+                return a.Wins > b.Wins
+            }
+            if a.Games != b.Games {
+                return a.Games < b.Games
+            }
+            if a.Rating != b.Rating {
+                return a.Rating > b.Rating
+            }
+            return a.Username < b.Username
+        })
+    }
+    for i, e := range entries {
+        e.Rank = i + 1
+        if i > 0 && sameStanding(entries[i-1], e, byRating) {
+            e.Rank = entries[i-1].Rank
+        }
+    }
+
+    return &domain.Leaderboard{
+        Variant:     key.variant,
+        TimeControl: key.timeControl,
+        Period:      key.period,
+        Since:       since,
+        MinGames:    minGames,
+        Total:       len(entries),
+        Entries:     entries,
+        UpdatedAt:   time.Now().UTC(),
+    }, nil
+}
+
+func (s *leaderboardService) withUsernames(ctx context.Context, entries []*domain.LeaderboardEntry) ([]*domain.LeaderboardEntry, error) {
+    ids := make([]uuid.UUID, 0, len(entries))
+    for _, e := range entries {
+        ids = append(ids, e.UserID)
+    }
+    users, err := s.users.ListUsersByIDs(ctx, ids)
+    if err != nil {
+        return nil, err
+    }
+    listed := make(map[uuid.UUID]*domain.User, len(users))
+    for _, u := range users {
+        if u.DeletedAt == nil && !u.Banned {
+            listed[u.ID] = u
+        }
+    }
+
+    kept := make([]*domain.LeaderboardEntry, 0, len(entries))
+    for _, e := range entries {
+        if u, ok := listed[e.UserID]; ok {
+            e.Username = u.Username
+            kept = append(kept, e)
+        }
+    }
+    return kept, nil
+}
+
+func sameStanding(a, b *domain.LeaderboardEntry, byRating bool) bool {
+    if byRating {
+        return a.Rating == b.Rating
+    }
+    return a.Points() == b.Points() && a.Wins == b.Wins
+}
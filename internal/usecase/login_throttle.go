@@ -0,0 +1,112 @@
+﻿package usecase
+
+import (
+    "context"
+    "sync"
+    "time"
+
+    "xo-server/internal/domain"
+)
+
+const maxThrottleEntries = 10000
+
+type clientIPKey struct{}
+
+func WithClientIP(ctx context.Context, ip string) context.Context {
+    return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+func ClientIPFromContext(ctx context.Context) string {
+    ip, _ := ctx.Value(clientIPKey{}).(string)
+    return ip
+}
+
+type attemptState struct {
+    failures    int
+    lastFailure time.Time
+    lockedUntil time.Time
+}
+
+type loginThrottle struct {
+    threshold int
+    base      time.Duration
+    max       time.Duration
+
+    mu      sync.Mutex
+    entries map[string]*attemptState
+}
+
+func newLoginThrottle(threshold int, base, max time.Duration) *loginThrottle {
+    return &loginThrottle{threshold: threshold, base: base, max: max, entries: make(map[string]*attemptState)}
+}
+
+func (t *loginThrottle) check(now time.Time, keys ...string) error {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    for _, key := range keys {
+        if st, ok := t.entries[key]; ok && now.Before(st.lockedUntil) {
+            return domain.ErrTooManyAttempts
+        }
+    }
+    return nil
+}
+
+func (t *loginThrottle) fail(now time.Time, keys ...string) {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    if len(t.entries) >= maxThrottleEntries {
+        t.prune(now)
+    }
+    for _, key := range keys {
+        st, ok := t.entries[key]
+        if !ok {
+            if len(t.entries) >= maxThrottleEntries {
+                t.evictOldest()
+            }
+            st = &attemptState{}
+            t.entries[key] = st
+        }
+        if now.Sub(st.lastFailure) > t.max {
+            st.failures = 0
+        }
+        st.failures++
+        st.lastFailure = now
+        if st.failures < t.threshold {
+            continue
+        }
+
+        delay := t.base
+        for i := t.threshold; i < st.failures && delay < t.max; i++ {
+            delay *= 2
+        }
+        if delay > t.max {
+            delay = t.max
+        }
+        st.lockedUntil = now.Add(delay)
+    }
+}
+
+func (t *loginThrottle) reset(key string) {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    delete(t.entries, key)
+}
+
+func (t *loginThrottle) evictOldest() {
+    var oldest string
+    var at time.Time
+    for key, st := range t.entries {
+        if oldest == "" || st.lastFailure.Before(at) {
+            oldest, at = key, st.lastFailure
+        }
+    }
+    delete(t.entries, oldest)
+}
+
+func (t *loginThrottle) prune(now time.Time) {
+    for key, st := range t.entries {
+        if now.After(st.lockedUntil) && now.Sub(st.lastFailure) > t.max {
+            delete(t.entries, key)
+        }
+    }
+}
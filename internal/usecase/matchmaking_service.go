@@ -9,48 +9,69 @@ import (
     "xo-server/internal/domain"
 )
 
+type MatchmakingOptions struct {
+    GuestsRated bool
+}
+
 type matchmakingService struct {
-    games GameRepository
-    mu    sync.Mutex
-    queue []uuid.UUID
+    games  GameRepository
+    opts   MatchmakingOptions
+    mu     sync.Mutex
+    queues map[domain.QueuePool][]uuid.UUID
 }
 
-func NewMatchmakingService(games GameRepository) MatchmakingService {
-    return &matchmakingService{games: games, queue: make([]uuid.UUID, 0)}
+func NewMatchmakingService(games GameRepository, opts MatchmakingOptions) MatchmakingService {
+    return &matchmakingService{games: games, opts: opts, queues: make(map[domain.QueuePool][]uuid.UUID)}
 }
 
-func (s *matchmakingService) JoinQueue(userID uuid.UUID) (bool, *domain.Game, error) {
+func (s *matchmakingService) JoinQueue(user *domain.User, pool domain.QueuePool) (bool, *domain.Game, error) {
+    switch pool {
+    case "":
+        pool = domain.PoolRated
+        if user.IsGuest && !s.opts.GuestsRated {
+            pool = domain.PoolCasual
+        }
+    case domain.PoolRated:
+        if user.IsGuest && !s.opts.GuestsRated {
+            return false, nil, domain.ErrGuestNotAllowed
+        }
+    case domain.PoolCasual:
+    default:
+        return false, nil, domain.ErrInvalidInput
+    }
+
     s.mu.Lock()
     defer s.mu.Unlock()
 
-    for _, id := range s.queue {
-        if id == userID {
-            return false, nil, domain.ErrAlreadyInQueue
+    for _, queue := range s.queues {
+        for _, id := range queue {
+            if id == user.ID {
+                return false, nil, domain.ErrAlreadyInQueue
+            }
         }
     }
 
-    if len(s.queue) == 0 {
-        s.queue = append(s.queue, userID)
+    queue := s.queues[pool]
+    if len(queue) == 0 {
+        s.queues[pool] = append(queue, user.ID)
         return false, nil, nil
     }
 
-    opponent := s.queue[0]
-    s.queue = s.queue[1:]
-
-    if opponent == userID {
-        s.queue = append(s.queue, userID)
-        return false, nil, nil
-    }
+    opponent := queue[0]
+    s.queues[pool] = queue[1:]
 
     game := &domain.Game{
-        ID:        uuid.New(),
-        PlayerX:   opponent,
-        PlayerO:   userID,
-        Board:     domain.NewEmptyBoard(),
-        NextTurn:  "X",
-        Status:    domain.GameInProgress,
-        CreatedAt: time.Now().UTC(),
-        UpdatedAt: time.Now().UTC(),
+        ID:          uuid.New(),
+        PlayerX:     opponent,
+        PlayerO:     user.ID,
+        Board:       domain.NewEmptyBoard(),
+        NextTurn:    "X",
+        Status:      domain.GameInProgress,
+        Rated:       pool == domain.PoolRated,
+        Variant:     domain.VariantStandard,
+        TimeControl: domain.TimeControlUntimed,
+        CreatedAt:   time.Now().UTC(),
+        UpdatedAt:   time.Now().UTC(),
     }
 
     if err := s.games.CreateGame(context.Background(), game); err != nil {
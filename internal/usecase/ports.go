@@ -2,6 +2,7 @@
 
 import (
     "context"
+    "time"
 
     "github.com/google/uuid"
     "xo-server/internal/domain"
@@ -11,6 +12,11 @@ type UserRepository interface {
     CreateUser(ctx context.Context, user *domain.User) error
     GetUserByUsername(ctx context.Context, username string) (*domain.User, error)
     GetUserByID(ctx context.Context, id uuid.UUID) (*domain.User, error)
+    UpdatePassword(ctx context.Context, userID uuid.UUID, passwordHash string) error
+    UpgradeGuest(ctx context.Context, userID uuid.UUID, username, passwordHash string) error
+    UpdateRole(ctx context.Context, userID uuid.UUID, role domain.Role) error
+    UpdateSuspension(ctx context.Context, userID uuid.UUID, banned bool, until *time.Time, reason string) error
+    ListUsersByIDs(ctx context.Context, ids []uuid.UUID) ([]*domain.User, error)
 }
 
 type GameRepository interface {
@@ -18,18 +24,170 @@ type GameRepository interface {
     GetGameByID(ctx context.Context, id uuid.UUID) (*domain.Game, error)
     UpdateGame(ctx context.Context, game *domain.Game) error
     ListActiveGamesByUser(ctx context.Context, userID uuid.UUID) ([]*domain.Game, error)
+    ListActiveGames(ctx context.Context, limit int) ([]*domain.Game, error)
     AddMove(ctx context.Context, move *domain.GameMove) error
     AddMessage(ctx context.Context, msg *domain.GameMessage) error
+    ListGamesByUser(ctx context.Context, userID uuid.UUID) ([]*domain.Game, error)
+    ListMovesByUser(ctx context.Context, userID uuid.UUID) ([]*domain.GameMove, error)
+    ListMessagesByUser(ctx context.Context, userID uuid.UUID) ([]*domain.GameMessage, error)
+    ListFinishedGamesByUser(ctx context.Context, userID uuid.UUID, limit int) ([]*domain.Game, error)
+    ListRatedGamesSince(ctx context.Context, variant, timeControl string, since time.Time) ([]*domain.Game, error)
+    ListFinishedGamesBetween(ctx context.Context, a, b uuid.UUID, limit int) ([]*domain.Game, error)
+    CountFinishedGamesBetween(ctx context.Context, userID, opponentID uuid.UUID) (asX, asO domain.ColourRecord, err error)
+    ListMovesByGame(ctx context.Context, gameID uuid.UUID) ([]*domain.GameMove, error)
+}
+
+type GameEventRepository interface {
+    AppendGameEvent(ctx context.Context, event *domain.GameEvent) error
+    ListGameEventsAfter(ctx context.Context, gameID uuid.UUID, afterSeq int64, limit int) ([]*domain.GameEvent, error)
+    LastGameEventSeq(ctx context.Context, gameID uuid.UUID) (int64, error)
+}
+
+type FriendRepository interface {
+    CreateFriendship(ctx context.Context, f *domain.Friendship) error
+    GetFriendship(ctx context.Context, userA, userB uuid.UUID) (*domain.Friendship, error)
+    UpdateFriendship(ctx context.Context, f *domain.Friendship) error
+    DeleteFriendship(ctx context.Context, userA, userB uuid.UUID) error
+    ListFriendshipsByUser(ctx context.Context, userID uuid.UUID) ([]*domain.Friendship, error)
+}
+
+type ReportRepository interface {
+    CreateReport(ctx context.Context, report *domain.Report) error
+    ListReports(ctx context.Context, status domain.ReportStatus, limit int) ([]*domain.Report, error)
+    ResolveReport(ctx context.Context, id, resolvedBy uuid.UUID, resolution string, at time.Time) (bool, error)
+}
+
+type AuditRepository interface {
+    AppendAuditEvent(ctx context.Context, event *domain.AuditEvent) error
+    ListAuditEvents(ctx context.Context, filter domain.AuditFilter) ([]*domain.AuditEvent, error)
+}
+
+type StatsRepository interface {
+    GetPlayerStats(ctx context.Context, userID uuid.UUID) (*domain.PlayerStats, error)
+    GetRating(ctx context.Context, userID uuid.UUID, variant, timeControl string) (*domain.Rating, error)
+    ListRatings(ctx context.Context, userID uuid.UUID) ([]*domain.Rating, error)
+    ListRatingsByVariant(ctx context.Context, variant, timeControl string, minGames int) ([]*domain.Rating, error)
+    SaveStatsUpdate(ctx context.Context, update *domain.StatsUpdate) error
+}
+
+type AchievementRepository interface {
+    AwardAchievement(ctx context.Context, achievement *domain.UserAchievement) (bool, error)
+    ListAchievements(ctx context.Context, userID uuid.UUID) ([]*domain.UserAchievement, error)
+}
+
+type SeasonRepository interface {
+    GetSeason(ctx context.Context, id uuid.UUID) (*domain.Season, error)
+    GetOpenSeason(ctx context.Context) (*domain.Season, error)
+    ListSeasons(ctx context.Context) ([]*domain.Season, error)
+    OpenSeason(ctx context.Context, season *domain.Season) error
+    CloseSeason(ctx context.Context, id uuid.UUID, closedAt time.Time, standings []*domain.SeasonStanding) error
+    RolloverSeason(ctx context.Context, closingID uuid.UUID, standings []*domain.SeasonStanding, next *domain.Season) error
+    ListStandings(ctx context.Context, seasonID uuid.UUID) ([]*domain.SeasonStanding, error)
+}
+
+type GameEventPublisher interface {
+    EncodeGameEvent(eventType string, game *domain.Game, msg *domain.GameMessage) []byte
+    PublishGameEvent(game *domain.Game, event *domain.GameEvent)
+}
+
+type AchievementNotifier interface {
+    NotifyAchievement(userID uuid.UUID, achievement *domain.UserAchievement)
+}
+
+type PresenceTracker interface {
+    IsOnline(userID uuid.UUID) bool
+}
+
+type RefreshTokenRepository interface {
+    CreateRefreshToken(ctx context.Context, token *domain.RefreshToken) error
+    GetRefreshTokenByHash(ctx context.Context, hash string) (*domain.RefreshToken, error)
+    MarkRefreshTokenRotated(ctx context.Context, id uuid.UUID, at time.Time) (bool, error)
+    RevokeTokenFamily(ctx context.Context, familyID uuid.UUID, at time.Time) error
+    IsTokenFamilyRevoked(ctx context.Context, familyID uuid.UUID) (bool, error)
+    RevokeUserTokenFamilies(ctx context.Context, userID, except uuid.UUID, at time.Time) ([]uuid.UUID, error)
+    GetTokenFamilyStart(ctx context.Context, familyID uuid.UUID) (time.Time, error)
+}
+
+type PasswordResetRepository interface {
+    CreateResetToken(ctx context.Context, token *domain.PasswordResetToken) error
+    GetResetTokenByHash(ctx context.Context, hash string) (*domain.PasswordResetToken, error)
+    MarkResetTokenUsed(ctx context.Context, id uuid.UUID, at time.Time) (bool, error)
+    InvalidateResetTokens(ctx context.Context, userID uuid.UUID, at time.Time) error
+}
+
+type TwoFactorRepository interface {
+    GetTOTP(ctx context.Context, userID uuid.UUID) (*domain.TOTPSecret, error)
+    SaveTOTP(ctx context.Context, secret *domain.TOTPSecret) error
+    AdvanceTOTPStep(ctx context.Context, userID uuid.UUID, from, to int64) (bool, error)
+    DeleteTOTP(ctx context.Context, userID uuid.UUID) error
+    ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, hashes []string) error
+    UseRecoveryCode(ctx context.Context, userID uuid.UUID, hash string, at time.Time) (bool, error)
+}
+
+type PasswordResetNotifier interface {
+    SendPasswordReset(ctx context.Context, user *domain.User, token string, expiresAt time.Time) error
+}
+
+type IdentityRepository interface {
+    CreateIdentity(ctx context.Context, identity *domain.ExternalIdentity) error
+    GetIdentity(ctx context.Context, provider, subject string) (*domain.ExternalIdentity, error)
+    ListIdentitiesByUser(ctx context.Context, userID uuid.UUID) ([]*domain.ExternalIdentity, error)
+}
+
+type AccountRepository interface {
+    AnonymizeAccount(ctx context.Context, userID uuid.UUID, username, placeholder string, at time.Time) error
+}
+
+type ExternalIdentityProvider interface {
+    Name() string
+    AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error)
+    Exchange(ctx context.Context, code, codeVerifier, nonce string) (*domain.ExternalClaims, error)
 }
 
 type TokenProvider interface {
-    IssueToken(user *domain.User) (string, error)
-    ParseToken(token string) (*domain.User, error)
+    IssueToken(user *domain.User, sessionID uuid.UUID) (string, error)
+    ParseToken(token string) (*domain.User, uuid.UUID, error)
+}
+
+type SessionCloser interface {
+    CloseSession(sessionID uuid.UUID)
+    CloseUser(userID uuid.UUID, reason string)
 }
 
 type AuthService interface {
     Register(ctx context.Context, username, password string) (*domain.User, error)
-    Login(ctx context.Context, username, password string) (string, *domain.User, error)
+    Login(ctx context.Context, username, password string) (*domain.LoginResult, error)
+    CreateGuest(ctx context.Context) (*domain.TokenPair, *domain.User, error)
+    UpgradeGuest(ctx context.Context, userID, sessionID uuid.UUID, username, password string) (*domain.TokenPair, *domain.User, error)
+    VerifyTwoFactor(ctx context.Context, challengeToken, code string) (*domain.TokenPair, *domain.User, error)
+    Refresh(ctx context.Context, refreshToken string) (*domain.TokenPair, *domain.User, error)
+    Logout(ctx context.Context, userID, sessionID uuid.UUID) error
+    StartSession(ctx context.Context, user *domain.User) (*domain.TokenPair, error)
+    ChangePassword(ctx context.Context, userID, sessionID uuid.UUID, currentPassword, newPassword string) error
+    RequestPasswordReset(ctx context.Context, username string) error
+    ResetPassword(ctx context.Context, token, newPassword string) error
+    EnrollTOTP(ctx context.Context, userID uuid.UUID) (*domain.TOTPEnrollment, error)
+    ActivateTOTP(ctx context.Context, userID uuid.UUID, code string) ([]string, error)
+    DisableTOTP(ctx context.Context, userID uuid.UUID, password, code string) error
+    SetRole(ctx context.Context, actor *domain.User, userID uuid.UUID, role domain.Role) error
+    GrantRole(ctx context.Context, username string, role domain.Role) error
+    SuspendUser(ctx context.Context, actor *domain.User, userID uuid.UUID, until *time.Time, reason string) error
+    LiftSuspension(ctx context.Context, actor *domain.User, userID uuid.UUID) error
+}
+
+type ExternalLoginService interface {
+    Providers() []string
+    Begin(ctx context.Context, provider string, linkUserID uuid.UUID) (string, error)
+    Complete(ctx context.Context, provider, state, code string) (*domain.TokenPair, *domain.User, error)
+    ListIdentities(ctx context.Context, userID uuid.UUID) ([]*domain.ExternalIdentity, error)
+}
+
+type GameEndListener interface {
+    GameEnded(ctx context.Context, game, previous *domain.Game)
+}
+
+type StatsListener interface {
+    StatsRecorded(ctx context.Context, game *domain.Game, stats []*domain.PlayerStats)
 }
 
 type GameService interface {
@@ -38,11 +196,74 @@ type GameService interface {
     OfferDraw(ctx context.Context, userID, gameID uuid.UUID) (*domain.Game, error)
     AcceptDraw(ctx context.Context, userID, gameID uuid.UUID) (*domain.Game, error)
     DeclineDraw(ctx context.Context, userID, gameID uuid.UUID) (*domain.Game, error)
-    AddChat(ctx context.Context, userID, gameID uuid.UUID, message string) error
+    AddChat(ctx context.Context, userID, gameID uuid.UUID, message string) (*domain.GameMessage, error)
     GetGame(ctx context.Context, gameID uuid.UUID) (*domain.Game, error)
     GetActiveGames(ctx context.Context, userID uuid.UUID) ([]*domain.Game, error)
+    AnnounceGame(ctx context.Context, gameID uuid.UUID) (*domain.Game, error)
+    ListLiveGames(ctx context.Context, actor *domain.User, limit int) ([]*domain.Game, error)
+    ForceFinish(ctx context.Context, actor *domain.User, gameID uuid.UUID, winnerID *uuid.UUID, reason string) (*domain.Game, error)
+    AbortGame(ctx context.Context, actor *domain.User, gameID uuid.UUID, reason string) (*domain.Game, error)
+    AdjustResult(ctx context.Context, actor *domain.User, gameID uuid.UUID, winnerID *uuid.UUID, reason string) (*domain.Game, error)
+}
+
+type GameEventService interface {
+    Replay(ctx context.Context, userID, gameID uuid.UUID, afterSeq int64) ([]*domain.GameEvent, error)
+    LastSeq(ctx context.Context, gameID uuid.UUID) (int64, error)
+}
+
+type FriendService interface {
+    SendRequest(ctx context.Context, userID uuid.UUID, username string) (*domain.Friendship, error)
+    AcceptRequest(ctx context.Context, userID, requesterID uuid.UUID) (*domain.Friendship, error)
+    DeclineRequest(ctx context.Context, userID, requesterID uuid.UUID) error
+    RemoveFriend(ctx context.Context, userID, friendID uuid.UUID) error
+    ListFriends(ctx context.Context, userID uuid.UUID) ([]*domain.Friend, error)
+    ListRequests(ctx context.Context, userID uuid.UUID) ([]*domain.FriendRequest, error)
+    FriendIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error)
+    Challenge(ctx context.Context, userID, friendID uuid.UUID) (*domain.Game, error)
+}
+
+type ReportService interface {
+    Report(ctx context.Context, reporterID, userID uuid.UUID, gameID *uuid.UUID, reason string) (*domain.Report, error)
+    ListReports(ctx context.Context, actor *domain.User, status domain.ReportStatus, limit int) ([]*domain.Report, error)
+    ResolveReport(ctx context.Context, actor *domain.User, reportID uuid.UUID, resolution string) error
+}
+
+type StatsService interface {
+    GameEndListener
+    Profile(ctx context.Context, username string, recent int) (*domain.Profile, error)
+    HeadToHead(ctx context.Context, username, opponent string, limit int) (*domain.HeadToHead, error)
+}
+
+type LeaderboardService interface {
+    GameEndListener
+    Leaderboard(ctx context.Context, query domain.LeaderboardQuery) (*domain.Leaderboard, error)
+    Snapshot(ctx context.Context, query domain.LeaderboardQuery) (*domain.Leaderboard, error)
+    Refresh()
+}
+
+type SeasonService interface {
+    Current(ctx context.Context) (*domain.Season, error)
+    ListSeasons(ctx context.Context) ([]*domain.Season, error)
+    Standings(ctx context.Context, seasonID uuid.UUID, limit, offset int) (*domain.SeasonStandings, error)
+    OpenSeason(ctx context.Context, actor *domain.User, name string) (*domain.Season, error)
+    CloseSeason(ctx context.Context, actor *domain.User) (*domain.Season, error)
+    Run(ctx context.Context)
+}
+
+type AchievementService interface {
+    StatsListener
+    ListAchievements(ctx context.Context, username string) ([]*domain.UserAchievement, error)
+}
+
+type AccountService interface {
+    ExportData(ctx context.Context, userID uuid.UUID) (*domain.AccountExport, error)
+    DeleteAccount(ctx context.Context, userID, sessionID uuid.UUID, password string) error
+}
+
+type AuditService interface {
+    ListEvents(ctx context.Context, actor *domain.User, filter domain.AuditFilter) ([]*domain.AuditEvent, error)
 }
 
 type MatchmakingService interface {
-    JoinQueue(userID uuid.UUID) (bool, *domain.Game, error)
+    JoinQueue(user *domain.User, pool domain.QueuePool) (bool, *domain.Game, error)
 }
@@ -0,0 +1,92 @@
+﻿package usecase
+
+import (
+    "context"
+    "strings"
+    "time"
+
+    "github.com/google/uuid"
+    "xo-server/internal/domain"
+)
+
+const (
+    maxReportReason = 1000
+    maxReportList   = 200
+)
+
+type reportService struct {
+    reports ReportRepository
+    users   UserRepository
+    games   GameRepository
+    audit   AuditRepository
+}
+
+func NewReportService(reports ReportRepository, users UserRepository, games GameRepository, audit AuditRepository) ReportService {
+    return &reportService{reports: reports, users: users, games: games, audit: audit}
+}
+
+func (s *reportService) Report(ctx context.Context, reporterID, userID uuid.UUID, gameID *uuid.UUID, reason string) (*domain.Report, error) {
+    reason = strings.TrimSpace(reason)
+    if reason == "" || len(reason) > maxReportReason || reporterID == userID {
+        return nil, domain.ErrInvalidInput
+    }
+    if _, err := s.users.GetUserByID(ctx, userID); err != nil {
+        return nil, err
+    }
+    if gameID != nil {
+        game, err := s.games.GetGameByID(ctx, *gameID)
+        if err != nil {
+            return nil, err
+        }
+        if game.PlayerX != userID && game.PlayerO != userID {
+            return nil, domain.ErrInvalidInput
+        }
+    }
+
+    report := &domain.Report{
+        ID:         uuid.New(),
+        ReporterID: reporterID,
+        UserID:     userID,
+        GameID:     gameID,
+        Reason:     reason,
+        Status:     domain.ReportOpen,
+        CreatedAt:  time.Now().UTC(),
+    }
+    if err := s.reports.CreateReport(ctx, report); err != nil {
+        return nil, err
+    }
+    return report, nil
+}
+
+func (s *reportService) ListReports(ctx context.Context, actor *domain.User, status domain.ReportStatus, limit int) ([]*domain.Report, error) {
+    if err := domain.Authorize(actor, domain.PermModerate); err != nil {
+        return nil, err
+    }
+    if status != "" && status != domain.ReportOpen && status != domain.ReportResolved {
+        return nil, domain.ErrInvalidInput
+    }
+    if limit <= 0 || limit > maxReportList {
+        limit = maxReportList
+    }
+    return s.reports.ListReports(ctx, status, limit)
+}
+
+func (s *reportService) ResolveReport(ctx context.Context, actor *domain.User, reportID uuid.UUID, resolution string) error {
+    if err := domain.Authorize(actor, domain.PermModerate); err != nil {
+        return err
+    }
+    resolution = strings.TrimSpace(resolution)
+    if resolution == "" {
+        return domain.ErrInvalidInput
+    }
+
+    ok, err := s.reports.ResolveReport(ctx, reportID, actor.ID, resolution, time.Now().UTC())
+    if err != nil {
+        return err
+    }
+    if !ok {
+        return domain.ErrNotFound
+    }
+    recordAudit(ctx, s.audit, domain.AuditReportResolved, actor.ID, reportID, resolution)
+    return nil
+}
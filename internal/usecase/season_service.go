@@ -0,0 +1,254 @@
+﻿package usecase
+
+import (
+    "context"
+    "log"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/google/uuid"
+    "xo-server/internal/domain"
+)
+
+type SeasonOptions struct {
+    Schedule      domain.SeasonSchedule
+    CarryOver     float64
+    Rewards       []domain.SeasonReward
+    CheckInterval time.Duration
+}
+
+type seasonService struct {
+    seasons SeasonRepository
+    boards  LeaderboardService
+    audit   AuditRepository
+    opts    SeasonOptions
+    mu      sync.Mutex
+}
+
+func NewSeasonService(seasons SeasonRepository, boards LeaderboardService, audit AuditRepository, opts SeasonOptions) SeasonService {
+    rewards := append([]domain.SeasonReward(nil), opts.Rewards...)
+    sort.Slice(rewards, func(i, j int) bool { return rewards[i].Top < rewards[j].Top })
+    opts.Rewards = rewards
+    return &seasonService{seasons: seasons, boards: boards, audit: audit, opts: opts}
+}
+
+func (s *seasonService) Current(ctx context.Context) (*domain.Season, error) {
+    return s.seasons.GetOpenSeason(ctx)
+}
+
+func (s *seasonService) ListSeasons(ctx context.Context) ([]*domain.Season, error) {
+    return s.seasons.ListSeasons(ctx)
+}
+
+func (s *seasonService) Standings(ctx context.Context, seasonID uuid.UUID, limit, offset int) (*domain.SeasonStandings, error) {
+    if offset < 0 {
+        return nil, domain.ErrInvalidInput
+    }
+    if limit <= 0 {
+        limit = defaultLeaderboardLimit
+    }
+    if limit > maxLeaderboardLimit {
+        limit = maxLeaderboardLimit
+    }
+
+    season, err := s.seasons.GetSeason(ctx, seasonID)
+    if err != nil {
+        return nil, err
+    }
+
+    if season.Status == domain.SeasonOpen {
+        board, err := s.boards.Leaderboard(ctx, domain.LeaderboardQuery{Period: domain.PeriodSeason, Limit: limit, Offset: offset})
+        if err != nil {
+            return nil, err
+        }
+        result := &domain.SeasonStandings{Season: season, Total: board.Total}
+        for _, e := range board.Entries {
+            result.Entries = append(result.Entries, s.standing(season.ID, e))
+        }
+        return result, nil
+    }
+
+    standings, err := s.seasons.ListStandings(ctx, season.ID)
+    if err != nil {
+        return nil, err
+    }
+    result := &domain.SeasonStandings{Season: season, Total: len(standings)}
+    if offset < len(standings) {
+        end := min(offset+limit, len(standings))
+        result.Entries = standings[offset:end]
+    }
+    return result, nil
+}
+
+func (s *seasonService) OpenSeason(ctx context.Context, actor *domain.User, name string) (*domain.Season, error) {
+    if err := domain.Authorize(actor, domain.PermManageGames); err != nil {
+        return nil, err
+    }
+    now := time.Now().UTC()
+    name = strings.TrimSpace(name)
+    if name == "" {
+        name = s.opts.Schedule.Name(now)
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.open(ctx, name, now, actor.ID)
+}
+
+func (s *seasonService) CloseSeason(ctx context.Context, actor *domain.User) (*domain.Season, error) {
+    if err := domain.Authorize(actor, domain.PermManageGames); err != nil {
+        return nil, err
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    season, err := s.seasons.GetOpenSeason(ctx)
+    if err != nil {
+        return nil, err
+    }
+    if err := s.close(ctx, season, time.Now().UTC(), actor.ID); err != nil {
+        return nil, err
+    }
+    return season, nil
+}
+
+func (s *seasonService) Run(ctx context.Context) {
+    if s.opts.Schedule == domain.ScheduleManual || s.opts.CheckInterval <= 0 {
+        return
+    }
+    s.tick(ctx, time.Now().UTC())
+
+    ticker := time.NewTicker(s.opts.CheckInterval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case now := <-ticker.C:
+            s.tick(ctx, now.UTC())
+        }
+    }
+}
+
+func (s *seasonService) tick(ctx context.Context, now time.Time) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    season, err := s.seasons.GetOpenSeason(ctx)
+    if err == domain.ErrNotFound {
+        if !s.due(ctx, now) {
+            return
+        }
+        if _, err := s.open(ctx, s.opts.Schedule.Name(now), now, uuid.Nil); err != nil {
+            log.Printf("season open error: %v", err)
+        }
+        return
+    }
+    if err != nil {
+        log.Printf("season schedule error: %v", err)
+        return
+    }
+    if season.EndsAt == nil || now.Before(*season.EndsAt) {
+        return
+    }
+
+    standings, err := s.archive(ctx, season)
+    if err != nil {
+        log.Printf("season close error: %v", err)
+        return
+    }
+    next := s.newSeason(s.opts.Schedule.Name(now), now)
+    if err := s.seasons.RolloverSeason(ctx, season.ID, standings, next); err != nil {
+        log.Printf("season rollover error: %v", err)
+        return
+    }
+    s.boards.Refresh()
+    recordAudit(ctx, s.audit, domain.AuditSeasonClosed, uuid.Nil, season.ID, season.Name)
+    recordAudit(ctx, s.audit, domain.AuditSeasonOpened, uuid.Nil, next.ID, next.Name)
+}
+
+func (s *seasonService) due(ctx context.Context, now time.Time) bool {
+    seasons, err := s.seasons.ListSeasons(ctx)
+    if err != nil {
+        log.Printf("season schedule error: %v", err)
+        return false
+    }
+    if len(seasons) == 0 {
+        return true
+    }
+    last := seasons[0]
+    return last.EndsAt != nil && !now.Before(*last.EndsAt)
+}
+
+func (s *seasonService) open(ctx context.Context, name string, now time.Time, actorID uuid.UUID) (*domain.Season, error) {
+    season := s.newSeason(name, now)
+    if err := s.seasons.OpenSeason(ctx, season); err != nil {
+        return nil, err
+    }
+    s.boards.Refresh()
+
+    recordAudit(ctx, s.audit, domain.AuditSeasonOpened, actorID, season.ID, season.Name)
+    return season, nil
+}
+
+func (s *seasonService) close(ctx context.Context, season *domain.Season, now time.Time, actorID uuid.UUID) error {
+    standings, err := s.archive(ctx, season)
+    if err != nil {
+        return err
+    }
+    if err := s.seasons.CloseSeason(ctx, season.ID, now, standings); err != nil {
+        return err
+    }
+    season.Status = domain.SeasonClosed
+    season.ClosedAt = &now
+    s.boards.Refresh()
+
+    recordAudit(ctx, s.audit, domain.AuditSeasonClosed, actorID, season.ID, season.Name)
+    return nil
+}
+
+func (s *seasonService) newSeason(name string, now time.Time) *domain.Season {
+    return &domain.Season{
+        ID:        uuid.New(),
+        Name:      name,
+        Status:    domain.SeasonOpen,
+        StartsAt:  now,
+        EndsAt:    s.opts.Schedule.End(now),
+        CarryOver: s.opts.CarryOver,
+    }
+}
+
+func (s *seasonService) archive(ctx context.Context, season *domain.Season) ([]*domain.SeasonStanding, error) {
+    board, err := s.boards.Snapshot(ctx, domain.LeaderboardQuery{Period: domain.PeriodSeason})
+    if err != nil {
+        return nil, err
+    }
+    standings := make([]*domain.SeasonStanding, 0, len(board.Entries))
+    for _, e := range board.Entries {
+        standings = append(standings, s.standing(season.ID, e))
+    }
+    return standings, nil
+}
+
+func (s *seasonService) standing(seasonID uuid.UUID, e *domain.LeaderboardEntry) *domain.SeasonStanding {
+    standing := &domain.SeasonStanding{
+        SeasonID: seasonID,
+        Rank:     e.Rank,
+        UserID:   e.UserID,
+        Username: e.Username,
+        Rating:   e.Rating,
+        Games:    e.Games,
+        Wins:     e.Wins,
+        Draws:    e.Draws,
+        Losses:   e.Losses,
+    }
+    for _, r := range s.opts.Rewards {
+        if e.Rank <= r.Top {
+            standing.Reward = r.Reward
+            break
+        }
+    }
+    return standing
+}
@@ -0,0 +1,286 @@
+﻿package usecase
+
+import (
+    "context"
+    "log"
+    "sort"
+    "sync"
+    "time"
+
+    "github.com/google/uuid"
+    "xo-server/internal/domain"
+)
+
+const (
+    defaultRecentGames    = 10
+    maxRecentGames        = 50
+    defaultHeadToHeadList = 20
+    maxHeadToHeadList     = 100
+)
+
+type statsService struct {
+    stats     StatsRepository
+    users     UserRepository
+    games     GameRepository
+    seasons   SeasonRepository
+    listeners []StatsListener
+    mu        sync.Mutex
+}
+
+func NewStatsService(stats StatsRepository, users UserRepository, games GameRepository, seasons SeasonRepository, listeners ...StatsListener) StatsService {
+    return &statsService{stats: stats, users: users, games: games, seasons: seasons, listeners: listeners}
+}
+
+func (s *statsService) Profile(ctx context.Context, username string, recent int) (*domain.Profile, error) {
+    user, err := s.publicUser(ctx, username)
+    if err != nil {
+        return nil, err
+    }
+    if recent <= 0 {
+        recent = defaultRecentGames
+    }
+    if recent > maxRecentGames {
+        recent = maxRecentGames
+    }
+
+    stats, err := s.playerStats(ctx, user.ID)
+    if err != nil {
+        return nil, err
+    }
+    ratings, err := s.stats.ListRatings(ctx, user.ID)
+    if err != nil {
+        return nil, err
+    }
+    games, err := s.games.ListFinishedGamesByUser(ctx, user.ID, recent)
+    if err != nil {
+        return nil, err
+    }
+    return &domain.Profile{User: user, Stats: stats, Ratings: ratings, RecentGames: games}, nil
+}
+
+func (s *statsService) HeadToHead(ctx context.Context, username, opponent string, limit int) (*domain.HeadToHead, error) {
+    user, err := s.publicUser(ctx, username)
+    if err != nil {
+        return nil, err
+    }
+    other, err := s.publicUser(ctx, opponent)
+    if err != nil {
+        return nil, err
+    }
+    if user.ID == other.ID {
+        return nil, domain.ErrInvalidInput
+    }
+    if limit <= 0 {
+        limit = defaultHeadToHeadList
+    }
+    if limit > maxHeadToHeadList {
+        limit = maxHeadToHeadList
+    }
+
+    asX, asO, err := s.games.CountFinishedGamesBetween(ctx, user.ID, other.ID)
+    if err != nil {
+        return nil, err
+    }
+    games, err := s.games.ListFinishedGamesBetween(ctx, user.ID, other.ID, limit)
+    if err != nil {
+        return nil, err
+    }
+    return &domain.HeadToHead{User: user, Opponent: other, Total: asX.Plus(asO), AsX: asX, AsO: asO, Games: games}, nil
+}
+
+func (s *statsService) GameEnded(ctx context.Context, game, previous *domain.Game) {
+    s.mu.Lock()
+    recorded, err := s.gameEnded(ctx, game, previous)
+    s.mu.Unlock()
+    if err != nil {
+        log.Printf("stats for game %s error: %v", game.ID, err)
+    }
+    if len(recorded) == 0 {
+        return
+    }
+    for _, l := range s.listeners {
+        l.StatsRecorded(ctx, game, recorded)
+    }
+}
+
+func (s *statsService) gameEnded(ctx context.Context, game, previous *domain.Game) ([]*domain.PlayerStats, error) {
+    update := &domain.StatsUpdate{}
+    if previous != nil {
+        for _, userID := range []uuid.UUID{game.PlayerX, game.PlayerO} {
+            stats, err := s.rebuild(ctx, userID)
+            if err != nil {
+                return nil, err
+            }
+            update.Stats = append(update.Stats, stats)
+        }
+        if game.Rated || previous.Rated {
+            if err := s.recomputeRatings(ctx, game, update); err != nil {
+                return nil, err
+            }
+        }
+        return nil, s.stats.SaveStatsUpdate(ctx, update)
+    }
+    if game.Status != domain.GameFinished {
+        return nil, nil
+    }
+
+    for _, userID := range []uuid.UUID{game.PlayerX, game.PlayerO} {
+        stats, err := s.playerStats(ctx, userID)
+        if err != nil {
+            return nil, err
+        }
+        stats.Record(game, userID)
+        update.Stats = append(update.Stats, stats)
+    }
+    if game.Rated {
+        if err := s.applyRatings(ctx, game, update); err != nil {
+            return nil, err
+        }
+    }
+    if err := s.stats.SaveStatsUpdate(ctx, update); err != nil {
+        return nil, err
+    }
+    return update.Stats, nil
+}
+
+func (s *statsService) rebuild(ctx context.Context, userID uuid.UUID) (*domain.PlayerStats, error) {
+    games, err := s.games.ListGamesByUser(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+    sort.Slice(games, func(i, j int) bool { return games[i].CreatedAt.Before(games[j].CreatedAt) })
+
+    stats := &domain.PlayerStats{UserID: userID}
+    for _, g := range games {
+        if g.Status == domain.GameFinished {
+            stats.Record(g, userID)
+        }
+    }
+    stats.UpdatedAt = time.Now().UTC()
+    return stats, nil
+}
+
+func (s *statsService) applyRatings(ctx context.Context, game *domain.Game, update *domain.StatsUpdate) error {
+    variant, timeControl := game.RatingPool()
+    x, err := s.rating(ctx, game.PlayerX, variant, timeControl)
+    if err != nil {
+        return err
+    }
+    o, err := s.rating(ctx, game.PlayerO, variant, timeControl)
+    if err != nil {
+        return err
+    }
+    update.Changes = append(update.Changes, rateGame(game, x, o, time.Now().UTC())...)
+    update.Ratings = append(update.Ratings, x, o)
+    return nil
+}
+
+func (s *statsService) recomputeRatings(ctx context.Context, game *domain.Game, update *domain.StatsUpdate) error {
+    variant, timeControl := game.RatingPool()
+    games, err := s.games.ListRatedGamesSince(ctx, variant, timeControl, time.Time{})
+    if err != nil {
+        return err
+    }
+    sort.Slice(games, func(i, j int) bool { return games[i].CreatedAt.Before(games[j].CreatedAt) })
+    seasons, err := s.seasons.ListSeasons(ctx)
+    if err != nil {
+        return err
+    }
+    sort.Slice(seasons, func(i, j int) bool { return seasons[i].StartsAt.Before(seasons[j].StartsAt) })
+    existing, err := s.stats.ListRatingsByVariant(ctx, variant, timeControl, 0)
+    if err != nil {
+        return err
+    }
+
+    ratings := make(map[uuid.UUID]*domain.Rating, len(existing))
+    rating := func(userID uuid.UUID) *domain.Rating {
+        if r, ok := ratings[userID]; ok {
+            return r
+        }
+        r := &domain.Rating{UserID: userID, Variant: variant, TimeControl: timeControl, Rating: domain.DefaultRating}
+        ratings[userID] = r
+        return r
+    }
+    for _, r := range existing {
+        rating(r.UserID)
+    }
+    next := 0
+    resetUntil := func(at time.Time) {
+        for ; next < len(seasons) && !seasons[next].StartsAt.After(at); next++ {
+            for _, r := range ratings {
+                r.Rating = domain.SoftReset(r.Rating, seasons[next].CarryOver)
+            }
+        }
+    }
+
+    now := time.Now().UTC()
+    for _, g := range games {
+        resetUntil(g.CreatedAt)
+        update.Changes = append(update.Changes, rateGame(g, rating(g.PlayerX), rating(g.PlayerO), now)...)
+    }
+    resetUntil(now)
+    for _, r := range ratings {
+        r.UpdatedAt = now
+        update.Ratings = append(update.Ratings, r)
+    }
+    update.Cleared = append(update.Cleared, game.ID)
+    return nil
+}
+
+func rateGame(game *domain.Game, x, o *domain.Rating, now time.Time) []*domain.RatingChange {
+    score := 0.5
+    if game.WinnerUserID != nil {
+        score = 0
+        if *game.WinnerUserID == game.PlayerX {
+            score = 1
+        }
+    }
+    newX, newO := domain.EloRatings(x.Rating, o.Rating, score)
+
+    changes := make([]*domain.RatingChange, 0, 2)
+    for _, u := range []struct {
+        rating *domain.Rating
+        after  int
+    }{{x, newX}, {o, newO}} {
+        changes = append(changes, &domain.RatingChange{
+            GameID:      game.ID,
+            UserID:      u.rating.UserID,
+            Variant:     u.rating.Variant,
+            TimeControl: u.rating.TimeControl,
+            Before:      u.rating.Rating,
+            After:       u.after,
+            CreatedAt:   now,
+        })
+        u.rating.Rating = u.after
+        u.rating.Games++
+        u.rating.UpdatedAt = now
+    }
+    return changes
+}
+
+func (s *statsService) playerStats(ctx context.Context, userID uuid.UUID) (*domain.PlayerStats, error) {
+    stats, err := s.stats.GetPlayerStats(ctx, userID)
+    if err == domain.ErrNotFound {
+        return &domain.PlayerStats{UserID: userID}, nil
+    }
+    return stats, err
+}
+
+func (s *statsService) rating(ctx context.Context, userID uuid.UUID, variant, timeControl string) (*domain.Rating, error) {
+    rating, err := s.stats.GetRating(ctx, userID, variant, timeControl)
+    if err == domain.ErrNotFound {
+        return &domain.Rating{UserID: userID, Variant: variant, TimeControl: timeControl, Rating: domain.DefaultRating}, nil
+    }
+    return rating, err
+}
+
+func (s *statsService) publicUser(ctx context.Context, username string) (*domain.User, error) {
+    user, err := s.users.GetUserByUsername(ctx, username)
+    if err != nil {
+        return nil, err
+    }
+    if user.DeletedAt != nil {
+        return nil, domain.ErrNotFound
+    }
+    return user, nil
+}
@@ -0,0 +1,334 @@
+﻿package usecase
+
+import (
+    "context"
+    "crypto/hmac"
+    "crypto/rand"
+    "crypto/sha1"
+    "crypto/subtle"
+    "encoding/base32"
+    "encoding/binary"
+    "fmt"
+    "net/url"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/google/uuid"
+    "golang.org/x/crypto/bcrypt"
+    "xo-server/internal/domain"
+)
+
+const (
+    totpPeriod          = 30
+    totpDigits          = 6
+    totpSkew            = 1
+    recoveryCodeCount   = 10
+    challengeTTL        = 5 * time.Minute
+    maxChallengeAttempt = 5
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type loginChallenge struct {
+    userID    uuid.UUID
+    username  string
+    expiresAt time.Time
+    attempts  int
+}
+
+type challengeStore struct {
+    mu         sync.Mutex
+    challenges map[string]*loginChallenge
+}
+
+func newChallengeStore() *challengeStore {
+    return &challengeStore{challenges: make(map[string]*loginChallenge)}
+}
+
+func (s *challengeStore) create(userID uuid.UUID, username string, now time.Time) (string, time.Time, error) {
+    token, err := randomToken()
+    if err != nil {
+        return "", time.Time{}, err
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    for key, c := range s.challenges {
+        if now.After(c.expiresAt) {
+            delete(s.challenges, key)
+        }
+    }
+    expiresAt := now.Add(challengeTTL)
+    s.challenges[hashToken(token)] = &loginChallenge{userID: userID, username: username, expiresAt: expiresAt}
+    return token, expiresAt, nil
+}
+
+func (s *challengeStore) get(token string, now time.Time) (uuid.UUID, string, bool) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    c, ok := s.challenges[hashToken(token)]
+    if !ok || now.After(c.expiresAt) {
+        return uuid.Nil, "", false
+    }
+    return c.userID, c.username, true
+}
+
+func (s *challengeStore) fail(token string) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    key := hashToken(token)
+    if c, ok := s.challenges[key]; ok {
+        c.attempts++
+        if c.attempts >= maxChallengeAttempt {
+            delete(s.challenges, key)
+        }
+    }
+}
+
+func (s *challengeStore) remove(token string) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    delete(s.challenges, hashToken(token))
+}
+
+func (s *authService) VerifyTwoFactor(ctx context.Context, challengeToken, code string) (*domain.TokenPair, *domain.User, error) {
+    if challengeToken == "" || code == "" {
+        return nil, nil, domain.ErrInvalidInput
+    }
+
+    now := time.Now().UTC()
+    userID, username, ok := s.challenges.get(challengeToken, now)
+    if !ok {
+        return nil, nil, domain.ErrUnauthorized
+    }
+    keys := throttleKeys(ctx, username)
+    if err := s.throttle.check(now, keys...); err != nil {
+        recordAudit(ctx, s.audit, domain.AuditLoginFailed, uuid.Nil, userID, "locked out: "+username)
+        return nil, nil, err
+    }
+
+    if err := s.checkSecondFactor(ctx, userID, code); err != nil {
+        s.challenges.fail(challengeToken)
+        s.throttle.fail(now, keys...)
+        recordAudit(ctx, s.audit, domain.AuditLoginFailed, uuid.Nil, userID, "wrong second factor")
+        return nil, nil, err
+    }
+    s.challenges.remove(challengeToken)
+    s.throttle.reset(keys[0])
+
+    user, err := s.users.GetUserByID(ctx, userID)
+    if err != nil {
+        return nil, nil, domain.ErrUnauthorized
+    }
+
+    pair, err := s.issue(ctx, user, uuid.New())
+    if err != nil {
+        return nil, nil, err
+    }
+    recordAudit(ctx, s.audit, domain.AuditLogin, user.ID, user.ID, "password+totp")
+    return pair, user, nil
+}
+
+func (s *authService) EnrollTOTP(ctx context.Context, userID uuid.UUID) (*domain.TOTPEnrollment, error) {
+    user, err := s.users.GetUserByID(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+    if user.IsGuest {
+        return nil, domain.ErrGuestNotAllowed
+    }
+
+    existing, err := s.twoFactor.GetTOTP(ctx, userID)
+    if err == nil && existing.EnabledAt != nil {
+        return nil, domain.ErrTwoFactorActive
+    }
+    if err != nil && err != domain.ErrNotFound {
+        return nil, err
+    }
+
+    raw := make([]byte, 20)
+    if _, err := rand.Read(raw); err != nil {
+        return nil, err
+    }
+    secret := totpEncoding.EncodeToString(raw)
+
+    record := &domain.TOTPSecret{
+        UserID:    userID,
+        Secret:    secret,
+        CreatedAt: time.Now().UTC(),
+    }
+    if err := s.twoFactor.SaveTOTP(ctx, record); err != nil {
+        return nil, err
+    }
+
+    return &domain.TOTPEnrollment{Secret: secret, URI: totpURI(s.opts.TOTPIssuer, user.Username, secret)}, nil
+}
+
+func (s *authService) ActivateTOTP(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+    record, err := s.twoFactor.GetTOTP(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+    if record.EnabledAt != nil {
+        return nil, domain.ErrTwoFactorActive
+    }
+
+    now := time.Now().UTC()
+    step, ok := matchTOTP(record.Secret, code, now, record.LastUsedStep)
+    if !ok {
+        return nil, domain.ErrUnauthorized
+    }
+
+    codes := make([]string, 0, recoveryCodeCount)
+    hashes := make([]string, 0, recoveryCodeCount)
+    for i := 0; i < recoveryCodeCount; i++ {
+        c, err := recoveryCode()
+        if err != nil {
+            return nil, err
+        }
+        codes = append(codes, c)
+        hashes = append(hashes, hashToken(normalizeRecoveryCode(c)))
+    }
+    if err := s.twoFactor.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+        return nil, err
+    }
+
+    record.EnabledAt = &now
+    record.LastUsedStep = step
+    if err := s.twoFactor.SaveTOTP(ctx, record); err != nil {
+        return nil, err
+    }
+    recordAudit(ctx, s.audit, domain.AuditTwoFactorEnabled, userID, userID, "")
+    return codes, nil
+}
+
+func (s *authService) DisableTOTP(ctx context.Context, userID uuid.UUID, password, code string) error {
+    user, err := s.users.GetUserByID(ctx, userID)
+    if err != nil {
+        return err
+    }
+
+    now := time.Now().UTC()
+    keys := throttleKeys(ctx, user.Username)
+    if err := s.throttle.check(now, keys...); err != nil {
+        return err
+    }
+    if user.PasswordHash != "" {
+        if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+            s.throttle.fail(now, keys...)
+            return domain.ErrUnauthorized
+        }
+    }
+    if err := s.checkSecondFactor(ctx, userID, code); err != nil {
+        s.throttle.fail(now, keys...)
+        return err
+    }
+    s.throttle.reset(keys[0])
+
+    if err := s.twoFactor.ReplaceRecoveryCodes(ctx, userID, nil); err != nil {
+        return err
+    }
+    if err := s.twoFactor.DeleteTOTP(ctx, userID); err != nil {
+        return err
+    }
+    recordAudit(ctx, s.audit, domain.AuditTwoFactorDisabled, userID, userID, "")
+    return nil
+}
+
+func (s *authService) twoFactorEnabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+    record, err := s.twoFactor.GetTOTP(ctx, userID)
+    if err == domain.ErrNotFound {
+        return false, nil
+    }
+    if err != nil {
+        return false, err
+    }
+    return record.EnabledAt != nil, nil
+}
+
+func (s *authService) checkSecondFactor(ctx context.Context, userID uuid.UUID, code string) error {
+    record, err := s.twoFactor.GetTOTP(ctx, userID)
+    if err != nil || record.EnabledAt == nil {
+        return domain.ErrUnauthorized
+    }
+
+    now := time.Now().UTC()
+    if step, ok := matchTOTP(record.Secret, code, now, record.LastUsedStep); ok {
+        advanced, err := s.twoFactor.AdvanceTOTPStep(ctx, userID, record.LastUsedStep, step)
+        if err != nil {
+            return err
+        }
+        if !advanced {
+            return domain.ErrUnauthorized
+        }
+        return nil
+    }
+
+    used, err := s.twoFactor.UseRecoveryCode(ctx, userID, hashToken(normalizeRecoveryCode(code)), now)
+    if err != nil {
+        return err
+    }
+    if !used {
+        return domain.ErrUnauthorized
+    }
+    return nil
+}
+
+func matchTOTP(secret, code string, now time.Time, lastUsedStep int64) (int64, bool) {
+    code = strings.TrimSpace(code)
+    if len(code) != totpDigits {
+        return 0, false
+    }
+    key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+    if err != nil {
+        return 0, false
+    }
+
+    current := now.Unix() / totpPeriod
+    for step := current - totpSkew; step <= current+totpSkew; step++ {
+        if step <= lastUsedStep {
+            continue
+        }
+        if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+            return step, true
+        }
+    }
+    return 0, false
+}
+
+func totpCode(key []byte, step int64) string {
+    var msg [8]byte
+    binary.BigEndian.PutUint64(msg[:], uint64(step))
+    mac := hmac.New(sha1.New, key)
+    mac.Write(msg[:])
+    sum := mac.Sum(nil)
+
+    offset := sum[len(sum)-1] & 0x0f
+    value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+    return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+func totpURI(issuer, username, secret string) string {
+    q := url.Values{}
+    q.Set("secret", secret)
+    q.Set("issuer", issuer)
+    q.Set("algorithm", "SHA1")
+    q.Set("digits", fmt.Sprint(totpDigits))
+    q.Set("period", fmt.Sprint(totpPeriod))
+    label := url.PathEscape(issuer + ":" + username)
+    return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+func recoveryCode() (string, error) {
+    raw := make([]byte, 5)
+    if _, err := rand.Read(raw); err != nil {
+        return "", err
+    }
+    code := strings.ToLower(totpEncoding.EncodeToString(raw))
+    return code[:4] + "-" + code[4:], nil
+}
+
+func normalizeRecoveryCode(code string) string {
+    return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
@@ -2,39 +2,724 @@
 
 import (
     "context"
+    "crypto/ed25519"
+    "crypto/rand"
+    "crypto/rsa"
+    "crypto/sha256"
+    "crypto/x509"
+    "encoding/base64"
+    "encoding/json"
+    "encoding/pem"
+    "errors"
+    "fmt"
+    "math/big"
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
     "testing"
+    "time"
 
+    "github.com/golang-jwt/jwt/v5"
     "github.com/google/uuid"
     "xo-server/internal/adapter/auth"
+    "xo-server/internal/adapter/oidc"
     "xo-server/internal/adapter/repo/memory"
     "xo-server/internal/domain"
 )
 
 func TestAuthRegisterAndLogin(t *testing.T) {
     userRepo := memory.NewUserRepo()
-    tokenProvider := auth.NewJWTProvider("secret", 0)
-    svc := NewAuthService(userRepo, tokenProvider)
+    refreshRepo := memory.NewRefreshTokenRepo()
+    tokenProvider := auth.NewJWTProvider("secret", time.Minute, refreshRepo)
+    svc := NewAuthService(userRepo, tokenProvider, refreshRepo, memory.NewPasswordResetRepo(), memory.NewTwoFactorRepo(), nil, &capturedResets{}, nil, AuthOptions{})
 
     user, err := svc.Register(context.Background(), "alice", "password")
     if err != nil {
         t.Fatalf("register error: %v", err)
     }
 
-    token, u2, err := svc.Login(context.Background(), "alice", "password")
+    result, err := svc.Login(context.Background(), "alice", "password")
     if err != nil {
         t.Fatalf("login error: %v", err)
     }
-    if token == "" {
+    if result.Tokens == nil || result.Tokens.AccessToken == "" || result.Tokens.RefreshToken == "" {
         t.Fatalf("empty token")
     }
-    if u2.ID != user.ID {
+    if result.User.ID != user.ID {
         t.Fatalf("expected same user")
     }
 }
 
+type closedSessions []uuid.UUID
+
+func (c *closedSessions) CloseSession(sessionID uuid.UUID) {
+    *c = append(*c, sessionID)
+}
+
+func (c *closedSessions) CloseUser(userID uuid.UUID, reason string) {
+    *c = append(*c, userID)
+}
+
+func TestRefreshRotationAndReuse(t *testing.T) {
+    ctx := context.Background()
+    userRepo := memory.NewUserRepo()
+    refreshRepo := memory.NewRefreshTokenRepo()
+    tokenProvider := auth.NewJWTProvider("secret", time.Minute, refreshRepo)
+    closed := &closedSessions{}
+    svc := NewAuthService(userRepo, tokenProvider, refreshRepo, memory.NewPasswordResetRepo(), memory.NewTwoFactorRepo(), nil, &capturedResets{}, closed, AuthOptions{RefreshTTL: time.Hour})
+
+    if _, err := svc.Register(ctx, "alice", "password"); err != nil {
+        t.Fatalf("register error: %v", err)
+    }
+    login, err := svc.Login(ctx, "alice", "password")
+    if err != nil {
+        t.Fatalf("login error: %v", err)
+    }
+    first := login.Tokens
+
+    second, _, err := svc.Refresh(ctx, first.RefreshToken)
+    if err != nil {
+        t.Fatalf("refresh error: %v", err)
+    }
+    if second.RefreshToken == first.RefreshToken || second.SessionID != first.SessionID {
+        t.Fatalf("expected rotated token in same session")
+    }
+    if _, _, err := tokenProvider.ParseToken(second.AccessToken); err != nil {
+        t.Fatalf("parse error: %v", err)
+    }
+
+    if _, _, err := svc.Refresh(ctx, first.RefreshToken); err != domain.ErrUnauthorized {
+        t.Fatalf("expected reuse to be rejected, got %v", err)
+    }
+    if len(*closed) != 1 || (*closed)[0] != first.SessionID {
+        t.Fatalf("expected session to be closed")
+    }
+    if _, _, err := svc.Refresh(ctx, second.RefreshToken); err != domain.ErrUnauthorized {
+        t.Fatalf("expected family to be revoked, got %v", err)
+    }
+    if _, _, err := tokenProvider.ParseToken(second.AccessToken); err == nil {
+        t.Fatalf("expected revoked access token")
+    }
+
+    login, err = svc.Login(ctx, "alice", "password")
+    if err != nil {
+        t.Fatalf("login error: %v", err)
+    }
+    other := login.Tokens
+    if err := svc.Logout(ctx, login.User.ID, other.SessionID); err != nil {
+        t.Fatalf("logout error: %v", err)
+    }
+    if _, _, err := svc.Refresh(ctx, other.RefreshToken); err != domain.ErrUnauthorized {
+        t.Fatalf("expected logged out session, got %v", err)
+    }
+}
+
+type unlockedAchievements []domain.AchievementCode
+
+func (u *unlockedAchievements) NotifyAchievement(userID uuid.UUID, achievement *domain.UserAchievement) {
+    *u = append(*u, achievement.Code)
+}
+
+type capturedResets struct {
+    tokens []string
+}
+
+func (c *capturedResets) SendPasswordReset(ctx context.Context, user *domain.User, token string, expiresAt time.Time) error {
+    c.tokens = append(c.tokens, token)
+    return nil
+}
+
+func TestPasswordChangeAndReset(t *testing.T) {
+    ctx := context.Background()
+    userRepo := memory.NewUserRepo()
+    refreshRepo := memory.NewRefreshTokenRepo()
+    resets := &capturedResets{}
+    closed := &closedSessions{}
+    svc := NewAuthService(userRepo, auth.NewJWTProvider("secret", time.Minute, refreshRepo), refreshRepo, memory.NewPasswordResetRepo(), memory.NewTwoFactorRepo(), nil, resets, closed, AuthOptions{})
+
+    user, _ := svc.Register(ctx, "alice", "password")
+    current, _ := svc.Login(ctx, "alice", "password")
+    other, _ := svc.Login(ctx, "alice", "password")
+
+    if err := svc.ChangePassword(ctx, user.ID, current.Tokens.SessionID, "wrong", "changed1"); err != domain.ErrUnauthorized {
+        t.Fatalf("expected unauthorized, got %v", err)
+    }
+    if err := svc.ChangePassword(ctx, user.ID, current.Tokens.SessionID, "password", "changed1"); err != nil {
+        t.Fatalf("change error: %v", err)
+    }
+    if len(*closed) != 1 || (*closed)[0] != other.Tokens.SessionID {
+        t.Fatalf("expected only the other session to be closed")
+    }
+    if _, err := svc.Login(ctx, "alice", "changed1"); err != nil {
+        t.Fatalf("login with new password: %v", err)
+    }
+
+    if err := svc.RequestPasswordReset(ctx, "nobody"); err != nil || len(resets.tokens) != 0 {
+        t.Fatalf("expected silent no-op for unknown user")
+    }
+    if err := svc.RequestPasswordReset(ctx, "alice"); err != nil {
+        t.Fatalf("request reset error: %v", err)
+    }
+    if err := svc.RequestPasswordReset(ctx, "alice"); err != nil {
+        t.Fatalf("request reset error: %v", err)
+    }
+    if err := svc.ResetPassword(ctx, resets.tokens[0], "reset123"); err != domain.ErrUnauthorized {
+        t.Fatalf("expected superseded token to fail, got %v", err)
+    }
+    if err := svc.ResetPassword(ctx, resets.tokens[1], "reset123"); err != nil {
+        t.Fatalf("reset error: %v", err)
+    }
+    if err := svc.ResetPassword(ctx, resets.tokens[1], "again123"); err != domain.ErrUnauthorized {
+        t.Fatalf("expected single-use token, got %v", err)
+    }
+    if _, err := svc.Login(ctx, "alice", "reset123"); err != nil {
+        t.Fatalf("login after reset: %v", err)
+    }
+}
+
+func TestLoginLockout(t *testing.T) {
+    ctx := WithClientIP(context.Background(), "10.0.0.1")
+    userRepo := memory.NewUserRepo()
+    refreshRepo := memory.NewRefreshTokenRepo()
+    svc := NewAuthService(userRepo, auth.NewJWTProvider("secret", time.Minute, refreshRepo), refreshRepo, memory.NewPasswordResetRepo(), memory.NewTwoFactorRepo(), nil, &capturedResets{}, nil, AuthOptions{LockoutThreshold: 3, LockoutBase: time.Minute})
+
+    _, _ = svc.Register(ctx, "alice", "password")
+    for i := 0; i < 3; i++ {
+        if _, err := svc.Login(ctx, "alice", "wrong"); err != domain.ErrUnauthorized {
+            t.Fatalf("expected unauthorized, got %v", err)
+        }
+    }
+    if _, err := svc.Login(ctx, "alice", "password"); err != domain.ErrTooManyAttempts {
+        t.Fatalf("expected lockout, got %v", err)
+    }
+    if _, err := svc.Login(ctx, "bob", "password"); err != domain.ErrTooManyAttempts {
+        t.Fatalf("expected ip lockout, got %v", err)
+    }
+    if _, err := svc.Login(WithClientIP(context.Background(), "10.0.0.2"), "alice", "password"); err != domain.ErrTooManyAttempts {
+        t.Fatalf("expected username lockout from another ip, got %v", err)
+    }
+}
+
+func TestLoginThrottleEvictsOldestWhenFull(t *testing.T) {
+    throttle := newLoginThrottle(3, time.Minute, time.Hour)
+    now := time.Now()
+    for i := 0; i < maxThrottleEntries; i++ {
+        throttle.fail(now.Add(time.Duration(i)*time.Millisecond), fmt.Sprintf("user:%d", i))
+    }
+    throttle.fail(now.Add(time.Minute), "user:new")
+    if len(throttle.entries) != maxThrottleEntries {
+        t.Fatalf("expected the table to stay at %d entries, got %d", maxThrottleEntries, len(throttle.entries))
+    }
+    if _, ok := throttle.entries["user:new"]; !ok {
+        t.Fatal("expected the new key to be tracked")
+    }
+    if _, ok := throttle.entries["user:0"]; ok {
+        t.Fatal("expected the oldest key to be evicted")
+    }
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+    ctx := context.Background()
+    userRepo := memory.NewUserRepo()
+    refreshRepo := memory.NewRefreshTokenRepo()
+    svc := NewAuthService(userRepo, auth.NewJWTProvider("secret", time.Minute, refreshRepo), refreshRepo, memory.NewPasswordResetRepo(), memory.NewTwoFactorRepo(), nil, &capturedResets{}, nil, AuthOptions{})
+
+    user, _ := svc.Register(ctx, "alice", "password")
+    enrollment, err := svc.EnrollTOTP(ctx, user.ID)
+    if err != nil {
+        t.Fatalf("enroll error: %v", err)
+    }
+    if !strings.HasPrefix(enrollment.URI, "otpauth://totp/") {
+        t.Fatalf("unexpected uri: %s", enrollment.URI)
+    }
+    key, _ := totpEncoding.DecodeString(enrollment.Secret)
+    step := time.Now().Unix() / totpPeriod
+
+    if _, err := svc.ActivateTOTP(ctx, user.ID, totpCode(key, step+10)); err != domain.ErrUnauthorized {
+        t.Fatalf("expected invalid code to fail, got %v", err)
+    }
+    codes, err := svc.ActivateTOTP(ctx, user.ID, totpCode(key, step))
+    if err != nil || len(codes) != recoveryCodeCount {
+        t.Fatalf("activate error: %v", err)
+    }
+    if _, err := svc.EnrollTOTP(ctx, user.ID); err != domain.ErrTwoFactorActive {
+        t.Fatalf("expected active 2fa, got %v", err)
+    }
+
+    result, err := svc.Login(ctx, "alice", "password")
+    if err != nil || result.Tokens != nil || result.ChallengeToken == "" {
+        t.Fatalf("expected challenge, got %+v %v", result, err)
+    }
+    if _, _, err := svc.VerifyTwoFactor(ctx, result.ChallengeToken, totpCode(key, step)); err != domain.ErrUnauthorized {
+        t.Fatalf("expected replayed code to fail, got %v", err)
+    }
+    pair, _, err := svc.VerifyTwoFactor(ctx, result.ChallengeToken, codes[0])
+    if err != nil || pair.AccessToken == "" {
+        t.Fatalf("recovery code login error: %v", err)
+    }
+    if _, _, err := svc.VerifyTwoFactor(ctx, result.ChallengeToken, codes[1]); err != domain.ErrUnauthorized {
+        t.Fatalf("expected consumed challenge, got %v", err)
+    }
+
+    result, _ = svc.Login(ctx, "alice", "password")
+    if _, _, err := svc.VerifyTwoFactor(ctx, result.ChallengeToken, codes[0]); err != domain.ErrUnauthorized {
+        t.Fatalf("expected used recovery code to fail, got %v", err)
+    }
+    if err := svc.DisableTOTP(ctx, user.ID, "wrong-password", codes[1]); err != domain.ErrUnauthorized {
+        t.Fatalf("expected disabling without the password to fail, got %v", err)
+    }
+    if err := svc.DisableTOTP(ctx, user.ID, "password", codes[1]); err != nil {
+        t.Fatalf("disable error: %v", err)
+    }
+    result, _ = svc.Login(ctx, "alice", "password")
+    if result.Tokens == nil {
+        t.Fatalf("expected direct login after disabling 2fa")
+    }
+}
+
+func TestTwoFactorLockout(t *testing.T) {
+    ctx := WithClientIP(context.Background(), "10.0.0.1")
+    userRepo := memory.NewUserRepo()
+    refreshRepo := memory.NewRefreshTokenRepo()
+    svc := NewAuthService(userRepo, auth.NewJWTProvider("secret", time.Minute, refreshRepo), refreshRepo, memory.NewPasswordResetRepo(), memory.NewTwoFactorRepo(), nil, &capturedResets{}, nil, AuthOptions{LockoutThreshold: 3, LockoutBase: time.Minute})
+
+    user, _ := svc.Register(ctx, "alice", "password")
+    enrollment, _ := svc.EnrollTOTP(ctx, user.ID)
+    key, _ := totpEncoding.DecodeString(enrollment.Secret)
+    step := time.Now().Unix() / totpPeriod
+    if _, err := svc.ActivateTOTP(ctx, user.ID, totpCode(key, step)); err != nil {
+        t.Fatalf("activate error: %v", err)
+    }
+
+    var challenge string
+    for i := 0; i < 3; i++ {
+        result, err := svc.Login(ctx, "alice", "password")
+        if err != nil || result.ChallengeToken == "" {
+            t.Fatalf("expected challenge, got %v", err)
+        }
+        challenge = result.ChallengeToken
+        if _, _, err := svc.VerifyTwoFactor(ctx, challenge, totpCode(key, step+10)); err != domain.ErrUnauthorized {
+            t.Fatalf("expected wrong code to fail, got %v", err)
+        }
+    }
+    if _, err := svc.Login(ctx, "alice", "password"); err != domain.ErrTooManyAttempts {
+        t.Fatalf("expected lockout after second factor failures, got %v", err)
+    }
+    if _, _, err := svc.VerifyTwoFactor(ctx, challenge, totpCode(key, step+1)); err != domain.ErrTooManyAttempts {
+        t.Fatalf("expected locked challenge, got %v", err)
+    }
+    if err := svc.DisableTOTP(ctx, user.ID, "password", totpCode(key, step+1)); err != domain.ErrTooManyAttempts {
+        t.Fatalf("expected disabling to share the lockout, got %v", err)
+    }
+
+    bob, _ := svc.Register(ctx, "bob", "password")
+    enrollment, _ = svc.EnrollTOTP(ctx, bob.ID)
+    key, _ = totpEncoding.DecodeString(enrollment.Secret)
+    if _, err := svc.ActivateTOTP(ctx, bob.ID, totpCode(key, step-1)); err != nil {
+        t.Fatalf("activate error: %v", err)
+    }
+    other := WithClientIP(context.Background(), "10.0.0.2")
+    for i := 0; i < 3; i++ {
+        if err := svc.DisableTOTP(other, bob.ID, "password", totpCode(key, step+10)); err != domain.ErrUnauthorized {
+            t.Fatalf("expected wrong code to fail, got %v", err)
+        }
+    }
+    if err := svc.DisableTOTP(other, bob.ID, "password", totpCode(key, step)); err != domain.ErrTooManyAttempts {
+        t.Fatalf("expected disable guesses to lock the account, got %v", err)
+    }
+}
+
+func TestTOTPStepIsClaimedOnce(t *testing.T) {
+    ctx := context.Background()
+    repo := memory.NewTwoFactorRepo()
+    userID := uuid.New()
+    _ = repo.SaveTOTP(ctx, &domain.TOTPSecret{UserID: userID, Secret: "secret", LastUsedStep: 10})
+
+    if ok, err := repo.AdvanceTOTPStep(ctx, userID, 10, 11); err != nil || !ok {
+        t.Fatalf("expected the first claim to win, got %v (%v)", ok, err)
+    }
+    if ok, _ := repo.AdvanceTOTPStep(ctx, userID, 10, 11); ok {
+        t.Fatal("expected a second claim from the same old step to lose")
+    }
+}
+
+func TestRolesAndPolicy(t *testing.T) {
+    ctx := context.Background()
+    userRepo := memory.NewUserRepo()
+    refreshRepo := memory.NewRefreshTokenRepo()
+    tokenProvider := auth.NewJWTProvider("secret", time.Minute, refreshRepo)
+    closed := &closedSessions{}
+    svc := NewAuthService(userRepo, tokenProvider, refreshRepo, memory.NewPasswordResetRepo(), memory.NewTwoFactorRepo(), nil, &capturedResets{}, closed, AuthOptions{})
+
+    svc.Register(ctx, "alice", "password")
+    bob, _ := svc.Register(ctx, "bob", "password")
+    if err := svc.GrantRole(ctx, "alice", domain.RoleAdmin); err != nil {
+        t.Fatalf("grant error: %v", err)
+    }
+
+    login, _ := svc.Login(ctx, "alice", "password")
+    admin, _, err := tokenProvider.ParseToken(login.Tokens.AccessToken)
+    if err != nil || admin.Role != domain.RoleAdmin {
+        t.Fatalf("expected admin claim, got %v", err)
+    }
+    login, _ = svc.Login(ctx, "bob", "password")
+    player, _, _ := tokenProvider.ParseToken(login.Tokens.AccessToken)
+
+    if err := svc.SetRole(ctx, player, admin.ID, domain.RolePlayer); err != domain.ErrForbidden {
+        t.Fatalf("expected player to be forbidden, got %v", err)
+    }
+    if err := svc.SetRole(ctx, admin, bob.ID, "root"); err != domain.ErrInvalidInput {
+        t.Fatalf("expected unknown role to be rejected, got %v", err)
+    }
+    if err := svc.SetRole(ctx, admin, bob.ID, domain.RoleBot); err != nil {
+        t.Fatalf("set role error: %v", err)
+    }
+    if len(*closed) != 1 || (*closed)[0] != login.Tokens.SessionID {
+        t.Fatalf("expected bob's session to be revoked")
+    }
+
+    updated, _ := userRepo.GetUserByID(ctx, bob.ID)
+    if err := domain.Authorize(updated, domain.PermPlay); err != nil {
+        t.Fatalf("expected bot to play, got %v", err)
+    }
+    if err := domain.Authorize(updated, domain.PermChat); err != domain.ErrForbidden {
+        t.Fatalf("expected bot chat to be forbidden, got %v", err)
+    }
+    if err := domain.Authorize(admin, domain.PermManageGames); err != nil {
+        t.Fatalf("expected admin to manage games, got %v", err)
+    }
+}
+
+func TestAdminModeration(t *testing.T) {
+    ctx := context.Background()
+    userRepo := memory.NewUserRepo()
+    gameRepo := memory.NewGameRepo()
+    refreshRepo := memory.NewRefreshTokenRepo()
+    closed := &closedSessions{}
+    svc := NewAuthService(userRepo, auth.NewJWTProvider("secret", time.Minute, refreshRepo), refreshRepo, memory.NewPasswordResetRepo(), memory.NewTwoFactorRepo(), nil, &capturedResets{}, closed, AuthOptions{})
+    games := NewGameService(gameRepo, nil, nil, nil)
+    reports := NewReportService(memory.NewReportRepo(), userRepo, gameRepo, nil)
+
+    alice, _ := svc.Register(ctx, "alice", "password")
+    bob, _ := svc.Register(ctx, "bob", "password")
+    svc.GrantRole(ctx, "alice", domain.RoleAdmin)
+    admin, _ := userRepo.GetUserByID(ctx, alice.ID)
+
+    game := &domain.Game{
+        ID:       uuid.New(),
+        PlayerX:  alice.ID,
+        PlayerO:  bob.ID,
+        Board:    domain.NewEmptyBoard(),
+        NextTurn: "X",
+        Status:   domain.GameInProgress,
+    }
+    _ = gameRepo.CreateGame(ctx, game)
+
+    if _, err := games.AbortGame(ctx, bob, game.ID, "stuck"); err != domain.ErrForbidden {
+        t.Fatalf("expected player to be forbidden, got %v", err)
+    }
+    if _, err := games.ForceFinish(ctx, admin, game.ID, nil, ""); err != domain.ErrInvalidInput {
+        t.Fatalf("expected missing reason to be rejected, got %v", err)
+    }
+    live, err := games.ListLiveGames(ctx, admin, 0)
+    if err != nil || len(live) != 1 {
+        t.Fatalf("expected one live game, got %d (%v)", len(live), err)
+    }
+    aborted, err := games.AbortGame(ctx, admin, game.ID, "stuck")
+    if err != nil || aborted.Status != domain.GameAborted || aborted.EndReason != "stuck" {
+        t.Fatalf("abort error: %v", err)
+    }
+    adjusted, err := games.AdjustResult(ctx, admin, game.ID, &bob.ID, "replayed offline")
+    if err != nil || adjusted.Status != domain.GameFinished || *adjusted.WinnerUserID != bob.ID {
+        t.Fatalf("adjust error: %v", err)
+    }
+
+    report, err := reports.Report(ctx, alice.ID, bob.ID, &game.ID, "abusive chat")
+    if err != nil {
+        t.Fatalf("report error: %v", err)
+    }
+    if _, err := reports.ListReports(ctx, bob, domain.ReportOpen, 0); err != domain.ErrForbidden {
+        t.Fatalf("expected player to be forbidden, got %v", err)
+    }
+    if err := reports.ResolveReport(ctx, admin, report.ID, "suspended"); err != nil {
+        t.Fatalf("resolve error: %v", err)
+    }
+    if err := reports.ResolveReport(ctx, admin, report.ID, "again"); err != domain.ErrNotFound {
+        t.Fatalf("expected resolved report to be closed, got %v", err)
+    }
+
+    until := time.Now().Add(time.Hour)
+    if err := svc.SuspendUser(ctx, admin, alice.ID, &until, "self"); err != domain.ErrForbidden {
+        t.Fatalf("expected self suspension to be forbidden, got %v", err)
+    }
+    if err := svc.SuspendUser(ctx, admin, bob.ID, &until, "abusive chat"); err != nil {
+        t.Fatalf("suspend error: %v", err)
+    }
+    if len(*closed) != 1 || (*closed)[0] != bob.ID {
+        t.Fatalf("expected bob's connections to be closed")
+    }
+    if _, err := svc.Login(ctx, "bob", "password"); err != domain.ErrAccountSuspended {
+        t.Fatalf("expected suspended login to fail, got %v", err)
+    }
+    if err := svc.LiftSuspension(ctx, admin, bob.ID); err != nil {
+        t.Fatalf("lift error: %v", err)
+    }
+    if _, err := svc.Login(ctx, "bob", "password"); err != nil {
+        t.Fatalf("login after lift error: %v", err)
+    }
+}
+
+func TestAuditTrail(t *testing.T) {
+    ctx := WithClientIP(context.Background(), "10.0.0.9")
+    userRepo := memory.NewUserRepo()
+    refreshRepo := memory.NewRefreshTokenRepo()
+    auditRepo := memory.NewAuditRepo()
+    svc := NewAuthService(userRepo, auth.NewJWTProvider("secret", time.Minute, refreshRepo), refreshRepo, memory.NewPasswordResetRepo(), memory.NewTwoFactorRepo(), auditRepo, &capturedResets{}, nil, AuthOptions{})
+    audit := NewAuditService(auditRepo)
+
+    alice, _ := svc.Register(ctx, "alice", "password")
+    bob, _ := svc.Register(ctx, "bob", "password")
+    svc.GrantRole(ctx, "alice", domain.RoleAdmin)
+    admin, _ := userRepo.GetUserByID(ctx, alice.ID)
+
+    if _, err := svc.Login(ctx, "bob", "wrong-password"); err != domain.ErrUnauthorized {
+        t.Fatalf("expected failed login, got %v", err)
+    }
+    if _, err := svc.Login(ctx, "bob", "password"); err != nil {
+        t.Fatalf("login error: %v", err)
+    }
+    if err := svc.SetRole(ctx, admin, bob.ID, domain.RoleModerator); err != nil {
+        t.Fatalf("set role error: %v", err)
+    }
+
+    if _, err := audit.ListEvents(ctx, bob, domain.AuditFilter{}); err != domain.ErrForbidden {
+        t.Fatalf("expected player to be forbidden, got %v", err)
+    }
+    failed, err := audit.ListEvents(ctx, admin, domain.AuditFilter{Action: domain.AuditLoginFailed})
+    if err != nil || len(failed) != 1 {
+        t.Fatalf("expected one failed login, got %d (%v)", len(failed), err)
+    }
+    if failed[0].IP != "10.0.0.9" || failed[0].TargetID == nil || *failed[0].TargetID != bob.ID {
+        t.Fatalf("unexpected failed login event: %+v", failed[0])
+    }
+
+    events, _ := audit.ListEvents(ctx, admin, domain.AuditFilter{TargetID: &bob.ID})
+    if len(events) != 4 || events[0].Action != domain.AuditRoleChanged || *events[0].ActorID != admin.ID {
+        t.Fatalf("expected register, failed login, login and role change for bob, got %d", len(events))
+    }
+}
+
+func TestJWTKeyRotation(t *testing.T) {
+    keys, err := auth.NewKeySet(auth.KeySetOptions{Algorithm: auth.AlgEdDSA, Dir: t.TempDir()})
+    if err != nil {
+        t.Fatalf("keyset error: %v", err)
+    }
+    provider := auth.NewJWTProviderWithKeys(keys, time.Minute, nil)
+    user := &domain.User{ID: uuid.New(), Username: "alice"}
+
+    before, err := provider.IssueToken(user, uuid.New())
+    if err != nil {
+        t.Fatalf("issue error: %v", err)
+    }
+    if err := keys.Rotate(time.Now().Add(time.Hour)); err != nil {
+        t.Fatalf("rotate error: %v", err)
+    }
+    after, err := provider.IssueToken(user, uuid.New())
+    if err != nil {
+        t.Fatalf("issue error: %v", err)
+    }
+
+    for _, token := range []string{before, after} {
+        if u, _, err := provider.ParseToken(token); err != nil || u.ID != user.ID {
+            t.Fatalf("parse error: %v", err)
+        }
+    }
+    if n := len(keys.JWKS()["keys"].([]map[string]string)); n != 2 {
+        t.Fatalf("expected 2 published keys, got %d", n)
+    }
+
+    forged, _ := auth.NewJWTProvider("secret", time.Minute, nil).IssueToken(user, uuid.New())
+    if _, _, err := provider.ParseToken(forged); err == nil {
+        t.Fatalf("expected HS256 token to be rejected")
+    }
+}
+
+func TestJWTKeyRetirement(t *testing.T) {
+    dir := t.TempDir()
+    keys, err := auth.NewKeySet(auth.KeySetOptions{Algorithm: auth.AlgEdDSA, Dir: dir, TokenTTL: time.Minute})
+    if err != nil {
+        t.Fatalf("keyset error: %v", err)
+    }
+    provider := auth.NewJWTProviderWithKeys(keys, time.Minute, nil)
+    user := &domain.User{ID: uuid.New(), Username: "alice"}
+    signerKid := func() string {
+        token, _ := provider.IssueToken(user, uuid.New())
+        parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+        if err != nil {
+            t.Fatalf("parse error: %v", err)
+        }
+        kid, _ := parsed.Header["kid"].(string)
+        return kid
+    }
+    current := signerKid()
+
+    now := time.Now()
+    for _, at := range []time.Time{now.Add(-3 * time.Hour), now.Add(-3 * time.Hour), now.Add(-2 * time.Hour)} {
+        if err := keys.Rotate(at); err != nil {
+            t.Fatalf("rotate error: %v", err)
+        }
+    }
+    _, operatorKey, _ := ed25519.GenerateKey(rand.Reader)
+    der, _ := x509.MarshalPKCS8PrivateKey(operatorKey)
+    operatorPath := filepath.Join(dir, "prod.key")
+    if err := os.WriteFile(operatorPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+        t.Fatalf("write error: %v", err)
+    }
+    _ = os.Chtimes(operatorPath, now.Add(-time.Hour), now.Add(-time.Hour))
+    if err := keys.Reload(); err != nil {
+        t.Fatalf("reload error: %v", err)
+    }
+
+    if kid := signerKid(); kid != current {
+        t.Fatalf("expected newest key %s to keep signing, got %s", current, kid)
+    }
+    if n := len(keys.JWKS()["keys"].([]map[string]string)); n != 2 {
+        t.Fatalf("expected retired keys to be dropped, got %d published", n)
+    }
+    entries, _ := os.ReadDir(dir)
+    if len(entries) != 2 {
+        t.Fatalf("expected retired generated keys to be removed, got %d files", len(entries))
+    }
+    if _, err := os.Stat(operatorPath); err != nil {
+        t.Fatalf("expected operator key to be kept: %v", err)
+    }
+}
+
+type testIdP struct {
+    server    *httptest.Server
+    key       *rsa.PrivateKey
+    subject   string
+    nonce     string
+    challenge string
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+    key, err := rsa.GenerateKey(rand.Reader, 2048)
+    if err != nil {
+        t.Fatalf("key error: %v", err)
+    }
+    idp := &testIdP{key: key}
+    mux := http.NewServeMux()
+    mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+        _ = json.NewEncoder(w).Encode(map[string]string{
+            "issuer":                 idp.server.URL,
+            "authorization_endpoint": idp.server.URL + "/authorize",
+            "token_endpoint":         idp.server.URL + "/token",
+            "jwks_uri":               idp.server.URL + "/jwks",
+        })
+    })
+    mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+        _ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+            "kid": "idp", "kty": "RSA",
+            "n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+            "e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+        }}})
+    })
+    mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+        sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+        if r.FormValue("code") != "good-code" || base64.RawURLEncoding.EncodeToString(sum[:]) != idp.challenge {
+            w.WriteHeader(http.StatusBadRequest)
+            return
+        }
+        token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+            "iss":                idp.server.URL,
+            "aud":                "xo",
+            "sub":                idp.subject,
+            "exp":                time.Now().Add(time.Minute).Unix(),
+            "nonce":              idp.nonce,
+            "preferred_username": "alice",
+        })
+        token.Header["kid"] = "idp"
+        signed, _ := token.SignedString(key)
+        _ = json.NewEncoder(w).Encode(map[string]string{"id_token": signed})
+    })
+    idp.server = httptest.NewServer(mux)
+    t.Cleanup(idp.server.Close)
+    return idp
+}
+
+func (idp *testIdP) authorize(t *testing.T, authURL, subject string) string {
+    u, err := url.Parse(authURL)
+    if err != nil {
+        t.Fatalf("auth url error: %v", err)
+    }
+    q := u.Query()
+    if q.Get("code_challenge_method") != "S256" {
+        t.Fatalf("expected PKCE S256")
+    }
+    idp.subject = subject
+    idp.nonce = q.Get("nonce")
+    idp.challenge = q.Get("code_challenge")
+    return q.Get("state")
+}
+
+func TestExternalLoginProvisionAndLink(t *testing.T) {
+    ctx := context.Background()
+    idp := newTestIdP(t)
+    userRepo := memory.NewUserRepo()
+    refreshRepo := memory.NewRefreshTokenRepo()
+    authSvc := NewAuthService(userRepo, auth.NewJWTProvider("secret", time.Minute, refreshRepo), refreshRepo, memory.NewPasswordResetRepo(), memory.NewTwoFactorRepo(), nil, &capturedResets{}, nil, AuthOptions{})
+    provider := oidc.NewProvider(oidc.Config{Name: "corp", Issuer: idp.server.URL, ClientID: "xo", RedirectURL: "http://xo/callback"}, nil)
+    svc := NewExternalLoginService([]ExternalIdentityProvider{provider}, memory.NewIdentityRepo(), userRepo, authSvc)
+
+    if _, err := authSvc.Register(ctx, "alice", "password"); err != nil {
+        t.Fatalf("register error: %v", err)
+    }
+
+    authURL, err := svc.Begin(ctx, "corp", uuid.Nil)
+    if err != nil {
+        t.Fatalf("begin error: %v", err)
+    }
+    state := idp.authorize(t, authURL, "sub-1")
+    pair, user, err := svc.Complete(ctx, "corp", state, "good-code")
+    if err != nil {
+        t.Fatalf("complete error: %v", err)
+    }
+    if pair.AccessToken == "" || user.Username != "alice2" {
+        t.Fatalf("expected provisioned alice2, got %q", user.Username)
+    }
+    if _, _, err := svc.Complete(ctx, "corp", state, "good-code"); err != domain.ErrUnauthorized {
+        t.Fatalf("expected state to be single-use, got %v", err)
+    }
+
+    authURL, _ = svc.Begin(ctx, "corp", uuid.Nil)
+    state = idp.authorize(t, authURL, "sub-1")
+    _, again, err := svc.Complete(ctx, "corp", state, "good-code")
+    if err != nil || again.ID != user.ID {
+        t.Fatalf("expected same user on second login: %v", err)
+    }
+
+    bob, _ := authSvc.Register(ctx, "bob", "password")
+    authURL, _ = svc.Begin(ctx, "corp", bob.ID)
+    state = idp.authorize(t, authURL, "sub-1")
+    if _, _, err := svc.Complete(ctx, "corp", state, "good-code"); err != domain.ErrIdentityLinked {
+        t.Fatalf("expected identity linked, got %v", err)
+    }
+    authURL, _ = svc.Begin(ctx, "corp", bob.ID)
+    state = idp.authorize(t, authURL, "sub-2")
+    _, linked, err := svc.Complete(ctx, "corp", state, "good-code")
+    if err != nil || linked.ID != bob.ID {
+        t.Fatalf("expected link to bob: %v", err)
+    }
+}
+
 func TestGameMovesWin(t *testing.T) {
     repo := memory.NewGameRepo()
-    svc := NewGameService(repo)
+    svc := NewGameService(repo, nil, nil, nil)
 
     game := &domain.Game{
         ID:       uuid.New(),
@@ -66,18 +751,894 @@ func TestGameMovesWin(t *testing.T) {
 
 func TestMatchmaking(t *testing.T) {
     repo := memory.NewGameRepo()
-    mm := NewMatchmakingService(repo)
+    mm := NewMatchmakingService(repo, MatchmakingOptions{})
 
-    u1 := uuid.New()
-    u2 := uuid.New()
+    u1 := &domain.User{ID: uuid.New()}
+    u2 := &domain.User{ID: uuid.New()}
 
-    matched, game, err := mm.JoinQueue(u1)
+    matched, game, err := mm.JoinQueue(u1, "")
     if err != nil || matched || game != nil {
         t.Fatalf("expected waiting")
     }
 
-    matched, game, err = mm.JoinQueue(u2)
+    matched, game, err = mm.JoinQueue(u2, "")
     if err != nil || !matched || game == nil {
         t.Fatalf("expected match")
     }
+    if !game.Rated {
+        t.Fatalf("expected rated game")
+    }
+}
+
+func TestGuestAccountUpgrade(t *testing.T) {
+    ctx := context.Background()
+    userRepo := memory.NewUserRepo()
+    refreshRepo := memory.NewRefreshTokenRepo()
+    tokenProvider := auth.NewJWTProvider("secret", time.Minute, refreshRepo)
+    svc := NewAuthService(userRepo, tokenProvider, refreshRepo, memory.NewPasswordResetRepo(), memory.NewTwoFactorRepo(), nil, &capturedResets{}, nil, AuthOptions{})
+    mm := NewMatchmakingService(memory.NewGameRepo(), MatchmakingOptions{})
+
+    pair, guest, err := svc.CreateGuest(ctx)
+    if err != nil || !guest.IsGuest || !strings.HasPrefix(guest.Username, domain.GuestUsernamePrefix) {
+        t.Fatalf("guest error: %v", err)
+    }
+    parsed, sessionID, err := tokenProvider.ParseToken(pair.AccessToken)
+    if err != nil || !parsed.IsGuest {
+        t.Fatalf("expected guest claim, got %v", err)
+    }
+
+    if _, _, err := mm.JoinQueue(parsed, domain.PoolRated); err != domain.ErrGuestNotAllowed {
+        t.Fatalf("expected guest to be kept out of rated pool, got %v", err)
+    }
+    if _, _, err := mm.JoinQueue(parsed, ""); err != nil {
+        t.Fatalf("join error: %v", err)
+    }
+    matched, game, err := mm.JoinQueue(&domain.User{ID: uuid.New()}, domain.PoolCasual)
+    if err != nil || !matched || game.Rated || game.PlayerX != guest.ID {
+        t.Fatalf("expected casual match with guest")
+    }
+
+    if _, err := svc.Register(ctx, "guest-1234", "password"); err != domain.ErrInvalidInput {
+        t.Fatalf("expected reserved prefix to be rejected, got %v", err)
+    }
+    upgraded, user, err := svc.UpgradeGuest(ctx, guest.ID, sessionID, "alice", "password")
+    if err != nil || user.ID != guest.ID || user.IsGuest {
+        t.Fatalf("upgrade error: %v", err)
+    }
+    if parsed, _, _ := tokenProvider.ParseToken(upgraded.AccessToken); parsed.IsGuest || parsed.Username != "alice" {
+        t.Fatalf("expected upgraded claims")
+    }
+    if _, _, err := svc.UpgradeGuest(ctx, guest.ID, sessionID, "alice2", "password"); err != domain.ErrForbidden {
+        t.Fatalf("expected second upgrade to fail, got %v", err)
+    }
+    if result, err := svc.Login(ctx, "alice", "password"); err != nil || result.User.ID != guest.ID {
+        t.Fatalf("login after upgrade: %v", err)
+    }
+}
+
+func TestFriendRequestAcceptAndChallenge(t *testing.T) {
+    ctx := context.Background()
+    userRepo := memory.NewUserRepo()
+    gameRepo := memory.NewGameRepo()
+    svc := NewFriendService(memory.NewFriendRepo(), userRepo, gameRepo, nil)
+
+    alice := &domain.User{ID: uuid.New(), Username: "alice"}
+    bob := &domain.User{ID: uuid.New(), Username: "bob"}
+    _ = userRepo.CreateUser(ctx, alice)
+    _ = userRepo.CreateUser(ctx, bob)
+
+    if _, err := svc.Challenge(ctx, alice.ID, bob.ID); err != domain.ErrNotFriends {
+        t.Fatalf("expected not friends, got %v", err)
+    }
+
+    if _, err := svc.SendRequest(ctx, alice.ID, "bob"); err != nil {
+        t.Fatalf("send request: %v", err)
+    }
+    requests, err := svc.ListRequests(ctx, bob.ID)
+    if err != nil || len(requests) != 1 || !requests[0].Incoming || requests[0].UserID != alice.ID {
+        t.Fatalf("expected incoming request from alice")
+    }
+
+    if _, err := svc.AcceptRequest(ctx, bob.ID, alice.ID); err != nil {
+        t.Fatalf("accept: %v", err)
+    }
+    friends, err := svc.ListFriends(ctx, alice.ID)
+    if err != nil || len(friends) != 1 || friends[0].Username != "bob" {
+        t.Fatalf("expected bob as friend")
+    }
+
+    game, err := svc.Challenge(ctx, alice.ID, bob.ID)
+    if err != nil {
+        t.Fatalf("challenge: %v", err)
+    }
+    if game.PlayerX != alice.ID || game.PlayerO != bob.ID || game.Status != domain.GameInProgress {
+        t.Fatalf("unexpected challenge game")
+    }
+}
+
+func TestAccountExportAndDeletion(t *testing.T) {
+    ctx := context.Background()
+    userRepo := memory.NewUserRepo()
+    gameRepo := memory.NewGameRepo()
+    friendRepo := memory.NewFriendRepo()
+    refreshRepo := memory.NewRefreshTokenRepo()
+    resetRepo := memory.NewPasswordResetRepo()
+    twoFactorRepo := memory.NewTwoFactorRepo()
+    identityRepo := memory.NewIdentityRepo()
+    tokens := auth.NewJWTProvider("secret", time.Minute, refreshRepo)
+    authSvc := NewAuthService(userRepo, tokens, refreshRepo, resetRepo, twoFactorRepo, nil, &capturedResets{}, nil, AuthOptions{})
+    games := NewGameService(gameRepo, nil, nil, nil)
+    friends := NewFriendService(friendRepo, userRepo, gameRepo, nil)
+    accountRepo := memory.NewAccountRepo(userRepo, gameRepo, memory.NewGameEventRepo(), friendRepo, identityRepo, twoFactorRepo, resetRepo, memory.NewSeasonRepo(memory.NewStatsRepo()))
+    accounts := NewAccountService(userRepo, accountRepo, gameRepo, friendRepo, identityRepo, refreshRepo, nil, nil)
+
+    alice, _ := authSvc.Register(ctx, "alice", "password")
+    bob, _ := authSvc.Register(ctx, "bob", "password")
+    friends.SendRequest(ctx, alice.ID, "bob")
+    friends.AcceptRequest(ctx, bob.ID, alice.ID)
+    game, _ := friends.Challenge(ctx, alice.ID, bob.ID)
+    games.MakeMove(ctx, alice.ID, game.ID, 4)
+    games.AddChat(ctx, alice.ID, game.ID, "good luck")
+
+    data, err := accounts.ExportData(ctx, alice.ID)
+    if err != nil {
+        t.Fatalf("export error: %v", err)
+    }
+    if data.User.Username != "alice" || len(data.Games) != 1 || len(data.Moves) != 1 || len(data.Messages) != 1 || len(data.Friendships) != 1 {
+        t.Fatalf("unexpected export: %+v", data)
+    }
+
+    if err := accounts.DeleteAccount(ctx, alice.ID, uuid.Nil, "password"); err != domain.ErrActiveGames {
+        t.Fatalf("expected active games to block deletion, got %v", err)
+    }
+    games.Resign(ctx, bob.ID, game.ID)
+    if err := accounts.DeleteAccount(ctx, alice.ID, uuid.Nil, "wrong-password"); err != domain.ErrUnauthorized {
+        t.Fatalf("expected wrong password to be rejected, got %v", err)
+    }
+    if err := accounts.DeleteAccount(ctx, alice.ID, uuid.Nil, "password"); err != nil {
+        t.Fatalf("delete error: %v", err)
+    }
+
+    deleted, err := userRepo.GetUserByID(ctx, alice.ID)
+    if err != nil || deleted.DeletedAt == nil || deleted.Username == "alice" || deleted.PasswordHash != "" {
+        t.Fatalf("expected anonymized user, got %+v (%v)", deleted, err)
+    }
+    if _, err := authSvc.Login(ctx, "alice", "password"); err != domain.ErrUnauthorized {
+        t.Fatalf("expected deleted user login to fail, got %v", err)
+    }
+    if _, err := authSvc.Register(ctx, "alice", "password"); err != nil {
+        t.Fatalf("expected username to be free again, got %v", err)
+    }
+
+    kept, err := gameRepo.GetGameByID(ctx, game.ID)
+    if err != nil || kept.PlayerX != alice.ID {
+        t.Fatalf("expected historical game to keep the anonymized player")
+    }
+    messages, _ := gameRepo.ListMessagesByUser(ctx, alice.ID)
+    if len(messages) != 1 || messages[0].Message == "good luck" {
+        t.Fatalf("expected chat to be redacted")
+    }
+    if list, _ := friends.ListFriends(ctx, bob.ID); len(list) != 0 {
+        t.Fatalf("expected friendship to be removed")
+    }
+
+    pair, guest, err := authSvc.CreateGuest(ctx)
+    if err != nil {
+        t.Fatalf("guest error: %v", err)
+    }
+    if err := accounts.DeleteAccount(ctx, guest.ID, uuid.Nil, ""); err != domain.ErrReauthRequired {
+        t.Fatalf("expected guest deletion without a session to be refused, got %v", err)
+    }
+    if err := accounts.DeleteAccount(ctx, guest.ID, uuid.New(), ""); err != domain.ErrReauthRequired {
+        t.Fatalf("expected guest deletion from an unknown session to be refused, got %v", err)
+    }
+    stale := uuid.New()
+    _ = refreshRepo.CreateRefreshToken(ctx, &domain.RefreshToken{ID: uuid.New(), FamilyID: stale, UserID: guest.ID, TokenHash: "guest-stale", ExpiresAt: time.Now().Add(time.Hour), CreatedAt: time.Now().Add(-time.Hour)})
+    if err := accounts.DeleteAccount(ctx, guest.ID, stale, ""); err != nil {
+        t.Fatalf("expected a guest to delete from an hour-old session, got %v", err)
+    }
+    if _, _, err := authSvc.Refresh(ctx, pair.RefreshToken); err == nil {
+        t.Fatal("expected the guest's sessions to be revoked after deletion")
+    }
+
+    sso, _ := authSvc.Register(ctx, "sso-user", "password")
+    _ = userRepo.UpdatePassword(ctx, sso.ID, "")
+    fresh, old := uuid.New(), uuid.New()
+    _ = refreshRepo.CreateRefreshToken(ctx, &domain.RefreshToken{ID: uuid.New(), FamilyID: old, UserID: sso.ID, TokenHash: "sso-stale", ExpiresAt: time.Now().Add(time.Hour), CreatedAt: time.Now().Add(-time.Hour)})
+    _ = refreshRepo.CreateRefreshToken(ctx, &domain.RefreshToken{ID: uuid.New(), FamilyID: fresh, UserID: sso.ID, TokenHash: "sso-fresh", ExpiresAt: time.Now().Add(time.Hour), CreatedAt: time.Now()})
+    if err := accounts.DeleteAccount(ctx, sso.ID, old, ""); err != domain.ErrReauthRequired {
+        t.Fatalf("expected passwordless deletion from an old session to be refused, got %v", err)
+    }
+    if err := accounts.DeleteAccount(ctx, sso.ID, fresh, ""); err != nil {
+        t.Fatalf("expected deletion from a fresh session, got %v", err)
+    }
+}
+
+type failingAccountRepo struct{}
+
+func (failingAccountRepo) AnonymizeAccount(ctx context.Context, userID uuid.UUID, username, placeholder string, at time.Time) error {
+    return errors.New("anonymize failed")
+}
+
+func TestAccountDeletionKeepsSessionsWhenAnonymizeFails(t *testing.T) {
+    ctx := context.Background()
+    userRepo := memory.NewUserRepo()
+    gameRepo := memory.NewGameRepo()
+    refreshRepo := memory.NewRefreshTokenRepo()
+    tokens := auth.NewJWTProvider("secret", time.Minute, refreshRepo)
+    authSvc := NewAuthService(userRepo, tokens, refreshRepo, memory.NewPasswordResetRepo(), memory.NewTwoFactorRepo(), nil, &capturedResets{}, nil, AuthOptions{})
+    accounts := NewAccountService(userRepo, failingAccountRepo{}, gameRepo, memory.NewFriendRepo(), memory.NewIdentityRepo(), refreshRepo, nil, nil)
+
+    alice, _ := authSvc.Register(ctx, "alice", "password")
+    login, err := authSvc.Login(ctx, "alice", "password")
+    if err != nil {
+        t.Fatalf("login error: %v", err)
+    }
+    if err := accounts.DeleteAccount(ctx, alice.ID, uuid.Nil, "password"); err == nil {
+        t.Fatal("expected the anonymize failure to be returned")
+    }
+    if _, _, err := authSvc.Refresh(ctx, login.Tokens.RefreshToken); err != nil {
+        t.Fatalf("expected sessions to survive a failed deletion, got %v", err)
+    }
+}
+
+func TestPlayerStatsAndProfile(t *testing.T) {
+    ctx := context.Background()
+    userRepo := memory.NewUserRepo()
+    gameRepo := memory.NewGameRepo()
+    refreshRepo := memory.NewRefreshTokenRepo()
+    authSvc := NewAuthService(userRepo, auth.NewJWTProvider("secret", time.Minute, refreshRepo), refreshRepo, memory.NewPasswordResetRepo(), memory.NewTwoFactorRepo(), nil, &capturedResets{}, nil, AuthOptions{})
+    statsRepo := memory.NewStatsRepo()
+    stats := NewStatsService(statsRepo, userRepo, gameRepo, memory.NewSeasonRepo(statsRepo))
+    games := NewGameService(gameRepo, nil, nil, nil, stats)
+
+    alice, _ := authSvc.Register(ctx, "alice", "password")
+    bob, _ := authSvc.Register(ctx, "bob", "password")
+    authSvc.GrantRole(ctx, "alice", domain.RoleAdmin)
+    admin, _ := userRepo.GetUserByID(ctx, alice.ID)
+
+    var last *domain.Game
+    for i := 0; i < 2; i++ {
+        last = &domain.Game{
+            ID:        uuid.New(),
+            PlayerX:   alice.ID,
+            PlayerO:   bob.ID,
+            Board:     domain.NewEmptyBoard(),
+            NextTurn:  "X",
+            Status:    domain.GameInProgress,
+            Rated:     true,
+            CreatedAt: time.Now().Add(time.Duration(i-2) * time.Minute),
+        }
+        _ = gameRepo.CreateGame(ctx, last)
+        if _, err := games.Resign(ctx, bob.ID, last.ID); err != nil {
+            t.Fatalf("resign error: %v", err)
+        }
+    }
+
+    profile, err := stats.Profile(ctx, "alice", 0)
+    if err != nil {
+        t.Fatalf("profile error: %v", err)
+    }
+    if profile.Stats.Wins != 2 || profile.Stats.GamesAsX != 2 || profile.Stats.CurrentStreak != 2 || len(profile.RecentGames) != 2 {
+        t.Fatalf("unexpected stats: %+v", profile.Stats)
+    }
+    if len(profile.Ratings) != 1 || profile.Ratings[0].Rating <= domain.DefaultRating || profile.Ratings[0].Games != 2 {
+        t.Fatalf("expected alice's rating to rise, got %+v", profile.Ratings)
+    }
+
+    if _, err := games.AdjustResult(ctx, admin, last.ID, &bob.ID, "misclick"); err != nil {
+        t.Fatalf("adjust error: %v", err)
+    }
+    profile, _ = stats.Profile(ctx, "alice", 0)
+    if profile.Stats.Wins != 1 || profile.Stats.Losses != 1 || profile.Stats.CurrentStreak != 0 || profile.Stats.BestStreak != 1 {
+        t.Fatalf("expected stats to be rebuilt, got %+v", profile.Stats)
+    }
+    bobProfile, _ := stats.Profile(ctx, "bob", 0)
+    if profile.Ratings[0].Games != 2 || profile.Ratings[0].Rating+bobProfile.Ratings[0].Rating != 2*domain.DefaultRating {
+        t.Fatalf("expected adjusted ratings to stay balanced, got %+v / %+v", profile.Ratings[0], bobProfile.Ratings[0])
+    }
+    if _, err := stats.Profile(ctx, "nobody", 0); err != domain.ErrNotFound {
+        t.Fatalf("expected unknown profile to be missing, got %v", err)
+    }
+
+    reverse := &domain.Game{
+        ID:       uuid.New(),
+        PlayerX:  bob.ID,
+        PlayerO:  alice.ID,
+        Board:    domain.NewEmptyBoard(),
+        NextTurn: "X",
+        Status:   domain.GameInProgress,
+    }
+    _ = gameRepo.CreateGame(ctx, reverse)
+    games.OfferDraw(ctx, bob.ID, reverse.ID)
+    games.AcceptDraw(ctx, alice.ID, reverse.ID)
+
+    h2h, err := stats.HeadToHead(ctx, "alice", "bob", 2)
+    if err != nil {
+        t.Fatalf("head to head error: %v", err)
+    }
+    if h2h.Total.Wins != 1 || h2h.Total.Losses != 1 || h2h.Total.Draws != 1 || h2h.AsX.Games() != 2 || h2h.AsO.Draws != 1 || len(h2h.Games) != 2 {
+        t.Fatalf("unexpected head to head: %+v", h2h)
+    }
+    if _, err := stats.HeadToHead(ctx, "alice", "alice", 0); err != domain.ErrInvalidInput {
+        t.Fatalf("expected self head to head to be rejected, got %v", err)
+    }
+}
+
+func TestAdjustedResultRecomputesRatingsAcrossSoftReset(t *testing.T) {
+    ctx := context.Background()
+    userRepo := memory.NewUserRepo()
+    gameRepo := memory.NewGameRepo()
+    statsRepo := memory.NewStatsRepo()
+    seasonRepo := memory.NewSeasonRepo(statsRepo)
+    refreshRepo := memory.NewRefreshTokenRepo()
+    authSvc := NewAuthService(userRepo, auth.NewJWTProvider("secret", time.Minute, refreshRepo), refreshRepo, memory.NewPasswordResetRepo(), memory.NewTwoFactorRepo(), nil, &capturedResets{}, nil, AuthOptions{})
+    games := NewGameService(gameRepo, nil, nil, nil, NewStatsService(statsRepo, userRepo, gameRepo, seasonRepo))
+
+    alice, _ := authSvc.Register(ctx, "alice", "password")
+    bob, _ := authSvc.Register(ctx, "bob", "password")
+    authSvc.GrantRole(ctx, "alice", domain.RoleAdmin)
+    admin, _ := userRepo.GetUserByID(ctx, alice.ID)
+    play := func(createdAt time.Time) *domain.Game {
+        game := &domain.Game{
+            ID:        uuid.New(),
+            PlayerX:   alice.ID,
+            PlayerO:   bob.ID,
+            Board:     domain.NewEmptyBoard(),
+            NextTurn:  "X",
+            Status:    domain.GameInProgress,
+            Rated:     true,
+            CreatedAt: createdAt,
+        }
+        _ = gameRepo.CreateGame(ctx, game)
+        if _, err := games.Resign(ctx, bob.ID, game.ID); err != nil {
+            t.Fatalf("resign error: %v", err)
+        }
+        return game
+    }
+    ratingOf := func(userID uuid.UUID) *domain.Rating {
+        rating, err := statsRepo.GetRating(ctx, userID, domain.VariantStandard, domain.TimeControlUntimed)
+        if err != nil {
+            t.Fatalf("rating error: %v", err)
+        }
+        return rating
+    }
+
+    now := time.Now().UTC()
+    before := play(now.Add(-2 * time.Hour))
+    if err := seasonRepo.OpenSeason(ctx, &domain.Season{ID: uuid.New(), Name: "S1", Status: domain.SeasonOpen, StartsAt: now.Add(-time.Hour)}); err != nil {
+        t.Fatalf("open season: %v", err)
+    }
+    if r := ratingOf(alice.ID); r.Rating != domain.DefaultRating {
+        t.Fatalf("expected the reset to bring alice back to %d, got %d", domain.DefaultRating, r.Rating)
+    }
+    play(now)
+
+    if _, err := games.AdjustResult(ctx, admin, before.ID, nil, "misclick"); err != nil {
+        t.Fatalf("adjust error: %v", err)
+    }
+    x, o := domain.EloRatings(domain.DefaultRating, domain.DefaultRating, 1)
+    if a, b := ratingOf(alice.ID), ratingOf(bob.ID); a.Rating != x || b.Rating != o || a.Games != 2 || b.Games != 2 {
+        t.Fatalf("expected only the game after the reset to count, got alice %+v and bob %+v", a, b)
+    }
+    stats, _ := statsRepo.GetPlayerStats(ctx, alice.ID)
+    if stats.Wins != 1 || stats.Draws != 1 || stats.CurrentStreak != 1 {
+        t.Fatalf("expected stats rebuilt in play order, got %+v", stats)
+    }
+}
+
+func TestLeaderboards(t *testing.T) {
+    ctx := context.Background()
+    userRepo := memory.NewUserRepo()
+    gameRepo := memory.NewGameRepo()
+    statsRepo := memory.NewStatsRepo()
+    refreshRepo := memory.NewRefreshTokenRepo()
+    authSvc := NewAuthService(userRepo, auth.NewJWTProvider("secret", time.Minute, refreshRepo), refreshRepo, memory.NewPasswordResetRepo(), memory.NewTwoFactorRepo(), nil, &capturedResets{}, nil, AuthOptions{})
+    boards := NewLeaderboardService(statsRepo, userRepo, gameRepo, memory.NewSeasonRepo(statsRepo), LeaderboardOptions{MinGamesAllTime: 2, MinGamesMonthly: 1, MinGamesWeekly: 1})
+    games := NewGameService(gameRepo, nil, nil, nil, NewStatsService(statsRepo, userRepo, gameRepo, memory.NewSeasonRepo(statsRepo)), boards)
+
+    alice, _ := authSvc.Register(ctx, "alice", "password")
+    bob, _ := authSvc.Register(ctx, "bob", "password")
+    carol, _ := authSvc.Register(ctx, "carol", "password")
+    play := func(winner, loser uuid.UUID, rated bool) {
+        game := &domain.Game{
+            ID:       uuid.New(),
+            PlayerX:  winner,
+            PlayerO:  loser,
+            Board:    domain.NewEmptyBoard(),
+            NextTurn: "X",
+            Status:   domain.GameInProgress,
+            Rated:    rated,
+        }
+        _ = gameRepo.CreateGame(ctx, game)
+        if _, err := games.Resign(ctx, loser, game.ID); err != nil {
+            t.Fatalf("resign error: %v", err)
+        }
+    }
+    play(alice.ID, bob.ID, true)
+    play(carol.ID, bob.ID, true)
+    play(carol.ID, alice.ID, false)
+
+    board, err := boards.Leaderboard(ctx, domain.LeaderboardQuery{})
+    if err != nil {
+        t.Fatalf("leaderboard error: %v", err)
+    }
+    if board.Total != 1 || board.Entries[0].Username != "bob" {
+        t.Fatalf("expected only bob to reach the all-time minimum, got %+v", board.Entries)
+    }
+
+    play(alice.ID, bob.ID, true)
+    board, _ = boards.Leaderboard(ctx, domain.LeaderboardQuery{})
+    if board.Total != 2 || board.Entries[0].Username != "alice" || board.Entries[1].Username != "bob" {
+        t.Fatalf("expected the cache to refresh after a rated game, got %+v", board.Entries)
+    }
+
+    monthly, err := boards.Leaderboard(ctx, domain.LeaderboardQuery{Period: domain.PeriodMonthly, Limit: 1, Offset: 1})
+    if err != nil {
+        t.Fatalf("monthly error: %v", err)
+    }
+    if monthly.Total != 3 || len(monthly.Entries) != 1 || monthly.Entries[0].Username != "carol" || monthly.Entries[0].Rank != 2 || monthly.Entries[0].Games != 1 {
+        t.Fatalf("unexpected monthly page: %+v", monthly.Entries)
+    }
+    if _, err := boards.Leaderboard(ctx, domain.LeaderboardQuery{Variant: "Gomoku!"}); err != domain.ErrInvalidInput {
+        t.Fatalf("expected malformed variant to be rejected, got %v", err)
+    }
+    if _, err := boards.Leaderboard(ctx, domain.LeaderboardQuery{Period: "daily"}); err != domain.ErrInvalidInput {
+        t.Fatalf("expected unknown period to be rejected, got %v", err)
+    }
+}
+
+func TestLeaderboardVariants(t *testing.T) {
+    ctx := context.Background()
+    userRepo := memory.NewUserRepo()
+    gameRepo := memory.NewGameRepo()
+    statsRepo := memory.NewStatsRepo()
+    refreshRepo := memory.NewRefreshTokenRepo()
+    authSvc := NewAuthService(userRepo, auth.NewJWTProvider("secret", time.Minute, refreshRepo), refreshRepo, memory.NewPasswordResetRepo(), memory.NewTwoFactorRepo(), nil, &capturedResets{}, nil, AuthOptions{})
+    boards := NewLeaderboardService(statsRepo, userRepo, gameRepo, memory.NewSeasonRepo(statsRepo), LeaderboardOptions{MinGamesAllTime: 1, MinGamesMonthly: 1, MinGamesWeekly: 1})
+    games := NewGameService(gameRepo, nil, nil, nil, NewStatsService(statsRepo, userRepo, gameRepo, memory.NewSeasonRepo(statsRepo)), boards)
+
+    alice, _ := authSvc.Register(ctx, "alice", "password")
+    bob, _ := authSvc.Register(ctx, "bob", "password")
+    carol, _ := authSvc.Register(ctx, "carol", "password")
+    play := func(winner, loser uuid.UUID, variant, timeControl string) {
+        game := &domain.Game{
+            ID:          uuid.New(),
+            PlayerX:     winner,
+            PlayerO:     loser,
+            Board:       domain.NewEmptyBoard(),
+            NextTurn:    "X",
+            Status:      domain.GameInProgress,
+            Rated:       true,
+            Variant:     variant,
+            TimeControl: timeControl,
+        }
+        _ = gameRepo.CreateGame(ctx, game)
+        if _, err := games.Resign(ctx, loser, game.ID); err != nil {
+            t.Fatalf("resign error: %v", err)
+        }
+    }
+    play(alice.ID, bob.ID, "", "")
+    play(carol.ID, bob.ID, "misere", "blitz")
+
+    blitz := domain.LeaderboardQuery{Variant: "misere", TimeControl: "blitz"}
+    board, err := boards.Leaderboard(ctx, blitz)
+    if err != nil {
+        t.Fatalf("leaderboard error: %v", err)
+    }
+    if board.Variant != "misere" || board.TimeControl != "blitz" || board.Total != 2 || board.Entries[0].Username != "carol" || board.Entries[1].Username != "bob" {
+        t.Fatalf("expected only the misere blitz players, got %+v", board.Entries)
+    }
+    standard, _ := boards.Leaderboard(ctx, domain.LeaderboardQuery{})
+    if standard.Total != 2 || standard.Entries[0].Username != "alice" || standard.Entries[1].Username != "bob" {
+        t.Fatalf("expected the standard board to ignore misere games, got %+v", standard.Entries)
+    }
+
+    play(alice.ID, carol.ID, "misere", "blitz")
+    board, _ = boards.Leaderboard(ctx, blitz)
+    if board.Total != 3 {
+        t.Fatalf("expected a misere game to refresh the misere board, got %+v", board.Entries)
+    }
+    monthly, _ := boards.Leaderboard(ctx, domain.LeaderboardQuery{Variant: "misere", TimeControl: "blitz", Period: domain.PeriodMonthly})
+    if monthly.Total != 3 {
+        t.Fatalf("expected the monthly board to count only misere blitz games, got %+v", monthly.Entries)
+    }
+    for _, e := range monthly.Entries {
+        if e.Username == "bob" && e.Games != 1 {
+            t.Fatalf("expected bob's standard game to be left out, got %+v", e)
+        }
+    }
+    if untimed, _ := boards.Leaderboard(ctx, domain.LeaderboardQuery{Variant: "misere"}); untimed.Total != 0 {
+        t.Fatalf("expected misere untimed to be its own pool, got %+v", untimed.Entries)
+    }
+
+    rating, err := statsRepo.GetRating(ctx, alice.ID, "misere", "blitz")
+    if err != nil || rating.Games != 1 || rating.Rating <= domain.DefaultRating {
+        t.Fatalf("expected alice to have a misere blitz rating, got %+v (%v)", rating, err)
+    }
+    if rating, _ := statsRepo.GetRating(ctx, alice.ID, domain.VariantStandard, domain.TimeControlUntimed); rating.Games != 1 {
+        t.Fatalf("expected the misere win to leave the standard rating alone, got %+v", rating)
+    }
+}
+
+func TestAchievements(t *testing.T) {
+    ctx := context.Background()
+    userRepo := memory.NewUserRepo()
+    gameRepo := memory.NewGameRepo()
+    statsRepo := memory.NewStatsRepo()
+    refreshRepo := memory.NewRefreshTokenRepo()
+    authSvc := NewAuthService(userRepo, auth.NewJWTProvider("secret", time.Minute, refreshRepo), refreshRepo, memory.NewPasswordResetRepo(), memory.NewTwoFactorRepo(), nil, &capturedResets{}, nil, AuthOptions{})
+    unlocked := &unlockedAchievements{}
+    badges := NewAchievementService(memory.NewAchievementRepo(), userRepo, gameRepo, unlocked)
+    games := NewGameService(gameRepo, nil, nil, nil, NewStatsService(statsRepo, userRepo, gameRepo, memory.NewSeasonRepo(statsRepo), badges))
+
+    alice, _ := authSvc.Register(ctx, "alice", "password")
+    bob, _ := authSvc.Register(ctx, "bob", "password")
+    bot, _ := authSvc.Register(ctx, "botty", "password")
+    authSvc.GrantRole(ctx, "botty", domain.RoleBot)
+    newGame := func(x, o uuid.UUID) *domain.Game {
+        game := &domain.Game{
+            ID:       uuid.New(),
+            PlayerX:  x,
+            PlayerO:  o,
+            Board:    domain.NewEmptyBoard(),
+            NextTurn: "X",
+            Status:   domain.GameInProgress,
+        }
+        _ = gameRepo.CreateGame(ctx, game)
+        return game
+    }
+
+    fork := newGame(alice.ID, bob.ID)
+    for i, pos := range []int{0, 1, 4, 8, 6, 3, 2} {
+        player := alice.ID
+        if i%2 == 1 {
+            player = bob.ID
+        }
+        if _, err := games.MakeMove(ctx, player, fork.ID, pos); err != nil {
+            t.Fatalf("move %d error: %v", pos, err)
+        }
+    }
+    want := []domain.AchievementCode{domain.AchievementFirstGame, domain.AchievementFirstWin, domain.AchievementForkWin, domain.AchievementFirstGame}
+    if len(*unlocked) != len(want) {
+        t.Fatalf("expected %v, got %v", want, *unlocked)
+    }
+    for i, code := range want {
+        if (*unlocked)[i] != code {
+            t.Fatalf("expected %v, got %v", want, *unlocked)
+        }
+    }
+
+    draw := newGame(alice.ID, bot.ID)
+    games.OfferDraw(ctx, alice.ID, draw.ID)
+    games.AcceptDraw(ctx, bot.ID, draw.ID)
+    if len(*unlocked) != 6 || (*unlocked)[4] != domain.AchievementBotDraw {
+        t.Fatalf("expected a bot draw badge, got %v", *unlocked)
+    }
+
+    list, err := badges.ListAchievements(ctx, "alice")
+    if err != nil || len(list) != 4 {
+        t.Fatalf("expected alice to hold four badges, got %d (%v)", len(list), err)
+    }
+}
+
+func TestForkAchievementNeedsTwoNewThreats(t *testing.T) {
+    ctx := context.Background()
+    userRepo := memory.NewUserRepo()
+    gameRepo := memory.NewGameRepo()
+    statsRepo := memory.NewStatsRepo()
+    refreshRepo := memory.NewRefreshTokenRepo()
+    authSvc := NewAuthService(userRepo, auth.NewJWTProvider("secret", time.Minute, refreshRepo), refreshRepo, memory.NewPasswordResetRepo(), memory.NewTwoFactorRepo(), nil, &capturedResets{}, nil, AuthOptions{})
+    unlocked := &unlockedAchievements{}
+    badges := NewAchievementService(memory.NewAchievementRepo(), userRepo, gameRepo, unlocked)
+    games := NewGameService(gameRepo, nil, nil, nil, NewStatsService(statsRepo, userRepo, gameRepo, memory.NewSeasonRepo(statsRepo), badges))
+
+    alice, _ := authSvc.Register(ctx, "alice", "password")
+    bob, _ := authSvc.Register(ctx, "bob", "password")
+    game := &domain.Game{
+        ID:       uuid.New(),
+        PlayerX:  alice.ID,
+        PlayerO:  bob.ID,
+        Board:    domain.NewEmptyBoard(),
+        NextTurn: "X",
+        Status:   domain.GameInProgress,
+    }
+    _ = gameRepo.CreateGame(ctx, game)
+
+    for i, pos := range []int{0, 3, 1, 5, 8, 7, 2} {
+        player := alice.ID
+        if i%2 == 1 {
+            player = bob.ID
+        }
+        if _, err := games.MakeMove(ctx, player, game.ID, pos); err != nil {
+            t.Fatalf("move %d error: %v", pos, err)
+        }
+    }
+    for _, code := range *unlocked {
+        if code == domain.AchievementForkWin {
+            t.Fatalf("expected a pre-existing threat not to count toward a fork, got %v", *unlocked)
+        }
+    }
+    if len(*unlocked) != 3 {
+        t.Fatalf("expected the game badges only, got %v", *unlocked)
+    }
+}
+
+func TestStreakAchievementUsesRecordedStats(t *testing.T) {
+    ctx := context.Background()
+    userRepo := memory.NewUserRepo()
+    gameRepo := memory.NewGameRepo()
+    statsRepo := memory.NewStatsRepo()
+    refreshRepo := memory.NewRefreshTokenRepo()
+    authSvc := NewAuthService(userRepo, auth.NewJWTProvider("secret", time.Minute, refreshRepo), refreshRepo, memory.NewPasswordResetRepo(), memory.NewTwoFactorRepo(), nil, &capturedResets{}, nil, AuthOptions{})
+    unlocked := &unlockedAchievements{}
+    badges := NewAchievementService(memory.NewAchievementRepo(), userRepo, gameRepo, unlocked)
+    boards := NewLeaderboardService(statsRepo, userRepo, gameRepo, memory.NewSeasonRepo(statsRepo), LeaderboardOptions{})
+    games := NewGameService(gameRepo, nil, nil, nil, boards, NewStatsService(statsRepo, userRepo, gameRepo, memory.NewSeasonRepo(statsRepo), badges))
+
+    alice, _ := authSvc.Register(ctx, "alice", "password")
+    bob, _ := authSvc.Register(ctx, "bob", "password")
+    for i := 0; i < 3; i++ {
+        game := &domain.Game{
+            ID:       uuid.New(),
+            PlayerX:  alice.ID,
+            PlayerO:  bob.ID,
+            Board:    domain.NewEmptyBoard(),
+            NextTurn: "X",
+            Status:   domain.GameInProgress,
+        }
+        _ = gameRepo.CreateGame(ctx, game)
+        for j, pos := range []int{0, 3, 1, 4, 2} {
+            player := alice.ID
+            if j%2 == 1 {
+                player = bob.ID
+            }
+            if _, err := games.MakeMove(ctx, player, game.ID, pos); err != nil {
+                t.Fatalf("game %d move %d error: %v", i, pos, err)
+            }
+        }
+    }
+
+    for _, code := range *unlocked {
+        if code == domain.AchievementStreak3 {
+            return
+        }
+    }
+    t.Fatalf("expected a streak badge after three wins, got %v", *unlocked)
+}
+
+func TestSeasons(t *testing.T) {
+    ctx := context.Background()
+    userRepo := memory.NewUserRepo()
+    gameRepo := memory.NewGameRepo()
+    statsRepo := memory.NewStatsRepo()
+    seasonRepo := memory.NewSeasonRepo(statsRepo)
+    refreshRepo := memory.NewRefreshTokenRepo()
+    authSvc := NewAuthService(userRepo, auth.NewJWTProvider("secret", time.Minute, refreshRepo), refreshRepo, memory.NewPasswordResetRepo(), memory.NewTwoFactorRepo(), nil, &capturedResets{}, nil, AuthOptions{})
+    boards := NewLeaderboardService(statsRepo, userRepo, gameRepo, seasonRepo, LeaderboardOptions{MinGamesSeason: 1})
+    games := NewGameService(gameRepo, nil, nil, nil, NewStatsService(statsRepo, userRepo, gameRepo, seasonRepo), boards)
+    seasons := NewSeasonService(seasonRepo, boards, nil, SeasonOptions{
+        Schedule:  domain.ScheduleManual,
+        CarryOver: 0.5,
+        Rewards:   []domain.SeasonReward{{Top: 3, Reward: "podium"}, {Top: 1, Reward: "champion"}},
+    })
+
+    alice, _ := authSvc.Register(ctx, "alice", "password")
+    bob, _ := authSvc.Register(ctx, "bob", "password")
+    carol, _ := authSvc.Register(ctx, "carol", "password")
+    authSvc.GrantRole(ctx, "alice", domain.RoleAdmin)
+    admin, _ := userRepo.GetUserByID(ctx, alice.ID)
+    player, _ := userRepo.GetUserByID(ctx, bob.ID)
+    play := func(winner, loser uuid.UUID) {
+        game := &domain.Game{
+            ID:       uuid.New(),
+            PlayerX:  winner,
+            PlayerO:  loser,
+            Board:    domain.NewEmptyBoard(),
+            NextTurn: "X",
+            Status:   domain.GameInProgress,
+            Rated:    true,
+        }
+        _ = gameRepo.CreateGame(ctx, game)
+        if _, err := games.Resign(ctx, loser, game.ID); err != nil {
+            t.Fatalf("resign error: %v", err)
+        }
+    }
+    play(alice.ID, bob.ID)
+
+    if _, err := seasons.OpenSeason(ctx, player, "S1"); err != domain.ErrForbidden {
+        t.Fatalf("expected player to be forbidden, got %v", err)
+    }
+    season, err := seasons.OpenSeason(ctx, admin, "S1")
+    if err != nil {
+        t.Fatalf("open error: %v", err)
+    }
+    if _, err := seasons.OpenSeason(ctx, admin, "S2"); err != domain.ErrSeasonOpen {
+        t.Fatalf("expected a second open season to be rejected, got %v", err)
+    }
+    rating, _ := statsRepo.GetRating(ctx, alice.ID, domain.VariantStandard, domain.TimeControlUntimed)
+    if rating.Rating != 1508 {
+        t.Fatalf("expected a soft reset to 1508, got %d", rating.Rating)
+    }
+
+    play(carol.ID, bob.ID)
+    live, err := seasons.Standings(ctx, season.ID, 0, 0)
+    if err != nil || live.Total != 2 || live.Entries[0].Username != "carol" || live.Entries[0].Reward != "champion" {
+        t.Fatalf("unexpected live standings: %+v (%v)", live, err)
+    }
+
+    late := time.Now().UTC()
+    _ = gameRepo.CreateGame(ctx, &domain.Game{ID: uuid.New(), PlayerX: carol.ID, PlayerO: alice.ID, Board: domain.NewEmptyBoard(), Status: domain.GameFinished, WinnerUserID: &carol.ID, Rated: true, CreatedAt: late, UpdatedAt: late})
+    if _, err := seasons.CloseSeason(ctx, admin); err != nil {
+        t.Fatalf("close error: %v", err)
+    }
+    archived, err := seasons.Standings(ctx, season.ID, 0, 0)
+    if err != nil || archived.Season.Status != domain.SeasonClosed || archived.Total != 3 || archived.Entries[2].Username != "bob" || archived.Entries[2].Reward != "podium" {
+        t.Fatalf("unexpected archived standings: %+v (%v)", archived, err)
+    }
+    if _, err := boards.Leaderboard(ctx, domain.LeaderboardQuery{Period: domain.PeriodSeason}); err != domain.ErrNotFound {
+        t.Fatalf("expected no season leaderboard between seasons, got %v", err)
+    }
+
+    friendRepo, identityRepo := memory.NewFriendRepo(), memory.NewIdentityRepo()
+    accountRepo := memory.NewAccountRepo(userRepo, gameRepo, memory.NewGameEventRepo(), friendRepo, identityRepo, memory.NewTwoFactorRepo(), memory.NewPasswordResetRepo(), seasonRepo)
+    accounts := NewAccountService(userRepo, accountRepo, gameRepo, friendRepo, identityRepo, refreshRepo, nil, nil)
+    if err := accounts.DeleteAccount(ctx, bob.ID, uuid.Nil, "password"); err != nil {
+        t.Fatalf("delete error: %v", err)
+    }
+    archived, _ = seasons.Standings(ctx, season.ID, 0, 0)
+    if archived.Entries[2].UserID != bob.ID || archived.Entries[2].Username == "bob" || !strings.HasPrefix(archived.Entries[2].Username, domain.DeletedUsernamePrefix) {
+        t.Fatalf("expected the archived standing to be anonymized, got %+v", archived.Entries[2])
+    }
+
+    start := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+    NewSeasonService(seasonRepo, boards, nil, SeasonOptions{Schedule: domain.ScheduleMonthly, CarryOver: 0.5}).(*seasonService).tick(ctx, start)
+    if _, err := seasons.Current(ctx); err != domain.ErrNotFound {
+        t.Fatalf("expected the scheduler to respect a manual close, got %v", err)
+    }
+
+    scheduledRepo := memory.NewSeasonRepo(statsRepo)
+    scheduledBoards := NewLeaderboardService(statsRepo, userRepo, gameRepo, scheduledRepo, LeaderboardOptions{})
+    scheduled := NewSeasonService(scheduledRepo, scheduledBoards, nil, SeasonOptions{Schedule: domain.ScheduleMonthly, CarryOver: 0.5}).(*seasonService)
+    scheduled.tick(ctx, start)
+    current, err := scheduled.Current(ctx)
+    if err != nil || current.Name != "2026-10" || !current.EndsAt.Equal(time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)) {
+        t.Fatalf("unexpected scheduled season: %+v (%v)", current, err)
+    }
+    scheduled.tick(ctx, current.EndsAt.Add(time.Minute))
+    next, _ := scheduled.Current(ctx)
+    if next.Name != "2026-11" {
+        t.Fatalf("expected the schedule to roll over, got %q", next.Name)
+    }
+    if closed, _ := scheduled.seasons.GetSeason(ctx, current.ID); closed.Status != domain.SeasonClosed || closed.ClosedAt == nil || !closed.ClosedAt.Equal(next.StartsAt) {
+        t.Fatalf("expected the previous season to be closed at the rollover, got %+v", closed)
+    }
+    if _, err := scheduled.CloseSeason(ctx, admin); err != nil {
+        t.Fatalf("close error: %v", err)
+    }
+    scheduled.tick(ctx, next.StartsAt.Add(time.Hour))
+    if _, err := scheduled.Current(ctx); err != domain.ErrNotFound {
+        t.Fatalf("expected a manually closed season to stay closed until its end, got %v", err)
+    }
+    scheduled.tick(ctx, next.EndsAt.Add(time.Minute))
+    if reopened, err := scheduled.Current(ctx); err != nil || reopened.Name != "2026-12" {
+        t.Fatalf("expected the next season to open once the closed one ended, got %+v (%v)", reopened, err)
+    }
+    if list, _ := scheduled.ListSeasons(ctx); len(list) != 3 {
+        t.Fatalf("expected three seasons, got %d", len(list))
+    }
+}
+
+type recordedEvents struct {
+    mu   sync.Mutex
+    seqs []int64
+}
+
+func (r *recordedEvents) EncodeGameEvent(eventType string, game *domain.Game, msg *domain.GameMessage) []byte {
+    return []byte(`{}`)
+}
+
+func (r *recordedEvents) PublishGameEvent(game *domain.Game, event *domain.GameEvent) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    r.seqs = append(r.seqs, event.Seq)
+}
+
+func TestGameEventReplayAfterSeq(t *testing.T) {
+    ctx := context.Background()
+    gameRepo := memory.NewGameRepo()
+    eventRepo := memory.NewGameEventRepo()
+    published := &recordedEvents{}
+    games := NewGameService(gameRepo, eventRepo, published, nil)
+    svc := NewGameEventService(eventRepo, gameRepo)
+
+    game := &domain.Game{ID: uuid.New(), PlayerX: uuid.New(), PlayerO: uuid.New(), Board: domain.NewEmptyBoard(), NextTurn: "X", Status: domain.GameInProgress}
+    _ = gameRepo.CreateGame(ctx, game)
+
+    if _, err := games.AnnounceGame(ctx, game.ID); err != nil {
+        t.Fatalf("announce: %v", err)
+    }
+    if _, err := games.OfferDraw(ctx, game.PlayerX, game.ID); err != nil {
+        t.Fatalf("offer draw: %v", err)
+    }
+    if _, err := games.DeclineDraw(ctx, game.PlayerO, game.ID); err != nil {
+        t.Fatalf("decline draw: %v", err)
+    }
+    if len(published.seqs) != 3 || published.seqs[0] != 1 || published.seqs[2] != 3 {
+        t.Fatalf("expected seqs 1..3, got %v", published.seqs)
+    }
+
+    events, err := svc.Replay(ctx, game.PlayerO, game.ID, 1)
+    if err != nil {
+        t.Fatalf("replay: %v", err)
+    }
+    if len(events) != 2 || events[0].Seq != 2 || events[1].Seq != 3 || events[0].Type != domain.GameEventUpdate {
+        t.Fatalf("expected events 2 and 3")
+    }
+
+    if _, err := svc.Replay(ctx, uuid.New(), game.ID, 0); err != domain.ErrForbidden {
+        t.Fatalf("expected forbidden for non-player, got %v", err)
+    }
+}
+
+type failingEventRepo struct {
+    *memory.GameEventRepo
+}
+
+func (r failingEventRepo) AppendGameEvent(ctx context.Context, event *domain.GameEvent) error {
+    return errors.New("append failed")
+}
+
+func TestGameEventNotPublishedWhenAppendFails(t *testing.T) {
+    ctx := context.Background()
+    gameRepo := memory.NewGameRepo()
+    published := &recordedEvents{}
+    games := NewGameService(gameRepo, failingEventRepo{memory.NewGameEventRepo()}, published, nil)
+
+    game := &domain.Game{ID: uuid.New(), PlayerX: uuid.New(), PlayerO: uuid.New(), Board: domain.NewEmptyBoard(), NextTurn: "X", Status: domain.GameInProgress}
+    _ = gameRepo.CreateGame(ctx, game)
+
+    if _, err := games.MakeMove(ctx, game.PlayerX, game.ID, 4); err != nil {
+        t.Fatalf("move: %v", err)
+    }
+    if len(published.seqs) != 0 {
+        t.Fatalf("expected no broadcast without a stored seq, got %v", published.seqs)
+    }
+}
+
+func TestGameEventsPublishedInSeqOrder(t *testing.T) {
+    ctx := context.Background()
+    gameRepo := memory.NewGameRepo()
+    published := &recordedEvents{}
+    games := NewGameService(gameRepo, memory.NewGameEventRepo(), published, nil)
+
+    game := &domain.Game{ID: uuid.New(), PlayerX: uuid.New(), PlayerO: uuid.New(), Board: domain.NewEmptyBoard(), NextTurn: "X", Status: domain.GameInProgress}
+    _ = gameRepo.CreateGame(ctx, game)
+
+    var wg sync.WaitGroup
+    for i := 0; i < 50; i++ {
+        wg.Add(1)
+        go func(i int) {
+            defer wg.Done()
+            player := game.PlayerX
+            if i%2 == 1 {
+                player = game.PlayerO
+            }
+            if i%5 == 0 {
+                _, _ = games.MakeMove(ctx, player, game.ID, i%9)
+                return
+            }
+            _, _ = games.AddChat(ctx, player, game.ID, "hi")
+        }(i)
+    }
+    wg.Wait()
+
+    if len(published.seqs) == 0 {
+        t.Fatalf("expected published events")
+    }
+    for i, seq := range published.seqs {
+        if seq != int64(i+1) {
+            t.Fatalf("expected seq %d at position %d, got %v", i+1, i, published.seqs)
+        }
+    }
 }
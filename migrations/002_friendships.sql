@@ -0,0 +1,13 @@
+﻿-- 002_friendships.sql
+CREATE TABLE IF NOT EXISTS friendships (
+    requester_id UUID NOT NULL REFERENCES users(id),
+    addressee_id UUID NOT NULL REFERENCES users(id),
+    status TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (requester_id, addressee_id),
+    CHECK (requester_id <> addressee_id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_friendships_pair ON friendships(LEAST(requester_id, addressee_id), GREATEST(requester_id, addressee_id));
+CREATE INDEX IF NOT EXISTS idx_friendships_addressee ON friendships(addressee_id);
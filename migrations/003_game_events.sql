@@ -0,0 +1,9 @@
+﻿-- 003_game_events.sql
+CREATE TABLE IF NOT EXISTS game_events (
+    game_id UUID NOT NULL REFERENCES games(id),
+    seq BIGINT NOT NULL,
+    type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (game_id, seq)
+);
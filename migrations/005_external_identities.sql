@@ -0,0 +1,11 @@
+﻿-- 005_external_identities.sql
+CREATE TABLE IF NOT EXISTS external_identities (
+    provider TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id),
+    email TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_external_identities_user ON external_identities(user_id);
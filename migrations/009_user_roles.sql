@@ -0,0 +1,2 @@
+﻿-- 009_user_roles.sql
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'player';
@@ -0,0 +1,21 @@
+﻿-- 010_admin_tools.sql
+ALTER TABLE users ADD COLUMN IF NOT EXISTS banned BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS suspended_until TIMESTAMPTZ NULL;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS suspension_reason TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE games ADD COLUMN IF NOT EXISTS end_reason TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS reports (
+    id UUID PRIMARY KEY,
+    reporter_id UUID NOT NULL REFERENCES users(id),
+    user_id UUID NOT NULL REFERENCES users(id),
+    game_id UUID NULL REFERENCES games(id),
+    reason TEXT NOT NULL,
+    status TEXT NOT NULL,
+    resolution TEXT NOT NULL DEFAULT '',
+    resolved_by UUID NULL REFERENCES users(id),
+    created_at TIMESTAMPTZ NOT NULL,
+    resolved_at TIMESTAMPTZ NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_reports_status ON reports(status, created_at);
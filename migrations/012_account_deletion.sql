@@ -0,0 +1,5 @@
+﻿-- 012_account_deletion.sql
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ NULL;
+
+CREATE INDEX IF NOT EXISTS idx_game_moves_user_id ON game_moves(user_id);
+CREATE INDEX IF NOT EXISTS idx_game_messages_user_id ON game_messages(user_id);
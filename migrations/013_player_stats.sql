@@ -0,0 +1,36 @@
+﻿-- 013_player_stats.sql
+CREATE TABLE IF NOT EXISTS player_stats (
+    user_id UUID PRIMARY KEY REFERENCES users(id),
+    wins INT NOT NULL DEFAULT 0,
+    losses INT NOT NULL DEFAULT 0,
+    draws INT NOT NULL DEFAULT 0,
+    games_as_x INT NOT NULL DEFAULT 0,
+    wins_as_x INT NOT NULL DEFAULT 0,
+    games_as_o INT NOT NULL DEFAULT 0,
+    wins_as_o INT NOT NULL DEFAULT 0,
+    current_streak INT NOT NULL DEFAULT 0,
+    best_streak INT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS ratings (
+    user_id UUID NOT NULL REFERENCES users(id),
+    variant TEXT NOT NULL,
+    rating INT NOT NULL,
+    games INT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (user_id, variant)
+);
+
+CREATE TABLE IF NOT EXISTS rating_changes (
+    game_id UUID NOT NULL REFERENCES games(id),
+    user_id UUID NOT NULL REFERENCES users(id),
+    variant TEXT NOT NULL,
+    rating_before INT NOT NULL,
+    rating_after INT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (game_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_games_finished_player_x ON games(player_x, updated_at) WHERE status = 'finished';
+CREATE INDEX IF NOT EXISTS idx_games_finished_player_o ON games(player_o, updated_at) WHERE status = 'finished';
@@ -0,0 +1,3 @@
+﻿-- 014_leaderboards.sql
+CREATE INDEX IF NOT EXISTS idx_games_rated_finished ON games(updated_at) WHERE status = 'finished' AND rated;
+CREATE INDEX IF NOT EXISTS idx_ratings_variant ON ratings(variant, rating DESC);
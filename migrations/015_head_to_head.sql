@@ -0,0 +1,2 @@
+﻿-- 015_head_to_head.sql
+CREATE INDEX IF NOT EXISTS idx_games_pair ON games(player_x, player_o, updated_at DESC) WHERE status = 'finished';
@@ -0,0 +1,8 @@
+﻿-- 016_achievements.sql
+CREATE TABLE IF NOT EXISTS user_achievements (
+    user_id UUID NOT NULL REFERENCES users(id),
+    code TEXT NOT NULL,
+    game_id UUID REFERENCES games(id),
+    unlocked_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (user_id, code)
+);
@@ -0,0 +1,27 @@
+﻿-- 017_seasons.sql
+CREATE TABLE IF NOT EXISTS seasons (
+    id UUID PRIMARY KEY,
+    name TEXT NOT NULL,
+    status TEXT NOT NULL,
+    starts_at TIMESTAMPTZ NOT NULL,
+    ends_at TIMESTAMPTZ NULL,
+    closed_at TIMESTAMPTZ NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_seasons_one_open ON seasons(status) WHERE status = 'open';
+
+CREATE TABLE IF NOT EXISTS season_standings (
+    season_id UUID NOT NULL REFERENCES seasons(id),
+    user_id UUID NOT NULL REFERENCES users(id),
+    rank INT NOT NULL,
+    username TEXT NOT NULL,
+    rating INT NOT NULL,
+    games INT NOT NULL,
+    wins INT NOT NULL,
+    draws INT NOT NULL,
+    losses INT NOT NULL,
+    reward TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (season_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_season_standings_rank ON season_standings(season_id, rank);
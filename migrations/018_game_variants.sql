@@ -0,0 +1,14 @@
+﻿-- 018_game_variants.sql
+ALTER TABLE games ADD COLUMN IF NOT EXISTS variant TEXT NOT NULL DEFAULT 'standard';
+ALTER TABLE games ADD COLUMN IF NOT EXISTS time_control TEXT NOT NULL DEFAULT 'untimed';
+
+ALTER TABLE ratings ADD COLUMN IF NOT EXISTS time_control TEXT NOT NULL DEFAULT 'untimed';
+ALTER TABLE ratings DROP CONSTRAINT IF EXISTS ratings_pkey;
+ALTER TABLE ratings ADD CONSTRAINT ratings_pkey PRIMARY KEY (user_id, variant, time_control);
+
+ALTER TABLE rating_changes ADD COLUMN IF NOT EXISTS time_control TEXT NOT NULL DEFAULT 'untimed';
+
+DROP INDEX IF EXISTS idx_ratings_variant;
+CREATE INDEX IF NOT EXISTS idx_ratings_variant ON ratings(variant, time_control, rating DESC);
+DROP INDEX IF EXISTS idx_games_rated_finished;
+CREATE INDEX IF NOT EXISTS idx_games_rated_finished ON games(variant, time_control, updated_at) WHERE status = 'finished' AND rated;
@@ -0,0 +1,2 @@
+﻿-- 019_season_carry_over.sql
+ALTER TABLE seasons ADD COLUMN IF NOT EXISTS carry_over DOUBLE PRECISION NOT NULL DEFAULT 0.5;
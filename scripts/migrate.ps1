@@ -3,4 +3,6 @@
 docker compose up -d postgres
 Start-Sleep -Seconds 2
 $env:PGPASSWORD="postgres"
-psql -h localhost -U postgres -d xo -f .\migrations\001_init.sql
+Get-ChildItem .\migrations\*.sql | Sort-Object Name | ForEach-Object {
+    psql -h localhost -U postgres -d xo -f $_.FullName
+}
@@ -3,4 +3,6 @@ set -e
 
 docker compose up -d postgres
 sleep 2
-PGPASSWORD=postgres psql -h localhost -U postgres -d xo -f ./migrations/001_init.sql
+for f in ./migrations/*.sql; do
+    PGPASSWORD=postgres psql -h localhost -U postgres -d xo -f "$f"
+done